@@ -0,0 +1,66 @@
+package throttle
+
+import "time"
+
+// BannedTier is the Tier of a ThrottledError for a request rejected because
+// its client has been banned using Ban.
+const BannedTier = "banned"
+
+// Exempt lets all requests of the client pass without taking any tokens, e.g.
+// for internal services, until Unexempt is called.
+func (t *Throttle) Exempt(client string) {
+	client = t.key(client)
+	t.accessMutex.Lock()
+	defer t.accessMutex.Unlock()
+	if t.exempt == nil {
+		t.exempt = make(map[string]struct{})
+	}
+	t.exempt[client] = struct{}{}
+}
+
+// Unexempt throttles the requests of a client exempted using Exempt again.
+func (t *Throttle) Unexempt(client string) {
+	client = t.key(client)
+	t.accessMutex.Lock()
+	defer t.accessMutex.Unlock()
+	delete(t.exempt, client)
+}
+
+// Ban rejects all requests of the client for the given duration right away,
+// with a *ThrottledError whose Tier is BannedTier. A duration of 0 lifts the
+// client's ban. Exempted clients cannot be banned.
+func (t *Throttle) Ban(client string, d time.Duration) {
+	client = t.key(client)
+	t.accessMutex.Lock()
+	defer t.accessMutex.Unlock()
+	if d <= 0 {
+		delete(t.bans, client)
+		return
+	}
+	if t.bans == nil {
+		t.bans = make(map[string]time.Time)
+	}
+	t.bans[client] = t.clock.Now().Add(d)
+}
+
+// access reports whether the client is exempted, or for how long it is still
+// banned.
+func (t *Throttle) access(client string) (exempt bool, banned time.Duration) {
+	t.accessMutex.RLock()
+	_, exempt = t.exempt[client]
+	until, ok := t.bans[client]
+	t.accessMutex.RUnlock()
+	if exempt || !ok {
+		return exempt, 0
+	}
+	banned = until.Sub(t.clock.Now())
+	if banned <= 0 {
+		t.accessMutex.Lock()
+		if t.bans[client] == until {
+			delete(t.bans, client)
+		}
+		t.accessMutex.Unlock()
+		return false, 0
+	}
+	return false, banned
+}
@@ -0,0 +1,48 @@
+package throttle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExempt(t *testing.T) {
+	throttle := New(1 * time.Hour)
+	throttle.Exempt("alice")
+	for i := 0; i < 3; i++ {
+		if !throttle.Allow("alice") {
+			t.Errorf("request %d: expected exempted client to be allowed", i)
+		}
+	}
+	throttle.Unexempt("alice")
+	if !throttle.Allow("alice") || throttle.Allow("alice") {
+		t.Errorf("after unexempt: expected the client to be throttled again")
+	}
+}
+
+func TestBan(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Millisecond, WithClock(clock))
+	throttle.Ban("alice", 1*time.Minute)
+
+	err := throttle.Wait("alice")
+	var throttledErr *ThrottledError
+	if !errors.As(err, &throttledErr) || throttledErr.Tier != BannedTier || throttledErr.RetryAfter != 1*time.Minute {
+		t.Errorf("banned client: expected to be rejected for %v, got %v", 1*time.Minute, err)
+	}
+	if !throttle.Allow("bob") {
+		t.Errorf("other client: expected to be allowed")
+	}
+
+	clock.Advance(1 * time.Minute)
+	if !throttle.Allow("alice") {
+		t.Errorf("after ban: expected to be allowed")
+	}
+
+	throttle.Ban("alice", 1*time.Minute)
+	throttle.Ban("alice", 0)
+	clock.Advance(1 * time.Millisecond)
+	if !throttle.Allow("alice") {
+		t.Errorf("after lifting ban: expected to be allowed")
+	}
+}
@@ -0,0 +1,140 @@
+package throttle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"time"
+)
+
+// Reset fills the client's bucket, or the bucket of its Group, and forgives
+// its penalty (see WithPenalty), e.g. for a client that has been throttled by
+// mistake. Requests waiting for tokens already reserved keep their tokens. The
+// state of WithTier and WithGlobalLimit is not affected.
+func (t *Throttle) Reset(ctx context.Context, client string) error {
+	client = t.key(client)
+	if t.penalties != nil {
+		t.penalties.forgive(client)
+	}
+	key, limit := t.bucketKey(client), t.limit(client)
+	result, err := t.store.Peek(ctx, key, limit)
+	if err != nil {
+		return err
+	}
+	if n := int(math.Ceil(float64(result.Reset) / float64(limit.Rate))); n > 0 {
+		return t.store.Refund(ctx, key, limit, n)
+	}
+	return nil
+}
+
+// AdminHandler returns a handler exposing the state of the Throttle as JSON,
+// e.g. for support staff unsticking a customer without a redeploy:
+//
+//	GET  /clients                 the stats of all clients
+//	GET  /clients/{client}        the stats of a single client
+//	POST /clients/{client}/reset  Reset the client
+//	PUT  /clients/{client}/rate   SetClientRate, given {"rate": "1s"}; "0s" resets it
+//	PUT  /rate                    SetRate, given {"rate": "100ms"}
+//
+// The handler does no authentication; it must only be reachable by trusted
+// users. To serve it under a prefix, use http.StripPrefix.
+func AdminHandler(t *Throttle) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /clients", func(w http.ResponseWriter, r *http.Request) {
+		clients := make(map[string]adminClient)
+		for client, stats := range t.AllStats() {
+			clients[client] = t.adminClient(client, stats)
+		}
+		writeJSON(w, http.StatusOK, struct {
+			Rate    string                 `json:"rate"`
+			Clients map[string]adminClient `json:"clients"`
+		}{t.Rate().String(), clients})
+	})
+	mux.HandleFunc("GET /clients/{client}", func(w http.ResponseWriter, r *http.Request) {
+		client := r.PathValue("client")
+		writeJSON(w, http.StatusOK, t.adminClient(client, t.Stats(client)))
+	})
+	mux.HandleFunc("POST /clients/{client}/reset", func(w http.ResponseWriter, r *http.Request) {
+		client := r.PathValue("client")
+		if err := t.Reset(r.Context(), client); err != nil {
+			writeJSON(w, http.StatusInternalServerError, adminError{err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, t.adminClient(client, t.Stats(client)))
+	})
+	mux.HandleFunc("PUT /clients/{client}/rate", func(w http.ResponseWriter, r *http.Request) {
+		rate, err := readRate(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, adminError{err.Error()})
+			return
+		}
+		client := r.PathValue("client")
+		t.SetClientRate(client, rate)
+		writeJSON(w, http.StatusOK, t.adminClient(client, t.Stats(client)))
+	})
+	mux.HandleFunc("PUT /rate", func(w http.ResponseWriter, r *http.Request) {
+		rate, err := readRate(r)
+		if err == nil && rate == 0 {
+			err = errors.New("rate must be positive")
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, adminError{err.Error()})
+			return
+		}
+		t.SetRate(rate)
+		writeJSON(w, http.StatusOK, struct {
+			Rate string `json:"rate"`
+		}{rate.String()})
+	})
+	return mux
+}
+
+// adminClient describes a client in the responses of AdminHandler.
+type adminClient struct {
+	Rate        string    `json:"rate"`
+	Allowed     uint64    `json:"allowed"`
+	Rejected    uint64    `json:"rejected"`
+	Waiters     int       `json:"waiters"`
+	LastRequest time.Time `json:"last_request,omitzero"`
+}
+
+func (t *Throttle) adminClient(client string, stats ClientStats) adminClient {
+	return adminClient{
+		Rate:        t.rate(t.key(client)).String(),
+		Allowed:     stats.Allowed,
+		Rejected:    stats.Rejected,
+		Waiters:     stats.Waiters,
+		LastRequest: stats.LastRequest,
+	}
+}
+
+// adminError is the response of AdminHandler to a failed request.
+type adminError struct {
+	Error string `json:"error"`
+}
+
+// readRate reads a request rate like {"rate": "1s"} from the request's body.
+// A rate of "0s" is allowed, negative rates are not.
+func readRate(r *http.Request) (time.Duration, error) {
+	var body struct {
+		Rate *duration `json:"rate"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<10)).Decode(&body); err != nil {
+		return 0, err
+	}
+	if body.Rate == nil {
+		return 0, errors.New("rate missing")
+	}
+	if *body.Rate < 0 {
+		return 0, errors.New("rate must not be negative")
+	}
+	return time.Duration(*body.Rate), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,85 @@
+package throttle
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReset(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Second, WithBurst(2), WithClock(clock))
+	throttle.AllowN("alice", 2)
+	if throttle.Allow("alice") {
+		t.Fatalf("empty bucket: expected request to be rejected")
+	}
+	if err := throttle.Reset(context.Background(), "alice"); err != nil {
+		t.Fatalf("reset: expected no error, got %v", err)
+	}
+	if !throttle.AllowN("alice", 2) {
+		t.Errorf("reset bucket: expected to be full")
+	}
+}
+
+func TestAdminHandler(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Second, WithClock(clock))
+	throttle.Allow("alice")
+	throttle.Allow("alice")
+	handler := AdminHandler(throttle)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := do(http.MethodGet, "/clients", "")
+	var list struct {
+		Rate    string
+		Clients map[string]adminClient
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("list: expected status 200 and JSON, got %d and %v", w.Code, err)
+	}
+	if alice := list.Clients["alice"]; list.Rate != "1s" || alice.Allowed != 1 || alice.Rejected != 1 {
+		t.Errorf("list: expected rate 1s and alice with one allowed and one rejected request, got %+v", list)
+	}
+
+	if w := do(http.MethodPost, "/clients/alice/reset", ""); w.Code != http.StatusOK {
+		t.Errorf("reset: expected status 200, got %d", w.Code)
+	}
+	if !throttle.Allow("alice") {
+		t.Errorf("after reset: expected request to be allowed")
+	}
+
+	if w := do(http.MethodPut, "/clients/alice/rate", `{"rate": "5s"}`); w.Code != http.StatusOK {
+		t.Errorf("client rate: expected status 200, got %d", w.Code)
+	}
+	if rate := throttle.rate("alice"); rate != 5*time.Second {
+		t.Errorf("client rate: expected %v, got %v", 5*time.Second, rate)
+	}
+	if w := do(http.MethodPut, "/rate", `{"rate": "0s"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("zero rate: expected status 400, got %d", w.Code)
+	}
+	if w := do(http.MethodPut, "/rate", `{"rate": "2s"}`); w.Code != http.StatusOK || throttle.Rate() != 2*time.Second {
+		t.Errorf("rate: expected status 200 and rate 2s, got %d and %v", w.Code, throttle.Rate())
+	}
+}
+
+func TestAdminHandlerNormalized(t *testing.T) {
+	throttle := New(1*time.Second, WithKeyNormalizer(strings.ToLower))
+	throttle.SetClientRate("Alice", 5*time.Second)
+	handler := AdminHandler(throttle)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/clients/ALICE", nil))
+	var alice adminClient
+	if err := json.NewDecoder(w.Body).Decode(&alice); err != nil || alice.Rate != "5s" {
+		t.Errorf("expected the rate of the normalized client, got %+v and %v", alice, err)
+	}
+}
@@ -0,0 +1,143 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// AIMD configures the adaptive request rate set by WithAIMD. A client is
+// considered unhealthy if a reported request took longer than
+// LatencyThreshold, or if the share of failed requests among the recently
+// reported ones exceeds ErrorThreshold. Thresholds of 0 are not checked.
+type AIMD struct {
+	// LatencyThreshold is the latency beyond which a request is too slow.
+	LatencyThreshold time.Duration
+
+	// ErrorThreshold is the acceptable share of failed requests, e.g. 0.05.
+	ErrorThreshold float64
+
+	// MaxSlowdown is the factor by which the request rate of a client can be
+	// slowed down at most. If 0, the rate is slowed down by at most 64 times.
+	MaxSlowdown int
+}
+
+const (
+	// errorDecay is the weight of a single outcome in the error rate.
+	errorDecay = 0.1
+
+	// additiveIncrease is the share of the Throttle's throughput a healthy
+	// client gains per outcome.
+	additiveIncrease = 0.1
+)
+
+// aimd holds the state of the clients whose outcomes have been reported.
+type aimd struct {
+	config  AIMD
+	mutex   sync.Mutex
+	clients map[string]*health
+}
+
+// health is the state of a client's outcomes. While the client's rate is
+// adapted, base is the rate it had before, and own the rate set for the client
+// using SetClientRate then, if any, to be restored once it has recovered.
+type health struct {
+	errorRate    float64
+	lastDecrease time.Time
+	adapted      bool
+	base         time.Duration
+	own          time.Duration
+}
+
+// Outcome describes how a request processed downstream went.
+type Outcome struct {
+	// Latency is the time the request took.
+	Latency time.Duration
+
+	// Err is the error of the request, or nil if it succeeded.
+	Err error
+}
+
+// WithAIMD adapts the request rate of every client to the outcomes of its
+// requests reported using Report: the throughput of an unhealthy client is
+// halved (multiplicative decrease), at most once per its current request rate,
+// and the throughput of a healthy client is increased by a tenth of its
+// original throughput per reported request (additive increase), until the
+// request rate is back to the client's rate from before the adaptation, e.g.
+// one set using SetClientRate or WithTierFunc. The adapted rate is set using
+// SetClientRate.
+func WithAIMD(config AIMD) Option {
+	return func(t *Throttle) {
+		if config.MaxSlowdown < 1 {
+			config.MaxSlowdown = 64
+		}
+		t.aimd = &aimd{config: config, clients: make(map[string]*health)}
+	}
+}
+
+// Report adapts the client's request rate to the outcome of one of its
+// requests, and feeds the client's circuit breaker set by WithBreaker. Without
+// WithAIMD and WithBreaker, Report does nothing.
+func (t *Throttle) Report(client string, outcome Outcome) {
+	if t.aimd == nil && t.breaker == nil {
+		return
+	}
+	client = t.key(client)
+	if t.breaker != nil {
+		t.breaker.report(client, outcome.Err != nil, t.clock.Now())
+	}
+	if t.aimd != nil {
+		t.adapt(client, outcome)
+	}
+}
+
+// adapt adapts the client's request rate to the outcome of one of its
+// requests.
+func (t *Throttle) adapt(client string, outcome Outcome) {
+	a := t.aimd
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	h, ok := a.clients[client]
+	if !ok {
+		h = &health{}
+		a.clients[client] = h
+	}
+	failed := 0.0
+	if outcome.Err != nil {
+		failed = 1
+	}
+	h.errorRate += errorDecay * (failed - h.errorRate)
+
+	now := t.clock.Now()
+	rate := t.rate(client)
+	unhealthy := (a.config.LatencyThreshold > 0 && outcome.Latency > a.config.LatencyThreshold) ||
+		(a.config.ErrorThreshold > 0 && h.errorRate > a.config.ErrorThreshold)
+	switch {
+	case unhealthy:
+		if now.Sub(h.lastDecrease) < rate {
+			// the previous decrease has not taken effect yet
+			return
+		}
+		if !h.adapted {
+			t.rateMutex.RLock()
+			h.base, h.own = rate, t.clientRates[client]
+			t.rateMutex.RUnlock()
+			h.adapted = true
+		}
+		h.lastDecrease = now
+		slowest := h.base * time.Duration(a.config.MaxSlowdown)
+		if rate *= 2; rate > slowest {
+			rate = slowest
+		}
+		t.setClientRate(client, rate)
+	case h.adapted:
+		// throughput is the inverse of the rate, so add to that
+		rate = time.Duration(1 / (1/float64(rate) + additiveIncrease/float64(h.base)))
+		if rate <= h.base {
+			rate, h.adapted = h.own, false
+		}
+		t.setClientRate(client, rate)
+	case h.errorRate < errorDecay*errorDecay:
+		// healthy at full speed, nothing worth remembering
+		delete(a.clients, client)
+	}
+}
@@ -0,0 +1,89 @@
+package throttle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAIMD(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithClock(clock), WithAIMD(AIMD{
+		LatencyThreshold: 1 * time.Second,
+		MaxSlowdown:      4,
+	}))
+
+	slow := Outcome{Latency: 2 * time.Second}
+	throttle.Report("alice", slow)
+	if rate := throttle.rate("alice"); rate != 200*time.Millisecond {
+		t.Errorf("slow request: expected rate %v, got %v", 200*time.Millisecond, rate)
+	}
+
+	// decreases take effect once per rate
+	throttle.Report("alice", slow)
+	if rate := throttle.rate("alice"); rate != 200*time.Millisecond {
+		t.Errorf("another slow request: expected rate %v, got %v", 200*time.Millisecond, rate)
+	}
+	for i := 0; i < 3; i++ {
+		clock.Advance(1 * time.Second)
+		throttle.Report("alice", slow)
+	}
+	if rate := throttle.rate("alice"); rate != 400*time.Millisecond {
+		t.Errorf("slowest rate: expected %v, got %v", 400*time.Millisecond, rate)
+	}
+
+	// every healthy request adds a tenth of the throughput
+	fast := Outcome{Latency: 10 * time.Millisecond}
+	throttle.Report("alice", fast)
+	if rate := throttle.rate("alice"); rate != 285714285*time.Nanosecond {
+		t.Errorf("fast request: expected rate %v, got %v", 285714285*time.Nanosecond, rate)
+	}
+	for i := 0; i < 10; i++ {
+		throttle.Report("alice", fast)
+	}
+	if rate := throttle.rate("alice"); rate != throttle.Rate() {
+		t.Errorf("recovered: expected rate %v, got %v", throttle.Rate(), rate)
+	}
+}
+
+func TestAIMDClientRate(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Second, WithClock(clock), WithAIMD(AIMD{
+		LatencyThreshold: 1 * time.Second,
+		MaxSlowdown:      4,
+	}))
+	throttle.SetClientRate("alice", 100*time.Millisecond)
+
+	slow := Outcome{Latency: 2 * time.Second}
+	for i := 0; i < 3; i++ {
+		clock.Advance(1 * time.Second)
+		throttle.Report("alice", slow)
+	}
+	if rate := throttle.rate("alice"); rate != 400*time.Millisecond {
+		t.Errorf("slowest rate: expected %v, got %v", 400*time.Millisecond, rate)
+	}
+
+	fast := Outcome{Latency: 10 * time.Millisecond}
+	for i := 0; i < 40; i++ {
+		throttle.Report("alice", fast)
+	}
+	if rate := throttle.rate("alice"); rate != 100*time.Millisecond {
+		t.Errorf("recovered: expected the client's rate %v, got %v", 100*time.Millisecond, rate)
+	}
+}
+
+func TestAIMDErrors(t *testing.T) {
+	throttle := New(100*time.Millisecond, WithAIMD(AIMD{ErrorThreshold: 0.25}))
+	failed := Outcome{Err: errors.New("unavailable")}
+	for i := 0; i < 2; i++ {
+		throttle.Report("alice", failed)
+	}
+	if rate := throttle.rate("alice"); rate != throttle.Rate() {
+		t.Errorf("few errors: expected rate %v, got %v", throttle.Rate(), rate)
+	}
+	throttle.Report("alice", failed)
+	if rate := throttle.rate("alice"); rate != 200*time.Millisecond {
+		t.Errorf("many errors: expected rate %v, got %v", 200*time.Millisecond, rate)
+	}
+	New(100*time.Millisecond).Report("alice", failed)
+}
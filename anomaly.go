@@ -0,0 +1,135 @@
+package throttle
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// baselineDecay is the weight of a single window in a client's baseline.
+	baselineDecay = 0.2
+
+	// baselineWindows is the number of windows a client must have been
+	// observed for before its baseline is trusted.
+	baselineWindows = 3
+)
+
+// AnomalyDetector configures the detection of spikes set by
+// WithAnomalyDetector.
+type AnomalyDetector struct {
+	// Window is the period over which the requests of a client are counted.
+	// If 0, it is one minute.
+	Window time.Duration
+
+	// Factor is how many times the baseline a client's requests in a window
+	// must exceed to be a spike. If 0, it is 10.
+	Factor float64
+
+	// MinRequests is the number of requests in a window below which a client
+	// never spikes, so that quiet clients do not spike by making a handful
+	// of requests. If 0, it is 10.
+	MinRequests int
+}
+
+// anomalies holds the request rates of the clients.
+type anomalies struct {
+	config  AnomalyDetector
+	onSpike func(client string, requests int, baseline float64)
+	mutex   sync.Mutex
+	clients map[string]*observation
+}
+
+// observation is the request rate of a client.
+type observation struct {
+	start    time.Time
+	count    int
+	baseline float64
+	windows  int
+	spiked   bool
+}
+
+// WithAnomalyDetector calls onSpike whenever the number of requests a client
+// made within a window of config.Window spikes beyond config.Factor times the
+// client's baseline, regardless of whether the requests were allowed, e.g. to
+// feed a fraud or abuse detection pipeline with clients behaving unusually
+// while still within their limits. The baseline is the moving average of the
+// client's requests per window, which is only trusted after three windows.
+// onSpike is called at most once per window and client, with the client, its
+// requests in the current window, and its baseline; it is called
+// synchronously and must therefore be fast.
+func WithAnomalyDetector(config AnomalyDetector, onSpike func(client string, requests int, baseline float64)) Option {
+	return func(t *Throttle) {
+		if config.Window <= 0 {
+			config.Window = time.Minute
+		}
+		if config.Factor <= 0 {
+			config.Factor = 10
+		}
+		if config.MinRequests < 1 {
+			config.MinRequests = 10
+		}
+		a := &anomalies{config: config, onSpike: onSpike, clients: make(map[string]*observation)}
+		t.anomalies = a
+		t.onAllow = append(t.onAllow, func(client string, waited time.Duration) {
+			a.observe(client, t.clock.Now())
+		})
+		t.onReject = append(t.onReject, func(client string) {
+			a.observe(client, t.clock.Now())
+		})
+	}
+}
+
+// observe counts a request of the client made at the given time, and reports
+// a spike if need be.
+func (a *anomalies) observe(client string, now time.Time) {
+	a.mutex.Lock()
+	o, ok := a.clients[client]
+	if !ok {
+		o = &observation{start: now}
+		a.clients[client] = o
+	}
+	if elapsed := now.Sub(o.start); elapsed >= a.config.Window {
+		// the current window is over, and so are the empty ones since
+		windows := int(elapsed / a.config.Window)
+		if o.windows == 0 {
+			o.baseline = float64(o.count)
+		} else {
+			o.baseline += baselineDecay * (float64(o.count) - o.baseline)
+		}
+		o.baseline *= math.Pow(1-baselineDecay, float64(windows-1))
+		o.windows += windows
+		o.start = o.start.Add(time.Duration(windows) * a.config.Window)
+		o.count, o.spiked = 0, false
+	}
+	o.count++
+	spike := !o.spiked && o.windows >= baselineWindows && o.count >= a.config.MinRequests &&
+		float64(o.count) > a.config.Factor*o.baseline
+	if spike {
+		o.spiked = true
+	}
+	requests, baseline := o.count, o.baseline
+	a.mutex.Unlock()
+	if spike {
+		a.onSpike(client, requests, baseline)
+	}
+}
+
+// evictIdle forgets the clients that have not made a request since
+// idleTimeout before now.
+func (a *anomalies) evictIdle(now time.Time, idleTimeout time.Duration) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for client, o := range a.clients {
+		if now.Sub(o.start) >= idleTimeout+a.config.Window {
+			delete(a.clients, client)
+		}
+	}
+}
+
+// forget forgets the client's request rate.
+func (a *anomalies) forget(client string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	delete(a.clients, client)
+}
@@ -0,0 +1,42 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnomalyDetector(t *testing.T) {
+	clock := newFakeClock()
+	type spike struct {
+		client   string
+		requests int
+		baseline float64
+	}
+	var spikes []spike
+	throttle := New(1*time.Millisecond, WithBurst(100), WithClock(clock),
+		WithAnomalyDetector(AnomalyDetector{Window: 1 * time.Second, Factor: 3, MinRequests: 5}, func(client string, requests int, baseline float64) {
+			spikes = append(spikes, spike{client, requests, baseline})
+		}))
+
+	for window := 0; window < 3; window++ {
+		for i := 0; i < 2; i++ {
+			throttle.Allow("alice")
+		}
+		clock.Advance(1 * time.Second)
+	}
+	// a burst of 10 requests spikes beyond three times the baseline of 2
+	for i := 0; i < 10; i++ {
+		throttle.Allow("alice")
+	}
+	if len(spikes) != 1 || spikes[0] != (spike{"alice", 7, 2}) {
+		t.Errorf("expected one spike at the 7th request, got %v", spikes)
+	}
+
+	// a client without a baseline never spikes
+	for i := 0; i < 10; i++ {
+		throttle.Allow("bob")
+	}
+	if len(spikes) != 1 {
+		t.Errorf("expected no spike without a baseline, got %v", spikes)
+	}
+}
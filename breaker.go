@@ -0,0 +1,182 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// BreakerTier is the Tier of a ThrottledError for a request rejected because
+// the client's circuit breaker is open.
+const BreakerTier = "breaker"
+
+// Breaker configures the circuit breaker set by WithBreaker.
+type Breaker struct {
+	// Failures is the number of consecutive failed requests after which the
+	// breaker opens. If 0, it opens after 5 failures.
+	Failures int
+
+	// Cooldown is the time the breaker stays open before letting trial
+	// requests pass. If 0, it is 10 seconds.
+	Cooldown time.Duration
+
+	// Trials is the number of trial requests let pass per Cooldown while the
+	// breaker is half-open, all of which must succeed to close it again. If
+	// 0, a single trial request is let pass.
+	Trials int
+}
+
+// BreakerState is the state of a client's circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets all requests pass on to the Throttle's limits.
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen rejects all requests.
+	BreakerOpen
+
+	// BreakerHalfOpen lets trial requests pass, whose outcomes decide
+	// whether the breaker closes or opens again.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// breaker holds the circuits of the clients whose requests have failed
+// recently.
+type breaker struct {
+	config  Breaker
+	mutex   sync.Mutex
+	clients map[string]*circuit
+}
+
+// circuit is the state of a client's circuit breaker.
+type circuit struct {
+	state     BreakerState
+	failures  int
+	successes int
+	trials    int
+	since     time.Time
+}
+
+// WithBreaker adds a circuit breaker per client, fed by the outcomes reported
+// using Report: once config.Failures requests of a client have failed in a
+// row, the breaker opens, and the client's requests are rejected with a
+// *ThrottledError whose Tier is BreakerTier for config.Cooldown. Then, the
+// breaker is half-open, and lets config.Trials requests per cooldown pass
+// to probe whether the client's downstream has recovered: once all of them
+// have succeeded, the breaker closes again, and as soon as one of them fails,
+// it opens again. Requests let pass are still subject to the Throttle's
+// limits.
+func WithBreaker(config Breaker) Option {
+	return func(t *Throttle) {
+		if config.Failures < 1 {
+			config.Failures = 5
+		}
+		if config.Cooldown <= 0 {
+			config.Cooldown = 10 * time.Second
+		}
+		if config.Trials < 1 {
+			config.Trials = 1
+		}
+		t.breaker = &breaker{config: config, clients: make(map[string]*circuit)}
+	}
+}
+
+// BreakerState returns the state of the client's circuit breaker, which is
+// always closed without WithBreaker.
+func (t *Throttle) BreakerState(client string) BreakerState {
+	if t.breaker == nil {
+		return BreakerClosed
+	}
+	client = t.key(client)
+	b := t.breaker
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	c, ok := b.clients[client]
+	if !ok {
+		return BreakerClosed
+	}
+	c.advance(t.clock.Now(), b.config.Cooldown)
+	return c.state
+}
+
+// pass reports whether a request of the client may pass the breaker at the
+// given time, or how long it takes until the next trial request may pass.
+func (b *breaker) pass(client string, now time.Time) (bool, time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	c, ok := b.clients[client]
+	if !ok {
+		return true, 0
+	}
+	c.advance(now, b.config.Cooldown)
+	switch c.state {
+	case BreakerOpen:
+		return false, c.since.Add(b.config.Cooldown).Sub(now)
+	case BreakerHalfOpen:
+		if now.Sub(c.since) >= b.config.Cooldown {
+			// the trials of the previous cooldown never reported back
+			c.since, c.trials = now, 0
+		}
+		if c.trials >= b.config.Trials {
+			return false, c.since.Add(b.config.Cooldown).Sub(now)
+		}
+		c.trials++
+	}
+	return true, 0
+}
+
+// report records the outcome of a request of the client made at the given
+// time.
+func (b *breaker) report(client string, failed bool, now time.Time) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	c, ok := b.clients[client]
+	if !ok {
+		if !failed {
+			return
+		}
+		c = &circuit{}
+		b.clients[client] = c
+	}
+	c.advance(now, b.config.Cooldown)
+	switch {
+	case failed && c.state == BreakerHalfOpen:
+		c.state, c.since = BreakerOpen, now
+	case failed:
+		if c.failures++; c.failures >= b.config.Failures && c.state == BreakerClosed {
+			c.state, c.since = BreakerOpen, now
+		}
+	case c.state == BreakerHalfOpen:
+		if c.successes++; c.successes >= b.config.Trials {
+			delete(b.clients, client)
+		}
+	case c.state == BreakerClosed:
+		// the failures are no longer consecutive
+		delete(b.clients, client)
+	}
+}
+
+// advance makes an open circuit half-open once the cooldown is over.
+func (c *circuit) advance(now time.Time, cooldown time.Duration) {
+	if c.state == BreakerOpen && now.Sub(c.since) >= cooldown {
+		c.state, c.since = BreakerHalfOpen, now
+		c.failures, c.successes, c.trials = 0, 0, 0
+	}
+}
+
+// forget forgets the client's circuit.
+func (b *breaker) forget(client string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.clients, client)
+}
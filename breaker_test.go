@@ -0,0 +1,69 @@
+package throttle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Millisecond, WithBurst(10), WithClock(clock),
+		WithBreaker(Breaker{Failures: 3, Cooldown: 1 * time.Second, Trials: 2}))
+	failed := Outcome{Err: errors.New("unavailable")}
+
+	for i := 0; i < 2; i++ {
+		throttle.Report("alice", failed)
+	}
+	throttle.Report("alice", Outcome{})
+	for i := 0; i < 2; i++ {
+		throttle.Report("alice", failed)
+	}
+	if state := throttle.BreakerState("alice"); state != BreakerClosed {
+		t.Fatalf("failures not in a row: expected %v, got %v", BreakerClosed, state)
+	}
+	throttle.Report("alice", failed)
+	if state := throttle.BreakerState("alice"); state != BreakerOpen {
+		t.Fatalf("after 3 failures in a row: expected %v, got %v", BreakerOpen, state)
+	}
+	err := throttle.Wait("alice")
+	var throttledErr *ThrottledError
+	if !errors.As(err, &throttledErr) || throttledErr.Tier != BreakerTier || throttledErr.RetryAfter != 1*time.Second {
+		t.Errorf("open breaker: expected to be rejected for the cooldown, got %v", err)
+	}
+	if !throttle.Allow("bob") {
+		t.Error("expected other clients not to be affected")
+	}
+
+	// a failing trial opens the breaker again
+	clock.Advance(1 * time.Second)
+	if !throttle.Allow("alice") {
+		t.Fatal("half-open breaker: expected a trial request to pass")
+	}
+	throttle.Report("alice", failed)
+	if throttle.Allow("alice") {
+		t.Error("failed trial: expected the breaker to open again")
+	}
+
+	// all trials must succeed to close the breaker
+	clock.Advance(1 * time.Second)
+	for i := 0; i < 2; i++ {
+		if !throttle.Allow("alice") {
+			t.Fatalf("trial %d: expected to pass", i)
+		}
+	}
+	if throttle.Allow("alice") {
+		t.Error("expected no more than 2 trial requests to pass")
+	}
+	if state := throttle.BreakerState("alice"); state != BreakerHalfOpen {
+		t.Errorf("during trials: expected %v, got %v", BreakerHalfOpen, state)
+	}
+	throttle.Report("alice", Outcome{})
+	throttle.Report("alice", Outcome{})
+	if state := throttle.BreakerState("alice"); state != BreakerClosed {
+		t.Errorf("after successful trials: expected %v, got %v", BreakerClosed, state)
+	}
+	if !throttle.Allow("alice") {
+		t.Error("closed breaker: expected the request to pass")
+	}
+}
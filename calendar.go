@@ -0,0 +1,213 @@
+package throttle
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Period is the calendar period of a CalendarQuota.
+type Period int
+
+const (
+	// Daily quotas reset at midnight.
+	Daily Period = iota
+
+	// Weekly quotas reset at midnight from Sunday to Monday.
+	Weekly
+
+	// Monthly quotas reset at midnight of the first day of the month.
+	Monthly
+)
+
+// CalendarQuota is a limit of N requests per calendar period, e.g. 10000
+// requests per day, which resets at the start of every period rather than
+// refilling continuously like the limits of WithTier.
+type CalendarQuota struct {
+	// Name is reported as the ThrottledError's Tier; names have to be
+	// unique.
+	Name string
+
+	// N is the number of requests allowed per period.
+	N int
+
+	// Period is the period after which the quota resets.
+	Period Period
+
+	// Location is the time zone the periods start in. If nil, UTC is used.
+	Location *time.Location
+}
+
+// bounds returns the start of the quota's period containing now, and the start
+// of the next period.
+func (q CalendarQuota) bounds(now time.Time) (start, next time.Time) {
+	loc := q.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	y, m, d := now.In(loc).Date()
+	switch q.Period {
+	case Weekly:
+		start = time.Date(y, m, d, 0, 0, 0, 0, loc)
+		// days since Monday
+		start = start.AddDate(0, 0, -(int(start.Weekday())+6)%7)
+		next = start.AddDate(0, 0, 7)
+	case Monthly:
+		start = time.Date(y, m, 1, 0, 0, 0, 0, loc)
+		next = start.AddDate(0, 1, 0)
+	default:
+		start = time.Date(y, m, d, 0, 0, 0, 0, loc)
+		next = start.AddDate(0, 0, 1)
+	}
+	return start, next
+}
+
+// QuotaStore keeps the usage of the calendar quotas of a Throttle's clients,
+// e.g. in a database, so that restarting the process does not reset the
+// quotas. Implementations must be safe for concurrent use.
+type QuotaStore interface {
+	// Consume adds n requests to the client's usage of the named quota in
+	// the period starting at start, unless the usage would exceed limit. It
+	// reports whether the requests were added.
+	Consume(ctx context.Context, quota, client string, start time.Time, n, limit int) (bool, error)
+
+	// Refund takes n requests back from the client's usage of the named
+	// quota in the period starting at start.
+	Refund(ctx context.Context, quota, client string, start time.Time, n int) error
+}
+
+// calendarQuota is a CalendarQuota enforced by a Throttle.
+type calendarQuota struct {
+	CalendarQuota
+	store QuotaStore
+}
+
+// WithCalendarQuota adds a calendar quota for every client, which is enforced
+// in addition to the Throttle's rate and other limits. Requests cannot wait
+// for the quota to reset; a request exceeding it is rejected with a
+// *ThrottledError whose RetryAfter is the time until the next period starts.
+// The usage is kept in store, or in memory if store is nil. Calendar quotas
+// do not apply to WaitAll.
+func WithCalendarQuota(quota CalendarQuota, store QuotaStore) Option {
+	return func(t *Throttle) {
+		if quota.N < 1 {
+			quota.N = 1
+		}
+		if store == nil {
+			store = NewMemoryQuotaStore()
+		}
+		t.calendar = append(t.calendar, calendarQuota{CalendarQuota: quota, store: store})
+	}
+}
+
+// consumeQuotas adds n requests to the client's usage of all calendar quotas.
+// If a quota is exceeded, the requests already added are refunded, and a
+// *ThrottledError is returned.
+func (t *Throttle) consumeQuotas(ctx context.Context, client string, n int) error {
+	now := t.clock.Now()
+	for i, q := range t.calendar {
+		start, next := q.bounds(now)
+		ok, err := q.store.Consume(ctx, q.Name, client, start, n, q.N)
+		if err == nil && !ok {
+			err = &ThrottledError{
+				Client:     client,
+				Tier:       q.Name,
+				Rate:       next.Sub(start) / time.Duration(q.N),
+				RetryAfter: next.Sub(now),
+			}
+		}
+		if err != nil {
+			t.refundQuotas(t.calendar[:i], client, n, now)
+			return err
+		}
+	}
+	return nil
+}
+
+// refundQuotas takes n requests back from the client's usage of the quotas in
+// the periods containing now.
+func (t *Throttle) refundQuotas(quotas []calendarQuota, client string, n int, now time.Time) {
+	for _, q := range quotas {
+		start, _ := q.bounds(now)
+		q.store.Refund(context.Background(), q.Name, client, start, n)
+	}
+}
+
+// MemoryQuotaStore is a QuotaStore keeping the usage in memory, which can be
+// saved and restored as JSON, e.g. when restarting the process. Usage of past
+// periods is dropped once a new period has started.
+type MemoryQuotaStore struct {
+	mutex  sync.Mutex
+	quotas map[string]*quotaUsage
+}
+
+// quotaUsage is the usage of a quota in its current period.
+type quotaUsage struct {
+	Start   time.Time      `json:"start"`
+	Clients map[string]int `json:"clients"`
+}
+
+// NewMemoryQuotaStore creates an empty MemoryQuotaStore.
+func NewMemoryQuotaStore() *MemoryQuotaStore {
+	return &MemoryQuotaStore{quotas: make(map[string]*quotaUsage)}
+}
+
+// Consume implements QuotaStore.
+func (s *MemoryQuotaStore) Consume(ctx context.Context, quota, client string, start time.Time, n, limit int) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	u, ok := s.quotas[quota]
+	if !ok || u.Start.Before(start) {
+		// a new period has started
+		u = &quotaUsage{Start: start, Clients: make(map[string]int)}
+		s.quotas[quota] = u
+	}
+	if !u.Start.Equal(start) {
+		// a request of a past period, which is over
+		return false, nil
+	}
+	if u.Clients[client]+n > limit {
+		return false, nil
+	}
+	u.Clients[client] += n
+	return true, nil
+}
+
+// Refund implements QuotaStore.
+func (s *MemoryQuotaStore) Refund(ctx context.Context, quota, client string, start time.Time, n int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	u, ok := s.quotas[quota]
+	if !ok || !u.Start.Equal(start) {
+		return nil
+	}
+	if u.Clients[client] -= n; u.Clients[client] <= 0 {
+		delete(u.Clients, client)
+	}
+	return nil
+}
+
+// MarshalJSON serializes the usage of all quotas.
+func (s *MemoryQuotaStore) MarshalJSON() ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return json.Marshal(s.quotas)
+}
+
+// UnmarshalJSON replaces the usage of all quotas by the serialized one.
+func (s *MemoryQuotaStore) UnmarshalJSON(data []byte) error {
+	var quotas map[string]*quotaUsage
+	if err := json.Unmarshal(data, &quotas); err != nil {
+		return err
+	}
+	for _, u := range quotas {
+		if u.Clients == nil {
+			u.Clients = make(map[string]int)
+		}
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.quotas = quotas
+	return nil
+}
@@ -0,0 +1,82 @@
+package throttle
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCalendarQuotaBounds(t *testing.T) {
+	zurich, err := time.LoadLocation("Europe/Zurich")
+	if err != nil {
+		t.Skip(err)
+	}
+	now := time.Date(2026, time.October, 14, 23, 30, 0, 0, time.UTC) // a Wednesday
+	testCases := []struct {
+		quota       CalendarQuota
+		start, next time.Time
+	}{
+		{CalendarQuota{Period: Daily}, time.Date(2026, time.October, 14, 0, 0, 0, 0, time.UTC), time.Date(2026, time.October, 15, 0, 0, 0, 0, time.UTC)},
+		{CalendarQuota{Period: Daily, Location: zurich}, time.Date(2026, time.October, 15, 0, 0, 0, 0, zurich), time.Date(2026, time.October, 16, 0, 0, 0, 0, zurich)},
+		{CalendarQuota{Period: Weekly}, time.Date(2026, time.October, 12, 0, 0, 0, 0, time.UTC), time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)},
+		{CalendarQuota{Period: Monthly}, time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, time.November, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, testCase := range testCases {
+		start, next := testCase.quota.bounds(now)
+		if !start.Equal(testCase.start) || !next.Equal(testCase.next) {
+			t.Errorf("%+v: expected period from %v to %v, got %v to %v", testCase.quota, testCase.start, testCase.next, start, next)
+		}
+	}
+}
+
+func TestCalendarQuota(t *testing.T) {
+	clock := newFakeClock()
+	clock.Advance(time.Date(2026, time.October, 14, 22, 0, 0, 0, time.UTC).Sub(clock.Now()))
+	store := NewMemoryQuotaStore()
+	throttle := New(1*time.Millisecond, WithBurst(10), WithClock(clock),
+		WithCalendarQuota(CalendarQuota{Name: "daily", N: 3}, store))
+	defer throttle.Close()
+
+	for i := 0; i < 3; i++ {
+		if !throttle.Allow("alice") {
+			t.Fatalf("request %d within the quota throttled", i)
+		}
+	}
+	var throttledErr *ThrottledError
+	if err := throttle.Wait("alice"); !errors.As(err, &throttledErr) || throttledErr.Tier != "daily" || throttledErr.RetryAfter != 2*time.Hour {
+		t.Errorf("request beyond the quota: expected to be told to retry after 2h, got %v", err)
+	}
+	if remaining := throttle.Remaining("alice"); remaining != 7 {
+		t.Errorf("request rejected by the quota took tokens, %d remaining", remaining)
+	}
+	if !throttle.Allow("bob") {
+		t.Error("request of other client throttled")
+	}
+
+	// the usage survives a restart
+	data, err := json.Marshal(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewMemoryQuotaStore()
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatal(err)
+	}
+	restarted := New(1*time.Millisecond, WithBurst(10), WithClock(clock),
+		WithCalendarQuota(CalendarQuota{Name: "daily", N: 3}, restored))
+	defer restarted.Close()
+	if restarted.Allow("alice") {
+		t.Error("quota reset by restart")
+	}
+
+	throttle.Refund("alice")
+	if !throttle.Allow("alice") {
+		t.Error("refunded request throttled")
+	}
+
+	clock.Advance(2 * time.Hour)
+	if !throttle.Allow("alice") || !restarted.Allow("alice") {
+		t.Error("request throttled after the quota reset at midnight")
+	}
+}
@@ -0,0 +1,58 @@
+package throttle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+// CertIdentity selects the part of a TLS client certificate CertKey keys
+// requests by.
+type CertIdentity int
+
+const (
+	// CertCommonName is the common name of the certificate's subject.
+	CertCommonName CertIdentity = iota
+
+	// CertSAN is the first subject alternative name of the certificate, trying
+	// URIs (e.g. SPIFFE IDs), DNS names, email addresses, and IP addresses in
+	// this order, or the common name if there is none.
+	CertSAN
+
+	// CertSPKI is the hex encoded SHA-256 hash of the certificate's public
+	// key, which stays the same when a certificate is renewed for the same
+	// key, and cannot be claimed by another certificate.
+	CertSPKI
+)
+
+// CertKey returns a function keying requests by the identity of the TLS
+// client certificate they were made with, to be used with Middleware, e.g. to
+// throttle the services calling an internal API secured by mutual TLS. The
+// certificate is only trusted as far as the server's tls.Config verifies it.
+// Requests without a client certificate are keyed by the host part of their
+// remote address.
+func CertKey(identity CertIdentity) func(*http.Request) string {
+	return func(r *http.Request) string {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			return remoteHost(r)
+		}
+		cert := r.TLS.PeerCertificates[0]
+		switch identity {
+		case CertSAN:
+			switch {
+			case len(cert.URIs) > 0:
+				return cert.URIs[0].String()
+			case len(cert.DNSNames) > 0:
+				return cert.DNSNames[0]
+			case len(cert.EmailAddresses) > 0:
+				return cert.EmailAddresses[0]
+			case len(cert.IPAddresses) > 0:
+				return cert.IPAddresses[0].String()
+			}
+		case CertSPKI:
+			sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			return hex.EncodeToString(sum[:])
+		}
+		return cert.Subject.CommonName
+	}
+}
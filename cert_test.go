@@ -0,0 +1,68 @@
+package throttle
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// newCert creates a self-signed certificate from the template.
+func newCert(t *testing.T, template *x509.Certificate) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(1)
+	template.NotBefore = time.Now()
+	template.NotAfter = time.Now().Add(time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestCertKey(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.org/billing")
+	withURI := newCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}, URIs: []*url.URL{spiffe}, DNSNames: []string{"billing.internal"}})
+	withDNS := newCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "search"}, DNSNames: []string{"search.internal"}})
+	plain := newCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "reports"}})
+	sum := sha256.Sum256(plain.RawSubjectPublicKeyInfo)
+
+	tests := []struct {
+		identity CertIdentity
+		cert     *x509.Certificate
+		key      string
+	}{
+		{CertCommonName, withURI, "billing"},
+		{CertSAN, withURI, "spiffe://example.org/billing"},
+		{CertSAN, withDNS, "search.internal"},
+		{CertSAN, plain, "reports"},
+		{CertSPKI, plain, hex.EncodeToString(sum[:])},
+		{CertSPKI, nil, "192.0.2.1"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.cert != nil {
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{test.cert}}
+		}
+		if key := CertKey(test.identity)(req); key != test.key {
+			t.Errorf("identity %d: expected key %q, got %q", test.identity, test.key, key)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package throttle
+
+import (
+	"context"
+	"fmt"
+)
+
+// Limiter limits the rate of the requests of clients. It is implemented by
+// *Throttle, and by the Limiters returned by Chain.
+type Limiter interface {
+	// WaitContext takes a token for the client, like Throttle.WaitContext.
+	WaitContext(ctx context.Context, client string) error
+
+	// Allow takes a token for the client if one is available right now, like
+	// Throttle.Allow.
+	Allow(client string) bool
+
+	// Refund puts a token taken for the client back, like Throttle.Refund.
+	Refund(client string)
+}
+
+// ChainError is returned by a Limiter created by Chain if one of its links
+// rejected a request. It wraps the link's error, so that errors.Is and
+// errors.As see, e.g., ErrThrottled and the link's *ThrottledError.
+type ChainError struct {
+	// Link is the index of the Limiter that rejected the request, in the
+	// order given to Chain.
+	Link int
+
+	// Limiter is the Limiter that rejected the request.
+	Limiter Limiter
+
+	// Err is the error of the Limiter.
+	Err error
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("link %d: %v", e.Link, e.Err)
+}
+
+func (e *ChainError) Unwrap() error {
+	return e.Err
+}
+
+// chain is the Limiter returned by Chain.
+type chain []Limiter
+
+// Chain returns a Limiter passing every request through all the given
+// Limiters in order, e.g. a global, a per-tenant, and a per-endpoint
+// Throttle, all of which have to grant a token for the request to be served.
+// If a link rejects the request, the tokens already taken from the links
+// before it are refunded, and a *ChainError describing the link is returned.
+// Every link sees the same client; links limiting coarser keys, e.g. the
+// tenant of a "tenant/endpoint" client, derive them using WithKeyNormalizer.
+func Chain(limiters ...Limiter) Limiter {
+	return chain(limiters)
+}
+
+func (c chain) WaitContext(ctx context.Context, client string) error {
+	for i, l := range c {
+		if err := l.WaitContext(ctx, client); err != nil {
+			c.refund(i, client)
+			return &ChainError{Link: i, Limiter: l, Err: err}
+		}
+	}
+	return nil
+}
+
+func (c chain) Allow(client string) bool {
+	for i, l := range c {
+		if !l.Allow(client) {
+			c.refund(i, client)
+			return false
+		}
+	}
+	return true
+}
+
+func (c chain) Refund(client string) {
+	c.refund(len(c), client)
+}
+
+// refund puts the tokens taken for the client from the first n links back.
+func (c chain) refund(n int, client string) {
+	for _, l := range c[:n] {
+		l.Refund(client)
+	}
+}
@@ -0,0 +1,60 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChain(t *testing.T) {
+	tenant := func(client string) string {
+		tenant, _, _ := strings.Cut(client, "/")
+		return tenant
+	}
+	global := New(1*time.Hour, WithBurst(3), WithMaxWait(10*time.Millisecond), WithKeyNormalizer(func(string) string { return "all" }))
+	tenants := New(1*time.Hour, WithBurst(2), WithMaxWait(10*time.Millisecond), WithKeyNormalizer(tenant))
+	endpoints := New(1*time.Hour, WithMaxWait(10*time.Millisecond))
+	limiter := Chain(global, tenants, endpoints)
+
+	ctx := context.Background()
+	if err := limiter.WaitContext(ctx, "acme/search"); err != nil {
+		t.Fatalf("first request: expected no error, got %v", err)
+	}
+	err := limiter.WaitContext(ctx, "acme/search")
+	var chainErr *ChainError
+	if !errors.As(err, &chainErr) || chainErr.Link != 2 || chainErr.Limiter != endpoints {
+		t.Fatalf("expected the endpoint link to reject the request, got %v", err)
+	}
+	var throttledErr *ThrottledError
+	if !errors.Is(err, ErrThrottled) || !errors.As(err, &throttledErr) || throttledErr.Client != "acme/search" {
+		t.Errorf("expected the endpoint's %T to be wrapped, got %v", throttledErr, err)
+	}
+	if global.Remaining("acme/search") != 2 || tenants.Remaining("acme/search") != 1 {
+		t.Errorf("expected the tokens of the earlier links to be refunded, got %d and %d left",
+			global.Remaining("acme/search"), tenants.Remaining("acme/search"))
+	}
+
+	if !limiter.Allow("acme/login") {
+		t.Fatal("second endpoint: expected to be allowed")
+	}
+	if limiter.Allow("acme/export") {
+		t.Fatal("third endpoint: expected to be rejected by the tenant's limit")
+	}
+	if global.Remaining("acme/export") != 1 {
+		t.Errorf("expected the global token to be refunded, got %d left", global.Remaining("acme/export"))
+	}
+	if !limiter.Allow("other/search") {
+		t.Fatal("other tenant: expected to be allowed")
+	}
+	err = limiter.WaitContext(ctx, "third/search")
+	if !errors.As(err, &chainErr) || chainErr.Link != 0 || !strings.HasPrefix(err.Error(), "link 0: ") {
+		t.Errorf("expected the global link to reject the request, got %v", err)
+	}
+
+	limiter.Refund("other/search")
+	if global.Remaining("other/search") != 1 || tenants.Remaining("other/search") != 2 || endpoints.Remaining("other/search") != 1 {
+		t.Errorf("expected a token to be refunded to every link")
+	}
+}
@@ -0,0 +1,50 @@
+// Package chimw adapts the middleware of a throttle.Throttle to the chi
+// router, so that it can be used for the whole router or for single routes:
+//
+//	r := chi.NewRouter()
+//	r.Use(chimw.Limit(api, nil))
+//	r.With(chimw.Limit(login, chimw.ByRoute(nil))).Post("/login", handleLogin)
+package chimw
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/patrickbucher/throttle"
+)
+
+// Limit returns a chi middleware throttling requests using t.Middleware,
+// keyed by keyFn. If keyFn is nil, requests are keyed by the host part of
+// their remote address.
+func Limit(t *throttle.Throttle, keyFn func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return t.Middleware(next, keyFn)
+	}
+}
+
+// ByRoute keys requests by the key derived using keyFn and the pattern of the
+// chi route matched, e.g. "/users/{id}", so that a client gets a bucket per
+// route rather than one for all routes sharing the Throttle. If keyFn is nil,
+// requests are keyed by the host part of their remote address.
+func ByRoute(keyFn func(*http.Request) string) func(*http.Request) string {
+	if keyFn == nil {
+		keyFn = remoteHost
+	}
+	return func(r *http.Request) string {
+		key := keyFn(r)
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			return key + " " + rctx.RoutePattern()
+		}
+		return key
+	}
+}
+
+// remoteHost returns the host part of the request's remote address.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,41 @@
+package chimw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/patrickbucher/throttle"
+)
+
+func TestLimit(t *testing.T) {
+	limiter := throttle.New(1*time.Hour, throttle.WithMaxWait(time.Millisecond))
+	defer limiter.Close()
+	r := chi.NewRouter()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	r.With(Limit(limiter, ByRoute(nil))).Get("/users/{id}", ok)
+	r.With(Limit(limiter, ByRoute(nil))).Get("/posts/{id}", ok)
+	r.Get("/health", ok)
+
+	for _, testCase := range []struct {
+		path   string
+		status int
+	}{
+		{"/users/1", http.StatusOK},
+		{"/users/2", http.StatusTooManyRequests},
+		{"/posts/1", http.StatusOK},
+		{"/health", http.StatusOK},
+		{"/health", http.StatusOK},
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, testCase.path, nil))
+		if rec.Code != testCase.status {
+			t.Errorf("%s: expected status %d, got %d", testCase.path, testCase.status, rec.Code)
+		}
+	}
+	if stats := limiter.Stats("192.0.2.1 /users/{id}"); stats.Allowed != 1 || stats.Rejected != 1 {
+		t.Errorf("expected requests keyed by route pattern, got %+v", stats)
+	}
+}
@@ -0,0 +1,73 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the time to a Throttle, so that the time can be controlled
+// in tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After waits for the duration to elapse and then sends the current time
+	// on the returned channel.
+	After(d time.Duration) <-chan time.Time
+
+	// Sleep pauses the current goroutine for at least the duration d.
+	Sleep(d time.Duration)
+}
+
+// WithClock makes the Throttle use the given Clock instead of the system's
+// clock. Stores other than the default one might use their own clock.
+func WithClock(clock Clock) Option {
+	return func(t *Throttle) {
+		t.clock = clock
+	}
+}
+
+// realClock is the Clock used by default, which uses the system's clock.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// timers are the stopped timers of the system's clock ready to be reused, so
+// that waiting does not allocate a new timer every time.
+var timers sync.Pool
+
+// after works like Clock.After, but takes a pooled timer if the system's clock
+// is used, unless disabled using WithTimerPool. The timer returned, which may
+// be nil, must be handed to stopTimer once the caller is no longer interested
+// in the channel.
+func (t *Throttle) after(d time.Duration) (<-chan time.Time, *time.Timer) {
+	if _, ok := t.clock.(realClock); !ok || t.unpooled {
+		return t.clock.After(d), nil
+	}
+	timer, _ := timers.Get().(*time.Timer)
+	if timer == nil {
+		timer = time.NewTimer(d)
+	} else {
+		timer.Reset(d)
+	}
+	return timer.C, timer
+}
+
+// stopTimer stops a timer returned by after and puts it back into the pool.
+// Since Go 1.23, a stopped timer never delivers a stale time after Reset.
+func stopTimer(timer *time.Timer) {
+	if timer != nil {
+		timer.Stop()
+		timers.Put(timer)
+	}
+}
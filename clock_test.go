@@ -0,0 +1,103 @@
+package throttle
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose time only moves when set explicitly.
+type fakeClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	due time.Time
+	c   chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2021, 2, 22, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	waiter := fakeWaiter{due: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		waiter.c <- c.now
+	} else {
+		c.waiters = append(c.waiters, waiter)
+	}
+	return waiter.c
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+// Advance moves the time forward by d and fires all waiters that are due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, waiter := range c.waiters {
+		if waiter.due.After(c.now) {
+			pending = append(pending, waiter)
+		} else {
+			waiter.c <- c.now
+		}
+	}
+	c.waiters = pending
+}
+
+// Waiters returns the number of waiters that are not due yet.
+func (c *fakeClock) Waiters() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.waiters)
+}
+
+func TestFakeClock(t *testing.T) {
+	for _, testCase := range testCases {
+		clock := newFakeClock()
+		throttle := New(testCase.AllowedRate, WithClock(clock))
+		results := make(chan error, testCase.TotalRequests)
+
+		for i := 0; i < testCase.TotalRequests; i++ {
+			if i > 0 {
+				clock.Advance(testCase.ProgressiveRequestPause)
+			}
+			go func() {
+				results <- throttle.Wait("alice")
+			}()
+			// the request either returns right away or waits for its token
+			for len(results)+clock.Waiters() < i+1 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+		clock.Advance(testCase.AllowedRate)
+
+		var ok, fail int
+		for i := 0; i < testCase.TotalRequests; i++ {
+			if err := <-results; err == nil {
+				ok++
+			} else {
+				fail++
+			}
+		}
+		if ok != testCase.ExpectedRequestsOK || fail != testCase.ExpectedRequestsFail {
+			t.Errorf("ok/fail: expected %d/%d, got %d/%d",
+				testCase.ExpectedRequestsOK, testCase.ExpectedRequestsFail, ok, fail)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Command throttleproxy is a reverse proxy that throttles the requests it
+// passes on to an upstream server, e.g. to run as a rate-limiting sidecar:
+//
+//	throttleproxy -upstream http://localhost:8000 -config throttle.json \
+//		-route /login=login -route "POST /api/=writes"
+//
+// The Throttles are described in the configuration file read by
+// throttle.LoadConfig, which also sets how requests are keyed, e.g. by IP
+// address. Every -route passes the requests matching an http.ServeMux pattern
+// through the named Throttle. The other requests go through the Throttle
+// named "default", if there is one, or are not throttled at all. If -watch is
+// set, changes of the rates, bursts, and max_waits in the configuration file
+// are applied without a restart, checking the file at that interval.
+//
+// If -metrics is set, the Prometheus metrics of all Throttles are served on
+// that address under /metrics, labelled by the name of the Throttle, and the
+// throttle.AdminHandler of every Throttle under /admin/<name>/.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/patrickbucher/throttle"
+	"github.com/patrickbucher/throttle/promthrottle"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// defaultThrottle is the name of the Throttle used for requests not matching
+// any route.
+const defaultThrottle = "default"
+
+// route passes the requests matching pattern through the Throttle named.
+type route struct {
+	pattern string
+	name    string
+}
+
+// routes is the value of the repeatable -route flag.
+type routes []route
+
+func (r *routes) String() string {
+	s := make([]string, len(*r))
+	for i, route := range *r {
+		s[i] = route.pattern + "=" + route.name
+	}
+	return strings.Join(s, ",")
+}
+
+func (r *routes) Set(value string) error {
+	i := strings.LastIndexByte(value, '=')
+	if i <= 0 || i == len(value)-1 {
+		return fmt.Errorf("route %q: expected pattern=name", value)
+	}
+	*r = append(*r, route{pattern: value[:i], name: value[i+1:]})
+	return nil
+}
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to serve the proxy on")
+	upstream := flag.String("upstream", "", "URL of the upstream server")
+	config := flag.String("config", "", "path of the JSON file configuring the throttles")
+	metricsAddr := flag.String("metrics", "", "address to serve metrics and admin endpoints on")
+	watch := flag.Duration("watch", 0, "interval at which to check the configuration file for changes")
+	var routes routes
+	flag.Var(&routes, "route", "pattern=name passing matching requests through the named throttle (repeatable)")
+	flag.Parse()
+	if *upstream == "" || *config == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	target, err := url.Parse(*upstream)
+	if err != nil {
+		log.Fatalf("upstream: %v", err)
+	}
+	limits, err := throttle.LoadConfig(*config)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	defer limits.Close()
+	if *watch > 0 {
+		limits.Watch(*config, *watch, func(err error) {
+			log.Printf("config: %v", err)
+		})
+	}
+	handler, err := newHandler(limits, httputil.NewSingleHostReverseProxy(target), routes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	servers := []*http.Server{{Addr: *addr, Handler: handler}}
+	if *metricsAddr != "" {
+		servers = append(servers, &http.Server{Addr: *metricsAddr, Handler: newMetricsHandler(limits)})
+	}
+	for _, server := range servers {
+		go func() {
+			if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, server := range servers {
+		server.Shutdown(ctx)
+	}
+}
+
+// newHandler returns a handler passing the requests on to proxy through the
+// Throttles of their routes.
+func newHandler(limits *throttle.Limits, proxy http.Handler, routes routes) (handler http.Handler, err error) {
+	defer func() {
+		// ServeMux panics on invalid or conflicting patterns
+		if r := recover(); r != nil {
+			err = fmt.Errorf("route: %v", r)
+		}
+	}()
+	mux := http.NewServeMux()
+	fallback := false
+	for _, route := range routes {
+		if limits.Throttle(route.name) == nil {
+			return nil, fmt.Errorf("route %s: no throttle named %q configured", route.pattern, route.name)
+		}
+		mux.Handle(route.pattern, limits.Middleware(route.name, proxy))
+		fallback = fallback || route.pattern == "/"
+	}
+	switch {
+	case fallback:
+	case limits.Throttle(defaultThrottle) != nil:
+		mux.Handle("/", limits.Middleware(defaultThrottle, proxy))
+	default:
+		mux.Handle("/", proxy)
+	}
+	return mux, nil
+}
+
+// newMetricsHandler returns a handler serving the metrics and admin endpoints
+// of all Throttles.
+func newMetricsHandler(limits *throttle.Limits) http.Handler {
+	registry := prometheus.NewRegistry()
+	mux := http.NewServeMux()
+	for _, name := range limits.Names() {
+		t := limits.Throttle(name)
+		registry.MustRegister(promthrottle.NewCollector(t, prometheus.Labels{"throttle": name}))
+		prefix := "/admin/" + name
+		mux.Handle(prefix+"/", http.StripPrefix(prefix, throttle.AdminHandler(t)))
+	}
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	return mux
+}
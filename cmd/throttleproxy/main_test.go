@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/patrickbucher/throttle"
+)
+
+const testConfig = `{
+  "throttles": {
+    "login": {"rate": "1m", "burst": 1, "max_wait": "1ms"},
+    "default": {"rate": "1m", "burst": 2, "max_wait": "1ms"}
+  }
+}`
+
+func TestRoutesSet(t *testing.T) {
+	var r routes
+	for _, value := range []string{"/login=login", "POST /api/=writes"} {
+		if err := r.Set(value); err != nil {
+			t.Fatalf("%s: expected no error, got %v", value, err)
+		}
+	}
+	if s := r.String(); s != "/login=login,POST /api/=writes" {
+		t.Errorf("expected routes to be kept, got %s", s)
+	}
+	for _, value := range []string{"/login", "=login", "/login="} {
+		if err := r.Set(value); err == nil {
+			t.Errorf("%s: expected an error", value)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	limits, err := throttle.ParseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer limits.Close()
+	proxy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler, err := newHandler(limits, proxy, routes{{pattern: "/login", name: "login"}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		expected []int
+	}{
+		{"/login", []int{http.StatusOK, http.StatusTooManyRequests}},
+		{"/other", []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}},
+	}
+	for _, test := range tests {
+		for i, status := range test.expected {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+			if rec.Code != status {
+				t.Errorf("%s, request %d: expected status %d, got %d", test.path, i, status, rec.Code)
+			}
+		}
+	}
+
+	if _, err := newHandler(limits, proxy, routes{{pattern: "/x", name: "unknown"}}); err == nil {
+		t.Errorf("unknown throttle: expected an error")
+	}
+	if _, err := newHandler(limits, proxy, routes{{pattern: "/x", name: "login"}, {pattern: "/x", name: "login"}}); err == nil {
+		t.Errorf("conflicting routes: expected an error")
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	limits, err := throttle.ParseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer limits.Close()
+	handler := newMetricsHandler(limits)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), `throttle_requests_allowed_total{throttle="login"}`) {
+		t.Errorf("metrics: expected counters of login, got %s", rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/login/clients", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("admin: expected status 200, got %d", rec.Code)
+	}
+}
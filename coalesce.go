@@ -0,0 +1,76 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+)
+
+// Coalescer lets concurrent identical requests of a client share a single
+// token and result, so that a thundering herd of requests for the same thing
+// takes one token instead of one per request, much like
+// golang.org/x/sync/singleflight:
+//
+//	profiles := throttle.NewCoalescer[*Profile](t)
+//	profile, err := profiles.Do(ctx, client, "profile/"+id, func(ctx context.Context) (*Profile, error) {
+//		return fetchProfile(ctx, id)
+//	})
+//
+// A Coalescer is safe for concurrent use.
+type Coalescer[T any] struct {
+	throttle *Throttle
+	mutex    sync.Mutex
+	calls    map[string]*call[T]
+}
+
+// call is an operation in progress, whose result is shared by all callers.
+type call[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+
+	// shared is the number of callers waiting for the first one's result.
+	shared int
+}
+
+// NewCoalescer creates a Coalescer taking the tokens from t.
+func NewCoalescer[T any](t *Throttle) *Coalescer[T] {
+	return &Coalescer[T]{throttle: t, calls: make(map[string]*call[T])}
+}
+
+// Do waits for a token of the client and calls f, unless a call of f for the
+// same client and operation is in progress already, in which case Do waits
+// for its result instead of taking a token. The first caller's context is
+// passed to f, and if no token is acquired, the Throttle's error is shared by
+// all callers waiting for the same operation. If ctx is done before the
+// result is available, Do returns the context's error, without cancelling the
+// operation.
+func (c *Coalescer[T]) Do(ctx context.Context, client, op string, f func(ctx context.Context) (T, error)) (T, error) {
+	key := c.throttle.key(client) + "\x00" + op
+	c.mutex.Lock()
+	if cl, ok := c.calls[key]; ok {
+		cl.shared++
+		c.mutex.Unlock()
+		select {
+		case <-cl.done:
+			return cl.value, cl.err
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+	cl := &call[T]{done: make(chan struct{})}
+	c.calls[key] = cl
+	c.mutex.Unlock()
+
+	defer func() {
+		c.mutex.Lock()
+		delete(c.calls, key)
+		c.mutex.Unlock()
+		close(cl.done)
+	}()
+	if cl.err = c.throttle.WaitContext(ctx, client); cl.err != nil {
+		return cl.value, cl.err
+	}
+	cl.value, cl.err = f(ctx)
+	return cl.value, cl.err
+}
@@ -0,0 +1,57 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCoalescer(t *testing.T) {
+	throttle := New(1*time.Second, WithMaxWait(10*time.Millisecond))
+	coalescer := NewCoalescer[int](throttle)
+	release := make(chan struct{})
+	calls := 0
+	fetch := func(ctx context.Context) (int, error) {
+		calls++
+		<-release
+		return 42, nil
+	}
+
+	results := make(chan int, 4)
+	for i := 0; i < 4; i++ {
+		go func() {
+			value, err := coalescer.Do(context.Background(), "alice", "answer", fetch)
+			if err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			results <- value
+		}()
+	}
+	for shared := 0; shared < 3; {
+		time.Sleep(time.Millisecond)
+		coalescer.mutex.Lock()
+		if cl, ok := coalescer.calls["alice\x00answer"]; ok {
+			shared = cl.shared
+		}
+		coalescer.mutex.Unlock()
+	}
+	close(release)
+	for i := 0; i < 4; i++ {
+		if value := <-results; value != 42 {
+			t.Errorf("expected shared result 42, got %d", value)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected a single call, got %d", calls)
+	}
+	if stats := throttle.Stats("alice"); stats.Allowed != 1 {
+		t.Errorf("expected a single token to be taken, got %d", stats.Allowed)
+	}
+
+	// the operation is over, so the next call takes a token again
+	_, err := coalescer.Do(context.Background(), "alice", "answer", fetch)
+	if !errors.Is(err, ErrThrottled) {
+		t.Errorf("next call: expected %v, got %v", ErrThrottled, err)
+	}
+}
@@ -0,0 +1,65 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// coldStart tracks since when the clients have been seen.
+type coldStart struct {
+	mutex     sync.Mutex
+	delay     time.Duration
+	clients   map[string]*activity
+	lastSweep time.Time
+}
+
+// WithColdStart makes new clients wait for the given delay before their first
+// token, instead of being served right away, e.g. for signups or password
+// resets, where the instant first token would defeat the purpose of the
+// limit. The delay starts with the client's first request, even if that
+// request is rejected; so a client whose requests are rejected because of the
+// maximum waiting time may come back once the delay has passed. A client idle
+// for long enough that its bucket is full again, and at least for the delay,
+// counts as new again. The delay does not apply to WaitAll.
+func WithColdStart(d time.Duration) Option {
+	return func(t *Throttle) {
+		if d <= 0 {
+			return
+		}
+		t.coldStart = &coldStart{delay: d, clients: make(map[string]*activity)}
+	}
+}
+
+// wait returns how much longer the client has to wait until its cold start
+// delay has passed, and records the client as seen. A client is forgotten
+// once it has not been seen for forget.
+func (c *coldStart) wait(client string, now time.Time, forget time.Duration) time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	forget = max(forget, c.delay)
+	if now.Sub(c.lastSweep) >= forget {
+		for k, a := range c.clients {
+			if now.Sub(a.last) >= forget {
+				delete(c.clients, k)
+			}
+		}
+		c.lastSweep = now
+	}
+	a, ok := c.clients[client]
+	if !ok || now.Sub(a.last) >= forget {
+		a = &activity{first: now}
+		c.clients[client] = a
+	}
+	a.last = now
+	return max(a.first.Add(c.delay).Sub(now), 0)
+}
+
+// cold returns how much longer the client has to wait until its cold start
+// delay has passed.
+func (t *Throttle) cold(client string) time.Duration {
+	if t.coldStart == nil {
+		return 0
+	}
+	full := t.rate(client) * time.Duration(t.clientBurst(client))
+	return t.coldStart.wait(client, t.clock.Now(), full)
+}
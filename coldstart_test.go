@@ -0,0 +1,64 @@
+package throttle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestColdStart(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Second, WithBurst(2), WithColdStart(10*time.Second), WithClock(clock))
+	defer throttle.Close()
+
+	var throttledErr *ThrottledError
+	if err := throttle.Wait("alice"); !errors.As(err, &throttledErr) || throttledErr.RetryAfter != 10*time.Second {
+		t.Fatalf("first request of new client: expected to be told to retry after 10s, got %v", err)
+	}
+	clock.Advance(5 * time.Second)
+	if throttle.Allow("alice") {
+		t.Error("request allowed before the cold start delay passed")
+	}
+
+	// the delay counts from the first request
+	clock.Advance(5 * time.Second)
+	if !throttle.Allow("alice") || !throttle.Allow("alice") {
+		t.Error("requests throttled after the cold start delay")
+	}
+
+	// a client seen recently is not cold again
+	clock.Advance(5 * time.Second)
+	if !throttle.Allow("alice") {
+		t.Error("request of known client throttled")
+	}
+
+	// an idle client is new again
+	clock.Advance(1 * time.Minute)
+	if throttle.Allow("alice") {
+		t.Error("request of idle client allowed right away")
+	}
+}
+
+func TestColdStartWait(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Second, WithColdStart(5*time.Second), WithMaxWait(1*time.Minute), WithClock(clock))
+	defer throttle.Close()
+
+	done := make(chan error)
+	go func() {
+		done <- throttle.Wait("alice")
+	}()
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(4 * time.Second)
+	select {
+	case err := <-done:
+		t.Fatalf("request returned %v before the cold start delay passed", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(1 * time.Second)
+	if err := <-done; err != nil {
+		t.Errorf("request failed after the cold start delay: %v", err)
+	}
+}
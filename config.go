@@ -0,0 +1,303 @@
+package throttle
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Limits is a set of named Throttles created from a configuration file using
+// LoadConfig, together with the keys their requests are derived from.
+type Limits struct {
+	throttles map[string]*Throttle
+	keyFns    map[string]func(*http.Request) string
+	mutex     sync.Mutex
+	configs   map[string]throttleConfig
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// config is the content of a configuration file.
+type config struct {
+	Throttles map[string]throttleConfig `json:"throttles"`
+}
+
+// throttleConfig describes a single named Throttle.
+type throttleConfig struct {
+	Rate        duration `json:"rate"`
+	Burst       int      `json:"burst"`
+	MaxWait     duration `json:"max_wait"`
+	IdleTimeout duration `json:"idle_timeout"`
+	Algorithm   string   `json:"algorithm"`
+	Key         string   `json:"key"`
+
+	TrustedProxies []string `json:"trusted_proxies"`
+	IPv4Prefix     int      `json:"ipv4_prefix"`
+	IPv6Prefix     int      `json:"ipv6_prefix"`
+}
+
+// duration is a time.Duration written like "1.5s" in a configuration file.
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(parsed)
+	return nil
+}
+
+// LoadConfig creates the Throttles described in the JSON file at the given
+// path, which looks like this:
+//
+//	{
+//	  "throttles": {
+//	    "login": {"rate": "10s", "burst": 3, "key": "ip", "trusted_proxies": ["10.0.0.0/8"]},
+//	    "search": {"rate": "100ms", "burst": 20, "key": "header:X-API-Key", "algorithm": "gcra"}
+//	  }
+//	}
+//
+// Every Throttle has a rate, and optionally a burst, a max_wait, and an
+// idle_timeout; durations are written like "1.5s". The algorithm is one of
+// "token-bucket" (the default), "gcra", "sliding-window" and "fixed-window"
+// (burst requests per burst rates), or "leaky-bucket" (queueing up to burst
+// requests). The key is parsed by ParseKey, and defaults to "remote"; IP
+// addresses are derived as configured by trusted_proxies, ipv4_prefix, and
+// ipv6_prefix (see IPKey). The options given are applied to all Throttles.
+// Changes to the file are applied while running using Watch.
+func LoadConfig(path string, opts ...Option) (*Limits, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(data, opts...)
+}
+
+// ParseConfig works like LoadConfig, but parses the given configuration.
+func ParseConfig(data []byte, opts ...Option) (*Limits, error) {
+	var c config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	limits := Limits{
+		throttles: make(map[string]*Throttle, len(c.Throttles)),
+		keyFns:    make(map[string]func(*http.Request) string, len(c.Throttles)),
+		configs:   c.Throttles,
+		done:      make(chan struct{}),
+	}
+	for name, tc := range c.Throttles {
+		keyFn, err := tc.keyFn()
+		if err != nil {
+			limits.Close()
+			return nil, fmt.Errorf("throttle %q: %w", name, err)
+		}
+		t, err := tc.throttle(opts)
+		if err != nil {
+			limits.Close()
+			return nil, fmt.Errorf("throttle %q: %w", name, err)
+		}
+		limits.throttles[name] = t
+		limits.keyFns[name] = keyFn
+	}
+	return &limits, nil
+}
+
+// throttle creates the Throttle described.
+func (tc throttleConfig) throttle(opts []Option) (*Throttle, error) {
+	rate := time.Duration(tc.Rate)
+	if rate <= 0 {
+		return nil, fmt.Errorf("rate must be positive")
+	}
+	burst := tc.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	opts = append([]Option{
+		WithBurst(burst),
+		WithMaxWait(time.Duration(tc.MaxWait)),
+		WithIdleTimeout(time.Duration(tc.IdleTimeout)),
+	}, opts...)
+	switch tc.Algorithm {
+	case "", "token-bucket":
+		return New(rate, opts...), nil
+	case "gcra":
+		return New(rate, append(opts, WithGCRA())...), nil
+	case "sliding-window":
+		return NewSlidingWindow(burst, rate*time.Duration(burst), opts...), nil
+	case "fixed-window":
+		return NewFixedWindow(burst, rate*time.Duration(burst), opts...), nil
+	case "leaky-bucket":
+		return NewLeakyBucket(rate, burst, opts...), nil
+	}
+	return nil, fmt.Errorf("unknown algorithm %q", tc.Algorithm)
+}
+
+// reconfigure applies the rate, burst, and max_wait of the configuration to
+// the Throttle created from it, all at once.
+func (tc throttleConfig) reconfigure(t *Throttle) {
+	rate, burst := time.Duration(tc.Rate), max(tc.Burst, 1)
+	t.rateMutex.Lock()
+	t.requestRate = rate
+	t.maxWait = time.Duration(tc.MaxWait)
+	switch {
+	case t.queued:
+		// the burst of a leaky bucket is the capacity of its queue
+		t.queueCapacity = burst
+	case !t.pacing:
+		t.burst = burst
+	}
+	t.rateMutex.Unlock()
+	t.debug("limit reloaded", slog.Duration("rate", rate), slog.Int("burst", burst), slog.Duration("max_wait", time.Duration(tc.MaxWait)))
+}
+
+// reloadable reports whether the Throttle created from the configuration can
+// be changed to the other one by reconfigure.
+func (tc throttleConfig) reloadable(other throttleConfig) bool {
+	return tc.Algorithm == other.Algorithm && tc.Key == other.Key &&
+		tc.IdleTimeout == other.IdleTimeout &&
+		slices.Equal(tc.TrustedProxies, other.TrustedProxies) &&
+		tc.IPv4Prefix == other.IPv4Prefix && tc.IPv6Prefix == other.IPv6Prefix
+}
+
+// keyFn returns the function deriving the key described from a request.
+func (tc throttleConfig) keyFn() (func(*http.Request) string, error) {
+	ip := IPKeyConfig{IPv4Prefix: tc.IPv4Prefix, IPv6Prefix: tc.IPv6Prefix}
+	for _, proxy := range tc.TrustedProxies {
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return nil, err
+		}
+		ip.TrustedProxies = append(ip.TrustedProxies, prefix)
+	}
+	return parseKey(tc.Key, ip)
+}
+
+// Throttle returns the Throttle of the given name, or nil if there is none.
+func (l *Limits) Throttle(name string) *Throttle {
+	return l.throttles[name]
+}
+
+// Names returns the names of all Throttles.
+func (l *Limits) Names() []string {
+	names := make([]string, 0, len(l.throttles))
+	for name := range l.throttles {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Middleware returns the Middleware of the Throttle of the given name, keying
+// requests as configured. It panics if there is no Throttle of that name, for
+// this is a programming error.
+func (l *Limits) Middleware(name string, next http.Handler) http.Handler {
+	t, ok := l.throttles[name]
+	if !ok {
+		panic(fmt.Sprintf("throttle: no throttle named %q configured", name))
+	}
+	return t.Middleware(next, l.keyFns[name])
+}
+
+// Reload applies the changed rates, bursts, and max_waits of the given
+// configuration to the live Throttles, e.g. to tune limits without a restart.
+// Either all changes are applied, or, if the configuration is invalid or
+// changes anything else, such as the set of Throttles, an algorithm, or a key,
+// none are and an error is returned. The rate, burst, and max_wait of a
+// Throttle change together, so that no request sees a mix of old and new ones.
+func (l *Limits) Reload(data []byte) error {
+	var c config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return err
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for name := range l.configs {
+		if _, ok := c.Throttles[name]; !ok {
+			return fmt.Errorf("throttle %q: cannot be removed without a restart", name)
+		}
+	}
+	for name, tc := range c.Throttles {
+		old, ok := l.configs[name]
+		switch {
+		case !ok:
+			return fmt.Errorf("throttle %q: cannot be added without a restart", name)
+		case tc.Rate <= 0:
+			return fmt.Errorf("throttle %q: rate must be positive", name)
+		case !old.reloadable(tc):
+			return fmt.Errorf("throttle %q: only rate, burst, and max_wait can be changed without a restart", name)
+		}
+	}
+	for name, tc := range c.Throttles {
+		tc.reconfigure(l.throttles[name])
+	}
+	l.configs = c.Throttles
+	return nil
+}
+
+// DefaultWatchInterval is the interval at which Watch checks the configuration
+// file if no positive interval is given.
+const DefaultWatchInterval = 10 * time.Second
+
+// Watch checks the configuration file at the given path for changes every
+// interval, and reloads it using Reload whenever it has been modified, until
+// the Limits are closed. Errors reading or reloading the file are passed to
+// onError, if not nil; the Throttles keep their limits until the file is
+// fixed. If interval is not positive, DefaultWatchInterval is used.
+func (l *Limits) Watch(path string, interval time.Duration, onError func(error)) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	report := func(err error) {
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+	info, err := os.Stat(path)
+	report(err)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-l.done:
+				return
+			}
+			latest, err := os.Stat(path)
+			if err != nil {
+				report(err)
+				continue
+			}
+			if info != nil && latest.ModTime().Equal(info.ModTime()) && latest.Size() == info.Size() {
+				continue
+			}
+			info = latest
+			data, err := os.ReadFile(path)
+			if err == nil {
+				err = l.Reload(data)
+			}
+			report(err)
+		}
+	}()
+}
+
+// Close closes all Throttles, and stops watching the configuration file.
+func (l *Limits) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+	for _, t := range l.throttles {
+		t.Close()
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package throttle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfig = `{
+  "throttles": {
+    "login": {"rate": "10s", "burst": 3, "key": "ip", "trusted_proxies": ["10.0.0.0/8"]},
+    "search": {"rate": "100ms", "burst": 2, "max_wait": "1ms", "key": "header:X-API-Key", "algorithm": "gcra"}
+  }
+}`
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "throttle.json")
+	if err := os.WriteFile(path, []byte(testConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	limits, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer limits.Close()
+
+	names := limits.Names()
+	sort.Strings(names)
+	if strings.Join(names, ",") != "login,search" {
+		t.Errorf("expected throttles login and search, got %v", names)
+	}
+	login := limits.Throttle("login")
+	if login.Rate() != 10*time.Second || login.burst != 3 {
+		t.Errorf("login: expected rate 10s and burst 3, got %v and %d", login.Rate(), login.burst)
+	}
+	if _, ok := limits.Throttle("search").store.(*gcraStore); !ok {
+		t.Errorf("search: expected GCRA store, got %T", limits.Throttle("search").store)
+	}
+
+	handler := limits.Middleware("search", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	expected := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}
+	for i, status := range expected {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", "alice")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != status {
+			t.Errorf("request %d: expected status %d, got %d", i, status, rec.Code)
+		}
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	configs := []string{
+		`{"throttles": {"x": {"rate": "fast"}}}`,
+		`{"throttles": {"x": {"rate": "0s"}}}`,
+		`{"throttles": {"x": {"rate": "1s", "algorithm": "magic"}}}`,
+		`{"throttles": {"x": {"rate": "1s", "key": "jwt:sub"}}}`,
+		`{"throttles": {"x": {"rate": "1s", "key": "cert:fingerprint"}}}`,
+		`{"throttles": {"x": {"rate": "1s", "key": "ip", "trusted_proxies": ["nonsense"]}}}`,
+	}
+	for _, config := range configs {
+		if _, err := ParseConfig([]byte(config)); err == nil {
+			t.Errorf("%s: expected an error", config)
+		}
+	}
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("missing file: expected an error")
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	limits, err := ParseConfig([]byte(`{"throttles": {
+		"login": {"rate": "10s", "burst": 3},
+		"queue": {"rate": "1s", "burst": 2, "algorithm": "leaky-bucket"}
+	}}`))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer limits.Close()
+
+	invalid := []string{
+		`{"throttles": {"login": {"rate": "5s"}}}`,
+		`{"throttles": {"login": {"rate": "5s"}, "queue": {"rate": "1s", "algorithm": "leaky-bucket"}, "search": {"rate": "1s"}}}`,
+		`{"throttles": {"login": {"rate": "0s"}, "queue": {"rate": "1s", "algorithm": "leaky-bucket"}}}`,
+		`{"throttles": {"login": {"rate": "5s", "key": "ip"}, "queue": {"rate": "1s", "algorithm": "leaky-bucket"}}}`,
+		`{"throttles": {"login": {"rate": "5s"}, "queue": {"rate": "1s"}}}`,
+	}
+	for _, config := range invalid {
+		if err := limits.Reload([]byte(config)); err == nil {
+			t.Errorf("%s: expected an error", config)
+		}
+	}
+	if login := limits.Throttle("login"); login.Rate() != 10*time.Second || login.clientBurst("alice") != 3 {
+		t.Errorf("login: expected rate 10s and burst 3 to be kept, got %v and %d", login.Rate(), login.clientBurst("alice"))
+	}
+
+	err = limits.Reload([]byte(`{"throttles": {
+		"login": {"rate": "5s", "burst": 6, "max_wait": "1s"},
+		"queue": {"rate": "2s", "burst": 4, "algorithm": "leaky-bucket"}
+	}}`))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	login := limits.Throttle("login")
+	if login.Rate() != 5*time.Second || login.clientBurst("alice") != 6 || login.timeout("alice", 1) != time.Second {
+		t.Errorf("login: expected rate 5s, burst 6, and max wait 1s, got %v, %d, and %v",
+			login.Rate(), login.clientBurst("alice"), login.timeout("alice", 1))
+	}
+	queue := limits.Throttle("queue")
+	if queue.clientBurst("alice") != 1 || queue.timeout("alice", 1) != 8*time.Second {
+		t.Errorf("queue: expected burst 1 and 4 requests of 2s queued, got %d and %v",
+			queue.clientBurst("alice"), queue.timeout("alice", 1))
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "throttle.json")
+	if err := os.WriteFile(path, []byte(testConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	limits, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer limits.Close()
+	errs := make(chan error, 16)
+	limits.Watch(path, time.Millisecond, func(err error) {
+		errs <- err
+	})
+
+	write := func(config string, modified time.Time) {
+		if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"throttles": {"login": {"rate": "10s"}}}`, time.Now().Add(time.Minute))
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "search") {
+			t.Errorf("expected an error about removing search, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an error reloading an invalid configuration")
+	}
+
+	write(strings.Replace(testConfig, `"10s"`, `"20s"`, 1), time.Now().Add(2*time.Minute))
+	deadline := time.Now().Add(5 * time.Second)
+	for limits.Throttle("login").Rate() != 20*time.Second {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the rate to be reloaded, got %v", limits.Throttle("login").Rate())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWatchConfigDefaultInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "throttle.json")
+	if err := os.WriteFile(path, []byte(testConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	limits, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// a watcher without a positive interval must not bring the process down
+	limits.Watch(path, 0, nil)
+	limits.Watch(path, -time.Second, nil)
+	time.Sleep(10 * time.Millisecond)
+	limits.Close()
+}
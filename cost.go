@@ -0,0 +1,22 @@
+package throttle
+
+import "net/http"
+
+// WithCostFunc makes Middleware and Transport charge every request the number
+// of tokens returned by f, e.g. depending on the size of its payload or the
+// complexity of its query, rather than one token per request. By default, or
+// if f is nil, every request costs one token. Requests costing less than one
+// token are not throttled at all.
+func WithCostFunc(f func(*http.Request) int) Option {
+	return func(t *Throttle) {
+		t.costFn = f
+	}
+}
+
+// cost returns the number of tokens the request costs.
+func (t *Throttle) cost(r *http.Request) int {
+	if t.costFn == nil {
+		return 1
+	}
+	return t.costFn(r)
+}
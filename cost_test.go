@@ -0,0 +1,39 @@
+package throttle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCostFunc(t *testing.T) {
+	cost := func(r *http.Request) int {
+		n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+		return n
+	}
+	throttle := New(1*time.Second, WithBurst(5), WithMaxWait(10*time.Millisecond), WithCostFunc(cost), WithClock(newFakeClock()))
+	handler := throttle.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), nil)
+
+	tests := []struct {
+		n      int
+		status int
+	}{
+		{3, http.StatusOK},
+		{3, http.StatusTooManyRequests},
+		{2, http.StatusOK},
+		{0, http.StatusOK},
+		{6, http.StatusTooManyRequests},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/?n="+strconv.Itoa(test.n), nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("request %d costing %d: expected status %d, got %d", i, test.n, test.status, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrDraining is returned for requests to a Throttle being drained that would
+// have to wait for their tokens.
+var ErrDraining = errors.New("throttle draining")
+
+// drainInterval is how often Drain checks whether requests are still waiting.
+const drainInterval = 10 * time.Millisecond
+
+// Drain prepares the Throttle for shutdown, e.g. during a rolling restart:
+// requests that would have to wait for their tokens are rejected right away
+// with ErrDraining, while the requests already waiting are served as usual.
+// Requests whose tokens are available right away still pass. Once no request
+// is waiting anymore, or ctx is done, the Throttle is closed, which rejects
+// the requests still waiting with ErrClosed. Drain returns ctx.Err() if
+// requests were still waiting, and nil otherwise.
+func (t *Throttle) Drain(ctx context.Context) error {
+	t.draining.Store(true)
+	defer t.Close()
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+	for t.metrics.waiters.Load() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.done:
+			return nil
+		}
+	}
+	return nil
+}
+
+// Draining reports whether Drain has been called.
+func (t *Throttle) Draining() bool {
+	return t.draining.Load()
+}
@@ -0,0 +1,103 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithMaxWait(1*time.Second), WithClock(clock))
+	throttle.Allow("alice")
+	waited := make(chan error)
+	go func() {
+		waited <- throttle.Wait("alice")
+	}()
+	for clock.Waiters() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	drained := make(chan error)
+	go func() {
+		drained <- throttle.Drain(context.Background())
+	}()
+	for !throttle.Draining() {
+		time.Sleep(time.Millisecond)
+	}
+	if err := throttle.Wait("alice"); !errors.Is(err, ErrDraining) {
+		t.Errorf("new waiter: expected %v, got %v", ErrDraining, err)
+	}
+	if err := throttle.Wait("bob"); err != nil {
+		t.Errorf("request served right away: expected no error, got %v", err)
+	}
+
+	clock.Advance(100 * time.Millisecond)
+	if err := <-waited; err != nil {
+		t.Errorf("waiting request: expected no error, got %v", err)
+	}
+	if err := <-drained; err != nil {
+		t.Errorf("expected the throttle to be drained, got %v", err)
+	}
+	if err := throttle.Wait("carol"); !errors.Is(err, ErrClosed) {
+		t.Errorf("after draining: expected %v, got %v", ErrClosed, err)
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithPriorities(), WithMaxWait(1*time.Second), WithClock(clock))
+	throttle.Allow("alice")
+	waited := make(chan error)
+	go func() {
+		waited <- throttle.Wait("alice")
+	}()
+	for queued(throttle, "alice") < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := throttle.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if err := <-waited; !errors.Is(err, ErrClosed) {
+		t.Errorf("waiting request: expected %v, got %v", ErrClosed, err)
+	}
+}
+
+func TestDrainFairQueue(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(time.Millisecond, WithGlobalLimit(1*time.Second, 1), WithFairQueueing(nil),
+		WithMaxWait(1*time.Hour), WithClock(clock))
+	throttle.Allow("alice")
+	waited := make(chan error)
+	go func() {
+		waited <- throttle.Wait("bob")
+	}()
+	for throttle.Stats("bob").Waiters < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	drained := make(chan error)
+	go func() {
+		drained <- throttle.Drain(context.Background())
+	}()
+	for !throttle.Draining() {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-drained:
+		t.Fatalf("drained with a queued request: %v", err)
+	case <-time.After(5 * drainInterval):
+	}
+
+	clock.Advance(1 * time.Second)
+	if err := <-waited; err != nil {
+		t.Errorf("queued request: expected no error, got %v", err)
+	}
+	if err := <-drained; err != nil {
+		t.Errorf("expected the throttle to be drained, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package throttle
+
+import (
+	"container/heap"
+	"math"
+	"time"
+)
+
+// DropPolicy decides which request is rejected if a request of a client has
+// to wait while the maximum number of requests set by WithMaxWaiters is
+// waiting already.
+type DropPolicy int
+
+const (
+	// DropNewest rejects the request arriving, which suits interactive
+	// workloads, whose callers should rather retry elsewhere than wait.
+	DropNewest DropPolicy = iota
+
+	// DropOldest rejects the request that has been waiting the longest, whose
+	// caller is the most likely to have given up waiting already.
+	DropOldest
+
+	// DropLowest rejects the request that would be served last, i.e. the most
+	// recent one of the lowest priority, if it would be served after the
+	// request arriving; otherwise, the request arriving is rejected. This
+	// suits batch workloads mixed with requests that must go first.
+	DropLowest
+)
+
+// WithDropPolicy sets the policy by which requests are rejected once the
+// maximum number of requests set by WithMaxWaiters is waiting. By default,
+// the request arriving is rejected. The policy applies to the queues of
+// WithPriorities; without them, requests do not line up in a queue, but
+// reserve their tokens upon arrival, so the request arriving is always
+// rejected. A request dropped from the queue is rejected with a
+// *ThrottledError.
+func WithDropPolicy(policy DropPolicy) Option {
+	return func(t *Throttle) {
+		t.dropPolicy = policy
+	}
+}
+
+// dropWaiter makes room for a request of the given priority and rank by
+// rejecting a waiting request of the client according to the drop policy. It
+// reports whether a request has been dropped, in which case the request
+// arriving takes its place among the waiting requests.
+func (t *Throttle) dropWaiter(client string, prio Priority, rank time.Time) bool {
+	if t.dropPolicy == DropNewest {
+		return false
+	}
+	newcomer := &waiter{prio: prio, rank: rank, seq: math.MaxUint64}
+	victim := t.queues.evict(client, newcomer, t.dropPolicy)
+	if victim == nil {
+		return false
+	}
+	rate := t.rate(client)
+	victim.err = &ThrottledError{Client: client, Rate: rate, RetryAfter: rate}
+	t.rejected(client, t.clock.Now())
+	victim.ready <- struct{}{}
+	t.metrics.waiting(client, 1)
+	return true
+}
+
+// evict removes the request to be dropped according to the policy from the
+// client's queue, and returns it. If no request is to be dropped in favour of
+// the newcomer, nil is returned.
+func (q *priorityQueues) evict(client string, newcomer *waiter, policy DropPolicy) *waiter {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	queue, ok := q.clients[client]
+	if !ok || queue.Len() == 0 {
+		return nil
+	}
+	victim := queue.waiters[0]
+	for _, w := range queue.waiters[1:] {
+		switch policy {
+		case DropOldest:
+			if w.seq < victim.seq {
+				victim = w
+			}
+		case DropLowest:
+			if victim.before(w) {
+				victim = w
+			}
+		}
+	}
+	if policy == DropLowest && !newcomer.before(victim) {
+		return nil
+	}
+	heap.Remove(queue, victim.index)
+	return victim
+}
@@ -0,0 +1,50 @@
+package throttle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDropPolicy(t *testing.T) {
+	tests := []struct {
+		policy   DropPolicy
+		arriving Priority
+		dropped  Priority
+	}{
+		{DropNewest, PriorityHigh, PriorityHigh},
+		{DropOldest, PriorityLow, PriorityNormal},
+		{DropLowest, PriorityHigh, PriorityLow},
+		{DropLowest, PriorityLow, PriorityLow},
+	}
+	for _, test := range tests {
+		clock := newFakeClock()
+		throttle := New(100*time.Millisecond, WithPriorities(), WithMaxWaiters(2), WithDropPolicy(test.policy),
+			WithMaxWait(1*time.Second), WithClock(clock))
+		throttle.Allow("alice")
+
+		errs := make(map[Priority]chan error)
+		for i, prio := range []Priority{PriorityNormal, PriorityLow, test.arriving} {
+			if _, ok := errs[prio]; !ok {
+				errs[prio] = make(chan error, 2)
+			}
+			go func(prio Priority) {
+				errs[prio] <- throttle.WaitPriority("alice", prio)
+			}(prio)
+			for i < 2 && queued(throttle, "alice") < i+1 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+		var throttledErr *ThrottledError
+		if err := <-errs[test.dropped]; !errors.As(err, &throttledErr) {
+			t.Errorf("policy %d, arriving %d: expected priority %d to be dropped, got %v", test.policy, test.arriving, test.dropped, err)
+		}
+		if queued(throttle, "alice") != 2 {
+			t.Errorf("policy %d, arriving %d: expected 2 requests to be queued, got %d", test.policy, test.arriving, queued(throttle, "alice"))
+		}
+		if stats := throttle.Stats("alice"); stats.Rejected != 1 || stats.Waiters != 2 {
+			t.Errorf("policy %d, arriving %d: expected 1 rejected and 2 waiting, got %+v", test.policy, test.arriving, stats)
+		}
+		throttle.Close()
+	}
+}
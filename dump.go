@@ -0,0 +1,69 @@
+package throttle
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// statsDump is the serialized form of the stats of all clients.
+type statsDump struct {
+	Taken   time.Time                 `json:"taken"`
+	Clients map[string]clientStatsRow `json:"clients"`
+}
+
+// clientStatsRow is the serialized form of a client's stats.
+type clientStatsRow struct {
+	Allowed     uint64    `json:"allowed"`
+	Rejected    uint64    `json:"rejected"`
+	LastRequest time.Time `json:"last_request"`
+	Waiters     int       `json:"waiters"`
+}
+
+// DumpJSON writes the stats of all clients to w as a JSON object, which looks
+// like this:
+//
+//	{
+//	  "taken": "2021-02-22T12:00:00Z",
+//	  "clients": {
+//	    "alice": {"allowed": 42, "rejected": 3, "last_request": "2021-02-22T11:59:58Z", "waiters": 1}
+//	  }
+//	}
+//
+// The dump can be analyzed offline, or loaded using LoadJSON, e.g. to keep the
+// stats across a restart. Unlike Snapshot, DumpJSON works regardless of the
+// Store used, but does not include the clients' tokens.
+func (t *Throttle) DumpJSON(w io.Writer) error {
+	dump := statsDump{Taken: t.clock.Now(), Clients: make(map[string]clientStatsRow)}
+	t.metrics.clients.each(func(client string, stats *ClientStats) bool {
+		dump.Clients[client] = clientStatsRow{
+			Allowed:     stats.Allowed,
+			Rejected:    stats.Rejected,
+			LastRequest: stats.LastRequest,
+			Waiters:     stats.Waiters,
+		}
+		return true
+	})
+	return json.NewEncoder(w).Encode(dump)
+}
+
+// LoadJSON replaces the stats of the clients in a dump written by DumpJSON.
+// The stats of clients not in the dump are kept, and so is the number of
+// requests currently waiting, which the dump only reports. The totals of
+// Metrics are not changed.
+func (t *Throttle) LoadJSON(r io.Reader) error {
+	var dump statsDump
+	if err := json.NewDecoder(r).Decode(&dump); err != nil {
+		return err
+	}
+	for client, row := range dump.Clients {
+		shard := t.metrics.clients.shard(client)
+		shard.mutex.Lock()
+		stats := t.metrics.client(shard, client)
+		stats.Allowed = row.Allowed
+		stats.Rejected = row.Rejected
+		stats.LastRequest = row.LastRequest
+		shard.mutex.Unlock()
+	}
+	return nil
+}
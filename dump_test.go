@@ -0,0 +1,52 @@
+package throttle
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDumpJSON(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Second, WithClock(clock))
+	throttle.Allow("alice")
+	throttle.Allow("alice")
+	throttle.Allow("bob")
+
+	var buf bytes.Buffer
+	if err := throttle.DumpJSON(&buf); err != nil {
+		t.Fatalf("dump: %v", err)
+	}
+	var dump struct {
+		Taken   time.Time `json:"taken"`
+		Clients map[string]struct {
+			Allowed  uint64 `json:"allowed"`
+			Rejected uint64 `json:"rejected"`
+		} `json:"clients"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &dump); err != nil {
+		t.Fatalf("parse dump: %v", err)
+	}
+	if !dump.Taken.Equal(clock.Now()) {
+		t.Errorf("expected dump taken at %v, got %v", clock.Now(), dump.Taken)
+	}
+	if alice := dump.Clients["alice"]; alice.Allowed != 1 || alice.Rejected != 1 {
+		t.Errorf("alice: expected 1 allowed and 1 rejected, got %+v", alice)
+	}
+	if bob := dump.Clients["bob"]; bob.Allowed != 1 || bob.Rejected != 0 {
+		t.Errorf("bob: expected 1 allowed, got %+v", bob)
+	}
+
+	restarted := New(1*time.Second, WithClock(clock))
+	restarted.Allow("carol")
+	if err := restarted.LoadJSON(&buf); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if stats := restarted.Stats("alice"); stats.Allowed != 1 || stats.Rejected != 1 || !stats.LastRequest.Equal(clock.Now()) {
+		t.Errorf("alice: expected stats to be loaded, got %+v", stats)
+	}
+	if stats := restarted.Stats("carol"); stats.Allowed != 1 {
+		t.Errorf("carol: expected stats to be kept, got %+v", stats)
+	}
+}
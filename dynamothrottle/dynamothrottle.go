@@ -0,0 +1,245 @@
+// Package dynamothrottle provides a throttle.Store that keeps the token
+// buckets in a DynamoDB table, so that serverless functions, e.g. the
+// invocations of a Lambda-based API, enforce one shared limit per client:
+//
+//	client := dynamodb.NewFromConfig(cfg)
+//	t := throttle.New(time.Second, throttle.WithStore(dynamothrottle.NewStore(client, "throttle")))
+//
+// Every bucket is an item of the table, whose partition key is a string
+// attribute named "key" by default, and which is updated atomically using
+// conditional writes. Every item carries the time at which its bucket is full
+// again as a number of seconds since the epoch in the attribute "expires",
+// which should be enabled as the table's TTL attribute, so that the items of
+// idle clients are deleted automatically. DynamoDB does not provide the time,
+// so the clocks of the instances must agree.
+package dynamothrottle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/patrickbucher/throttle"
+)
+
+// maxAttempts is the number of times an update of a bucket is attempted
+// before giving up because of concurrent updates.
+const maxAttempts = 16
+
+// ErrContention is returned if a bucket could not be updated because other
+// instances kept on updating it at the same time.
+var ErrContention = errors.New("dynamothrottle: too many concurrent updates")
+
+// Client is the part of a DynamoDB client used by Store, which is implemented
+// by *dynamodb.Client.
+type Client interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+// Store is a throttle.Store keeping the token buckets in a DynamoDB table.
+type Store struct {
+	client       Client
+	table        string
+	prefix       string
+	keyAttribute string
+	ttlAttribute string
+	now          func() time.Time
+}
+
+// Option configures a Store created by NewStore.
+type Option func(*Store)
+
+// WithPrefix sets the prefix of the keys of the items holding the clients'
+// buckets, which is "throttle:" by default. Throttles enforcing different
+// limits in the same table must use different prefixes.
+func WithPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.prefix = prefix
+	}
+}
+
+// WithKeyAttribute sets the name of the table's partition key, which is "key"
+// by default.
+func WithKeyAttribute(name string) Option {
+	return func(s *Store) {
+		s.keyAttribute = name
+	}
+}
+
+// WithTTLAttribute sets the name of the attribute holding the time at which
+// an item can be deleted, which is "expires" by default.
+func WithTTLAttribute(name string) Option {
+	return func(s *Store) {
+		s.ttlAttribute = name
+	}
+}
+
+// WithClock makes the Store use the given clock instead of the system's
+// clock.
+func WithClock(clock throttle.Clock) Option {
+	return func(s *Store) {
+		s.now = clock.Now
+	}
+}
+
+// NewStore creates a new Store keeping the buckets in the given table using
+// the given DynamoDB client, e.g. a *dynamodb.Client.
+func NewStore(client Client, table string, opts ...Option) *Store {
+	store := Store{
+		client:       client,
+		table:        table,
+		prefix:       "throttle:",
+		keyAttribute: "key",
+		ttlAttribute: "expires",
+		now:          time.Now,
+	}
+	for _, opt := range opts {
+		opt(&store)
+	}
+	return &store
+}
+
+// bucket is the state of a client's bucket, together with the version of the
+// item it was read from, or 0 if there was none.
+type bucket struct {
+	throttle.TokenBucket
+	version int64
+}
+
+// TryConsume implements throttle.Store.
+func (s *Store) TryConsume(ctx context.Context, client string, limit throttle.Limit, n int, maxWait time.Duration) (throttle.Result, error) {
+	var result throttle.Result
+	err := s.update(ctx, client, limit, func(b *throttle.TokenBucket) bool {
+		result = b.TryConsume(limit, n, maxWait)
+		return result.OK
+	})
+	return result, err
+}
+
+// Refund implements throttle.Store.
+func (s *Store) Refund(ctx context.Context, client string, limit throttle.Limit, n int) error {
+	return s.update(ctx, client, limit, func(b *throttle.TokenBucket) bool {
+		b.Refund(limit, n)
+		return true
+	})
+}
+
+// Peek implements throttle.Store.
+func (s *Store) Peek(ctx context.Context, client string, limit throttle.Limit) (throttle.Result, error) {
+	var result throttle.Result
+	err := s.update(ctx, client, limit, func(b *throttle.TokenBucket) bool {
+		result = b.Peek(limit)
+		return false
+	})
+	return result, err
+}
+
+// update applies f to the client's bucket, with the tokens spawned until now
+// added, and writes the bucket if f returns true, provided that the item has
+// not been changed since it was read. Otherwise, the update is retried.
+func (s *Store) update(ctx context.Context, client string, limit throttle.Limit, f func(*throttle.TokenBucket) bool) error {
+	key := map[string]types.AttributeValue{s.keyAttribute: &types.AttributeValueMemberS{Value: s.prefix + client}}
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		now := s.now()
+		out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(s.table),
+			Key:            key,
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return err
+		}
+		b := bucket{TokenBucket: throttle.NewTokenBucket(limit, now)}
+		if len(out.Item) > 0 {
+			if b, err = decode(out.Item); err != nil {
+				return err
+			}
+			b.Advance(limit, now)
+		}
+		if !f(&b.TokenBucket) {
+			return nil
+		}
+
+		_, err = s.client.PutItem(ctx, s.put(key, b, limit))
+		var conflict *types.ConditionalCheckFailedException
+		if errors.As(err, &conflict) {
+			// another instance was faster, try again with its state
+			continue
+		}
+		return err
+	}
+	return ErrContention
+}
+
+// put returns the conditional write of the bucket read from the item of the
+// given key.
+func (s *Store) put(key map[string]types.AttributeValue, b bucket, limit throttle.Limit) *dynamodb.PutItemInput {
+	item := map[string]types.AttributeValue{
+		"tokens":       number(strconv.FormatFloat(b.Tokens, 'g', -1, 64)),
+		"last":         number(strconv.FormatInt(b.Last.UnixMicro(), 10)),
+		"version":      number(strconv.FormatInt(b.version+1, 10)),
+		s.ttlAttribute: number(strconv.FormatInt(b.expiration(limit).Unix(), 10)),
+	}
+	for name, value := range key {
+		item[name] = value
+	}
+	input := &dynamodb.PutItemInput{
+		TableName:                aws.String(s.table),
+		Item:                     item,
+		ExpressionAttributeNames: map[string]string{"#key": s.keyAttribute},
+		ConditionExpression:      aws.String("attribute_not_exists(#key)"),
+	}
+	if b.version > 0 {
+		input.ExpressionAttributeNames = map[string]string{"#version": "version"}
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{":version": number(strconv.FormatInt(b.version, 10))}
+		input.ConditionExpression = aws.String("#version = :version")
+	}
+	return input
+}
+
+func number(value string) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: value}
+}
+
+// expiration returns the time at which the bucket is full, and can be
+// forgotten, plus a second.
+func (b *bucket) expiration(limit throttle.Limit) time.Time {
+	return b.Last.Add(b.UntilFull(limit) + time.Second)
+}
+
+// decode reads the bucket from an item.
+func decode(item map[string]types.AttributeValue) (bucket, error) {
+	tokens, err := attribute(item, "tokens", func(s string) (float64, error) { return strconv.ParseFloat(s, 64) })
+	if err != nil {
+		return bucket{}, err
+	}
+	last, err := attribute(item, "last", func(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) })
+	if err != nil {
+		return bucket{}, err
+	}
+	version, err := attribute(item, "version", func(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) })
+	if err != nil {
+		return bucket{}, err
+	}
+	return bucket{TokenBucket: throttle.TokenBucket{Tokens: tokens, Last: time.UnixMicro(last)}, version: version}, nil
+}
+
+// attribute parses the numeric attribute of the given name.
+func attribute[T any](item map[string]types.AttributeValue, name string, parse func(string) (T, error)) (T, error) {
+	var zero T
+	n, ok := item[name].(*types.AttributeValueMemberN)
+	if !ok {
+		return zero, fmt.Errorf("dynamothrottle: malformed bucket: no number %q", name)
+	}
+	value, err := parse(n.Value)
+	if err != nil {
+		return zero, fmt.Errorf("dynamothrottle: malformed bucket: %w", err)
+	}
+	return value, nil
+}
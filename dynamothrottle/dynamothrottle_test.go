@@ -0,0 +1,149 @@
+package dynamothrottle
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/patrickbucher/throttle"
+)
+
+// fakeDynamo is an in-memory Client, which evaluates the conditions written
+// by Store.
+type fakeDynamo struct {
+	mutex sync.Mutex
+	items map[string]map[string]types.AttributeValue
+
+	// conflicts is the number of writes failing as if another instance had
+	// been faster.
+	conflicts int
+}
+
+func newFakeDynamo() *fakeDynamo {
+	return &fakeDynamo{items: make(map[string]map[string]types.AttributeValue)}
+}
+
+func (f *fakeDynamo) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	key := params.Key["key"].(*types.AttributeValueMemberS).Value
+	return &dynamodb.GetItemOutput{Item: f.items[aws.ToString(params.TableName)+"/"+key]}, nil
+}
+
+func (f *fakeDynamo) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	key := aws.ToString(params.TableName) + "/" + params.Item["key"].(*types.AttributeValueMemberS).Value
+	item, exists := f.items[key]
+	conflict := f.conflicts > 0
+	switch aws.ToString(params.ConditionExpression) {
+	case "attribute_not_exists(#key)":
+		conflict = conflict || exists
+	case "#version = :version":
+		version := params.ExpressionAttributeValues[":version"].(*types.AttributeValueMemberN).Value
+		conflict = conflict || !exists || item["version"].(*types.AttributeValueMemberN).Value != version
+	}
+	if conflict {
+		f.conflicts--
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+	f.items[key] = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestStore(t *testing.T) {
+	now := time.Date(2021, 2, 22, 0, 0, 0, 0, time.UTC)
+	store := NewStore(newFakeDynamo(), "limits", WithClock(clock{now}))
+	limit := throttle.Limit{Rate: 1 * time.Second, Burst: 4}
+	ctx := context.Background()
+
+	result, err := store.TryConsume(ctx, "alice", limit, 3, 0)
+	if err != nil || !result.OK || result.Remaining != 1 {
+		t.Fatalf("three tokens: expected to be taken, got %v, %v", result, err)
+	}
+	result, err = store.TryConsume(ctx, "alice", limit, 3, 0)
+	if err != nil || result.OK || result.Wait != 2*time.Second {
+		t.Errorf("three more tokens: expected to wait for two, got %v, %v", result, err)
+	}
+	result, err = store.TryConsume(ctx, "alice", limit, 3, 2*time.Second)
+	if err != nil || !result.OK || result.Wait != 2*time.Second {
+		t.Errorf("three reserved tokens: expected to be taken, got %v, %v", result, err)
+	}
+	if err := store.Refund(ctx, "alice", limit, 6); err != nil {
+		t.Fatalf("refund: expected no error, got %v", err)
+	}
+	result, err = store.Peek(ctx, "alice", limit)
+	if err != nil || result.Remaining != 4 {
+		t.Errorf("peek: expected %d remaining, got %v, %v", 4, result, err)
+	}
+}
+
+func TestStoreTTL(t *testing.T) {
+	now := time.Date(2021, 2, 22, 0, 0, 0, 0, time.UTC)
+	dynamo := newFakeDynamo()
+	store := NewStore(dynamo, "limits", WithPrefix("api:"), WithClock(clock{now}))
+	limit := throttle.Limit{Rate: 1 * time.Minute, Burst: 2}
+	if _, err := store.TryConsume(context.Background(), "alice", limit, 2, 0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	item, ok := dynamo.items["limits/api:alice"]
+	if !ok {
+		t.Fatal("expected the bucket to be kept under the prefixed key")
+	}
+	expires := item["expires"].(*types.AttributeValueMemberN).Value
+	if expected := strconv.FormatInt(now.Add(2*time.Minute+time.Second).Unix(), 10); expires != expected {
+		t.Errorf("expected the item to expire once the bucket is full at %s, got %s", expected, expires)
+	}
+}
+
+func TestStoreContention(t *testing.T) {
+	dynamo := newFakeDynamo()
+	store := NewStore(dynamo, "limits")
+	limit := throttle.Limit{Rate: 1 * time.Second, Burst: 1}
+
+	dynamo.conflicts = 3
+	result, err := store.TryConsume(context.Background(), "alice", limit, 1, 0)
+	if err != nil || !result.OK {
+		t.Errorf("after conflicts: expected the token to be taken, got %v, %v", result, err)
+	}
+	dynamo.conflicts = maxAttempts
+	if err := store.Refund(context.Background(), "alice", limit, 1); !errors.Is(err, ErrContention) {
+		t.Errorf("expected %v, got %v", ErrContention, err)
+	}
+}
+
+func TestSharedLimit(t *testing.T) {
+	dynamo := newFakeDynamo()
+	first := throttle.New(1*time.Second, throttle.WithMaxWait(10*time.Millisecond), throttle.WithStore(NewStore(dynamo, "limits")))
+	second := throttle.New(1*time.Second, throttle.WithMaxWait(10*time.Millisecond), throttle.WithStore(NewStore(dynamo, "limits")))
+
+	if err := first.Wait("alice"); err != nil {
+		t.Fatalf("first instance: expected no error, got %v", err)
+	}
+	if err := second.Wait("alice"); !errors.Is(err, throttle.ErrThrottled) {
+		t.Errorf("second instance: expected %v, got %v", throttle.ErrThrottled, err)
+	}
+}
+
+// clock is a throttle.Clock standing still.
+type clock struct {
+	now time.Time
+}
+
+func (c clock) Now() time.Time {
+	return c.now
+}
+
+func (c clock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- c.now.Add(d)
+	return ch
+}
+
+func (c clock) Sleep(d time.Duration) {}
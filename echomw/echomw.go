@@ -0,0 +1,60 @@
+// Package echomw adapts the middleware of a throttle.Throttle to echo, so that
+// it can be used for the whole server, a group, or single routes:
+//
+//	e := echo.New()
+//	e.Use(echomw.Limit(api, nil))
+//	e.POST("/login", handleLogin, echomw.Limit(login, echomw.ByRoute(nil)))
+//
+// Requests are throttled like by throttle.Throttle.Middleware, including its
+// response headers; throttled requests are not passed on.
+package echomw
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/patrickbucher/throttle"
+)
+
+// KeyFunc derives the client key from a request.
+type KeyFunc func(c echo.Context) string
+
+// RealIP keys requests by the client IP determined by echo, which respects
+// the server's IPExtractor.
+func RealIP(c echo.Context) string {
+	return c.RealIP()
+}
+
+// Limit returns an echo middleware throttling requests using t, keyed by
+// keyFn. If keyFn is nil, RealIP is used.
+func Limit(t *throttle.Throttle, keyFn KeyFunc) echo.MiddlewareFunc {
+	if keyFn == nil {
+		keyFn = RealIP
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var err error
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.SetRequest(r)
+				err = next(c)
+			})
+			t.Middleware(handler, func(*http.Request) string {
+				return keyFn(c)
+			}).ServeHTTP(c.Response(), c.Request())
+			return err
+		}
+	}
+}
+
+// ByRoute keys requests by the key derived using keyFn and the path of the
+// echo route matched, e.g. "/users/:id", so that a client gets a bucket per
+// route rather than one for all routes sharing the Throttle. If keyFn is nil,
+// RealIP is used.
+func ByRoute(keyFn KeyFunc) KeyFunc {
+	if keyFn == nil {
+		keyFn = RealIP
+	}
+	return func(c echo.Context) string {
+		return keyFn(c) + " " + c.Path()
+	}
+}
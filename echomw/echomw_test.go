@@ -0,0 +1,62 @@
+package echomw
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/patrickbucher/throttle"
+)
+
+func TestLimit(t *testing.T) {
+	limiter := throttle.New(1*time.Hour, throttle.WithMaxWait(time.Millisecond))
+	defer limiter.Close()
+	handled := 0
+	ok := func(c echo.Context) error {
+		handled++
+		return c.NoContent(http.StatusOK)
+	}
+	e := echo.New()
+	e.GET("/users/:id", ok, Limit(limiter, ByRoute(nil)))
+	e.GET("/posts/:id", ok, Limit(limiter, ByRoute(nil)))
+	e.GET("/health", ok)
+
+	for _, testCase := range []struct {
+		path   string
+		status int
+	}{
+		{"/users/1", http.StatusOK},
+		{"/users/2", http.StatusTooManyRequests},
+		{"/posts/1", http.StatusOK},
+		{"/health", http.StatusOK},
+	} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, testCase.path, nil))
+		if rec.Code != testCase.status {
+			t.Errorf("%s: expected status %d, got %d", testCase.path, testCase.status, rec.Code)
+		}
+	}
+	if handled != 3 {
+		t.Errorf("expected 3 requests handled, got %d", handled)
+	}
+	if stats := limiter.Stats("192.0.2.1 /users/:id"); stats.Allowed != 1 || stats.Rejected != 1 {
+		t.Errorf("expected requests keyed by route, got %+v", stats)
+	}
+}
+
+func TestLimitHandlerError(t *testing.T) {
+	limiter := throttle.New(1 * time.Hour)
+	defer limiter.Close()
+	failed := errors.New("failed")
+	handler := Limit(limiter, nil)(func(c echo.Context) error {
+		return failed
+	})
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	if err := handler(c); err != failed {
+		t.Errorf("expected error of the handler, got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrThrottled is matched by errors.Is for all requests that have been
+// rejected because no token could be acquired in time.
+var ErrThrottled = errors.New("throttled")
+
+// ErrClosed is returned for requests to a Throttle that has been closed.
+var ErrClosed = errors.New("throttle closed")
+
+// ErrBurstExceeded is returned for requests taking more tokens than a client's
+// bucket can hold.
+var ErrBurstExceeded = errors.New("request exceeds burst")
+
+// errDeadline is wrapped by the *ThrottledError of a request whose token would
+// not be available before the deadline of its context.
+var errDeadline = fmt.Errorf("token not available before deadline: %w", context.DeadlineExceeded)
+
+// ThrottledError is returned for a request that has been rejected because no
+// token could be acquired in time.
+type ThrottledError struct {
+	// Client is the key of the client whose request has been rejected.
+	Client string
+
+	// Tier is the name of the limit that has been exceeded: empty for the
+	// client's request rate, GlobalTier for the limit set by WithGlobalLimit,
+	// BannedTier for a client banned using Ban, BreakerTier for a client
+	// whose circuit breaker is open, or the name given to WithTier or of a
+	// Quota.
+	Tier string
+
+	// Rate is the request rate in effect for the client.
+	Rate time.Duration
+
+	// RetryAfter is the estimated time until the client's next token is
+	// spawned. Other waiting requests may claim that token first.
+	RetryAfter time.Duration
+
+	// Err is the cause of the rejection, if any: an error wrapping
+	// context.DeadlineExceeded for a request whose token would not be
+	// available before the deadline of its context.
+	Err error
+}
+
+func (e *ThrottledError) Error() string {
+	msg := fmt.Sprintf("one request per %v allowed", e.Rate)
+	if e.Tier != "" {
+		msg = fmt.Sprintf("%s (%s)", msg, e.Tier)
+	}
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	return msg
+}
+
+// Is reports whether target is ErrThrottled.
+func (e *ThrottledError) Is(target error) bool {
+	return target == ErrThrottled
+}
+
+// Unwrap returns the cause of the rejection, if any.
+func (e *ThrottledError) Unwrap() error {
+	return e.Err
+}
+
+// asThrottled returns the *ThrottledError in err's tree, if any. Unlike
+// errors.As, it does not allocate for a nil error, which keeps requests that
+// are served free of allocations.
+func asThrottled(err error) (*ThrottledError, bool) {
+	if err == nil {
+		return nil, false
+	}
+	var throttledErr *ThrottledError
+	ok := errors.As(err, &throttledErr)
+	return throttledErr, ok
+}
@@ -0,0 +1,32 @@
+package throttle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestThrottledError(t *testing.T) {
+	throttle := New(1 * time.Second)
+	if err := throttle.Wait("alice"); err != nil {
+		t.Fatalf("first request: expected no error, got %v", err)
+	}
+	err := throttle.TryWaitFor("alice", 100*time.Millisecond)
+	if !errors.Is(err, ErrThrottled) {
+		t.Fatalf("expected %v, got %v", ErrThrottled, err)
+	}
+
+	var throttledErr *ThrottledError
+	if !errors.As(err, &throttledErr) {
+		t.Fatalf("expected %T, got %T", throttledErr, err)
+	}
+	if throttledErr.Client != "alice" {
+		t.Errorf("client: expected %q, got %q", "alice", throttledErr.Client)
+	}
+	if throttledErr.Rate != 1*time.Second {
+		t.Errorf("rate: expected %v, got %v", 1*time.Second, throttledErr.Rate)
+	}
+	if throttledErr.RetryAfter <= 0 || throttledErr.RetryAfter > 1*time.Second {
+		t.Errorf("retry after: expected (0, 1s], got %v", throttledErr.RetryAfter)
+	}
+}
@@ -0,0 +1,195 @@
+// Package etcdthrottle provides a throttle.Store that keeps the token buckets
+// in etcd, so that a few replicas of a service enforce one shared, strongly
+// consistent limit per client:
+//
+//	t := throttle.New(time.Second, throttle.WithStore(etcdthrottle.NewStore(client)))
+//
+// Every update of a bucket is a transaction that only succeeds if the bucket
+// has not been changed since it was read, so the Store suits services that
+// make a moderate number of requests, e.g. control planes. Like memcached,
+// etcd does not provide the time, so the replicas' clocks must agree.
+package etcdthrottle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/patrickbucher/throttle"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// maxAttempts is the number of times an update of a bucket is attempted
+// before giving up because of concurrent updates.
+const maxAttempts = 16
+
+// ErrContention is returned if a bucket could not be updated because other
+// replicas kept on updating it at the same time.
+var ErrContention = errors.New("etcdthrottle: too many concurrent updates")
+
+// Store is a throttle.Store keeping the token buckets in etcd.
+type Store struct {
+	kv     clientv3.KV
+	prefix string
+	now    func() time.Time
+}
+
+// Option configures a Store created by NewStore.
+type Option func(*Store)
+
+// WithPrefix sets the prefix of the etcd keys holding the clients' buckets,
+// which is "throttle/" by default. Throttles enforcing different limits must
+// use different prefixes.
+func WithPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.prefix = prefix
+	}
+}
+
+// WithClock makes the Store use the given clock instead of the system's
+// clock.
+func WithClock(clock throttle.Clock) Option {
+	return func(s *Store) {
+		s.now = clock.Now
+	}
+}
+
+// NewStore creates a new Store using the given etcd client, e.g. a
+// *clientv3.Client.
+func NewStore(kv clientv3.KV, opts ...Option) *Store {
+	store := Store{
+		kv:     kv,
+		prefix: "throttle/",
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(&store)
+	}
+	return &store
+}
+
+// bucket is the state of a client's bucket. The limit is kept, so that Sweep
+// can tell whether the bucket is full.
+type bucket struct {
+	Tokens float64       `json:"tokens"`
+	Last   int64         `json:"last"`
+	Rate   time.Duration `json:"rate"`
+	Burst  int           `json:"burst"`
+}
+
+// TryConsume implements throttle.Store.
+func (s *Store) TryConsume(ctx context.Context, client string, limit throttle.Limit, n int, maxWait time.Duration) (throttle.Result, error) {
+	var result throttle.Result
+	err := s.update(ctx, client, limit, func(b *throttle.TokenBucket) bool {
+		result = b.TryConsume(limit, n, maxWait)
+		return result.OK
+	})
+	return result, err
+}
+
+// Refund implements throttle.Store.
+func (s *Store) Refund(ctx context.Context, client string, limit throttle.Limit, n int) error {
+	return s.update(ctx, client, limit, func(b *throttle.TokenBucket) bool {
+		b.Refund(limit, n)
+		return true
+	})
+}
+
+// Peek implements throttle.Store.
+func (s *Store) Peek(ctx context.Context, client string, limit throttle.Limit) (throttle.Result, error) {
+	var result throttle.Result
+	err := s.update(ctx, client, limit, func(b *throttle.TokenBucket) bool {
+		result = b.Peek(limit)
+		return false
+	})
+	return result, err
+}
+
+// Sweep removes the buckets that are full, which are the same as no buckets,
+// so that the keys of idle clients do not pile up. It should be called
+// periodically, e.g. once per idle timeout, by one of the replicas.
+func (s *Store) Sweep(ctx context.Context) error {
+	resp, err := s.kv.Get(ctx, s.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	for _, kv := range resp.Kvs {
+		var b bucket
+		if err := json.Unmarshal(kv.Value, &b); err != nil {
+			continue
+		}
+		if tokens := b.tokenBucket(now); tokens.Tokens < float64(b.Burst) {
+			continue
+		}
+		// the bucket is only removed if it has not been used in the meantime
+		key := string(kv.Key)
+		_, err := s.kv.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision)).
+			Then(clientv3.OpDelete(key)).
+			Commit()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// update applies f to the client's bucket, with the tokens spawned until now
+// added, and stores the bucket if f returns true. The update is retried if
+// the bucket was changed concurrently.
+func (s *Store) update(ctx context.Context, client string, limit throttle.Limit, f func(*throttle.TokenBucket) bool) error {
+	key := s.prefix + client
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		resp, err := s.kv.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		now := s.now()
+		b := bucket{Tokens: float64(limit.Burst), Last: now.UnixMicro()}
+		// a revision of 0 means that the key must not exist
+		var revision int64
+		if len(resp.Kvs) > 0 {
+			if err := json.Unmarshal(resp.Kvs[0].Value, &b); err != nil {
+				return err
+			}
+			revision = resp.Kvs[0].ModRevision
+		}
+		b.Rate, b.Burst = limit.Rate, limit.Burst
+		tokens := b.tokenBucket(now)
+		if !f(&tokens) {
+			return nil
+		}
+		b.Tokens, b.Last = tokens.Tokens, tokens.Last.UnixMicro()
+
+		op := clientv3.OpDelete(key)
+		if b.Tokens < float64(b.Burst) {
+			value, err := json.Marshal(b)
+			if err != nil {
+				return err
+			}
+			op = clientv3.OpPut(key, string(value))
+		}
+		txn, err := s.kv.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", revision)).
+			Then(op).
+			Commit()
+		if err != nil {
+			return err
+		}
+		if txn.Succeeded {
+			return nil
+		}
+		// another replica was faster, try again with its state
+	}
+	return ErrContention
+}
+
+// tokenBucket returns the state of the bucket with the tokens spawned until now
+// added.
+func (b *bucket) tokenBucket(now time.Time) throttle.TokenBucket {
+	tokens := throttle.TokenBucket{Tokens: b.Tokens, Last: time.UnixMicro(b.Last)}
+	tokens.Advance(throttle.Limit{Rate: b.Rate, Burst: b.Burst}, now)
+	return tokens
+}
@@ -0,0 +1,213 @@
+package etcdthrottle
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/patrickbucher/throttle"
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// fakeKV is an in-memory clientv3.KV supporting the operations used by Store.
+type fakeKV struct {
+	mutex    sync.Mutex
+	revision int64
+	kvs      map[string]*mvccpb.KeyValue
+}
+
+func newFakeKV() *fakeKV {
+	return &fakeKV{kvs: make(map[string]*mvccpb.KeyValue)}
+}
+
+func (f *fakeKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	end := clientv3.OpGet(key, opts...).RangeBytes()
+	resp := clientv3.GetResponse{}
+	for k, kv := range f.kvs {
+		if k == key || (len(end) > 0 && k >= key && bytes.Compare([]byte(k), end) < 0) {
+			copied := *kv
+			resp.Kvs = append(resp.Kvs, &copied)
+		}
+	}
+	return &resp, nil
+}
+
+func (f *fakeKV) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeKV) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeKV) Compact(ctx context.Context, rev int64, opts ...clientv3.CompactOption) (*clientv3.CompactResponse, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeKV) Do(ctx context.Context, op clientv3.Op) (clientv3.OpResponse, error) {
+	return clientv3.OpResponse{}, errors.New("not supported")
+}
+
+func (f *fakeKV) Txn(ctx context.Context) clientv3.Txn {
+	return &fakeTxn{kv: f}
+}
+
+// fakeTxn is a transaction comparing mod revisions for equality.
+type fakeTxn struct {
+	kv   *fakeKV
+	cmps []clientv3.Cmp
+	ops  []clientv3.Op
+}
+
+func (t *fakeTxn) If(cs ...clientv3.Cmp) clientv3.Txn {
+	t.cmps = append(t.cmps, cs...)
+	return t
+}
+
+func (t *fakeTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.ops = append(t.ops, ops...)
+	return t
+}
+
+func (t *fakeTxn) Else(ops ...clientv3.Op) clientv3.Txn {
+	return t
+}
+
+func (t *fakeTxn) Commit() (*clientv3.TxnResponse, error) {
+	f := t.kv
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, c := range t.cmps {
+		cmp := pb.Compare(c)
+		if cmp.Target != pb.Compare_MOD || cmp.Result != pb.Compare_EQUAL {
+			return nil, errors.New("not supported")
+		}
+		var revision int64
+		if kv, ok := f.kvs[string(cmp.Key)]; ok {
+			revision = kv.ModRevision
+		}
+		if revision != cmp.GetModRevision() {
+			return &clientv3.TxnResponse{Succeeded: false}, nil
+		}
+	}
+	f.revision++
+	for _, op := range t.ops {
+		key := string(op.KeyBytes())
+		switch {
+		case op.IsPut():
+			f.kvs[key] = &mvccpb.KeyValue{Key: []byte(key), Value: op.ValueBytes(), ModRevision: f.revision}
+		case op.IsDelete():
+			delete(f.kvs, key)
+		}
+	}
+	return &clientv3.TxnResponse{Succeeded: true}, nil
+}
+
+func TestWait(t *testing.T) {
+	limiter := throttle.New(100*time.Millisecond, throttle.WithBurst(2), throttle.WithStore(NewStore(newFakeKV())))
+	for i := 0; i < 3; i++ {
+		if err := limiter.Wait("alice"); err != nil {
+			t.Errorf("request %d: expected no error, got %v", i, err)
+		}
+	}
+}
+
+func TestAllow(t *testing.T) {
+	limiter := throttle.New(1*time.Second, throttle.WithStore(NewStore(newFakeKV())))
+	expected := []bool{true, false}
+	for i, exp := range expected {
+		if allowed := limiter.Allow("alice"); allowed != exp {
+			t.Errorf("request %d: expected allowed to be %v, got %v", i, exp, allowed)
+		}
+	}
+}
+
+func TestRefund(t *testing.T) {
+	kv := newFakeKV()
+	store := NewStore(kv)
+	limit := throttle.Limit{Rate: 1 * time.Second, Burst: 1}
+	ctx := context.Background()
+
+	result, err := store.TryConsume(ctx, "alice", limit, 1, 0)
+	if err != nil || !result.OK {
+		t.Fatalf("first token: expected to be taken, got %v, %v", result, err)
+	}
+	if err := store.Refund(ctx, "alice", limit, 1); err != nil {
+		t.Fatalf("refund: expected no error, got %v", err)
+	}
+	result, err = store.Peek(ctx, "alice", limit)
+	if err != nil || result.Remaining != 1 {
+		t.Errorf("peek: expected %d remaining, got %v, %v", 1, result, err)
+	}
+	if n := len(kv.kvs); n != 0 {
+		t.Errorf("full bucket: expected to be deleted, %d keys left", n)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	kv := newFakeKV()
+	now := time.Now()
+	store := NewStore(kv, WithClock(clock{&now}))
+	ctx := context.Background()
+	store.TryConsume(ctx, "alice", throttle.Limit{Rate: 1 * time.Second, Burst: 1}, 1, 0)
+	store.TryConsume(ctx, "bob", throttle.Limit{Rate: 1 * time.Minute, Burst: 1}, 1, 0)
+
+	now = now.Add(2 * time.Second)
+	if err := store.Sweep(ctx); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, ok := kv.kvs["throttle/alice"]; ok {
+		t.Errorf("full bucket: expected to be swept")
+	}
+	if _, ok := kv.kvs["throttle/bob"]; !ok {
+		t.Errorf("bucket being refilled: expected to be kept")
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	store := NewStore(newFakeKV())
+	limit := throttle.Limit{Rate: 1 * time.Hour, Burst: 50}
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	taken := 0
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				result, err := store.TryConsume(ctx, "alice", limit, 1, 0)
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+					return
+				}
+				if result.OK {
+					mutex.Lock()
+					taken++
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if taken != limit.Burst {
+		t.Errorf("expected exactly %d tokens to be taken, got %d", limit.Burst, taken)
+	}
+}
+
+// clock is a throttle.Clock returning the time pointed to.
+type clock struct {
+	now *time.Time
+}
+
+func (c clock) Now() time.Time                         { return *c.now }
+func (c clock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (c clock) Sleep(d time.Duration)                  { time.Sleep(d) }
@@ -0,0 +1,26 @@
+package throttle
+
+import "expvar"
+
+// WithExpvar publishes the Throttle's counters as an expvar variable with the
+// given name, which is a map holding the total number of waits (allowed and
+// rejected requests), the number of rejections, and the number of clients
+// tracked (-1 if unknown). Like expvar.Publish, New panics if a variable with
+// that name already exists.
+func WithExpvar(name string) Option {
+	return func(t *Throttle) {
+		t.expvarName = name
+	}
+}
+
+// publishExpvar publishes the Throttle's counters under the given name.
+func (t *Throttle) publishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		metrics := t.Metrics()
+		return map[string]interface{}{
+			"waits":      metrics.Allowed + metrics.Rejected,
+			"rejections": metrics.Rejected,
+			"clients":    metrics.Clients,
+		}
+	}))
+}
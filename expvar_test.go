@@ -0,0 +1,30 @@
+package throttle
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+)
+
+func TestExpvar(t *testing.T) {
+	throttle := New(1*time.Second, WithExpvar("throttle_test"))
+	throttle.Allow("alice")
+	throttle.Allow("alice")
+	throttle.Allow("bob")
+
+	v := expvar.Get("throttle_test")
+	if v == nil {
+		t.Fatalf("expected variable to be published")
+	}
+	var counters map[string]int
+	if err := json.Unmarshal([]byte(v.String()), &counters); err != nil {
+		t.Fatalf("expected counters as JSON, got %q: %v", v.String(), err)
+	}
+	expected := map[string]int{"waits": 3, "rejections": 1, "clients": 2}
+	for name, value := range expected {
+		if counters[name] != value {
+			t.Errorf("%s: expected %d, got %d", name, value, counters[name])
+		}
+	}
+}
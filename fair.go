@@ -0,0 +1,221 @@
+package throttle
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// WithFairQueueing makes the clients share the tokens of the limit set by
+// WithGlobalLimit in proportion to their weights while it is contended,
+// instead of handing them out in the order the requests arrive, which lets
+// the clients sending the most requests take most of the tokens. Requests
+// whose global token is not available right away line up in a queue; the
+// tokens are handed out by weighted fair queueing, so that a client of
+// weight 2 gets twice as many tokens as a client of weight 1 while both have
+// requests waiting. A request not served within its maximum waiting time is
+// rejected with a *ThrottledError whose Tier is GlobalTier. Queued requests
+// count as waiting, both in the client's Stats and for WithMaxWaiters. While
+// requests are queued, Allow does not take global tokens, and Reserve blocks
+// until its global token is available, see Reserve. If weight is nil or
+// returns less than or equal to 0 for a client, the client's weight is 1.
+// Without WithGlobalLimit, fair queueing has no effect.
+func WithFairQueueing(weight func(client string) float64) Option {
+	return func(t *Throttle) {
+		t.fair = &fairQueue{weight: weight, finish: make(map[string]float64), wake: make(chan struct{}, 1)}
+	}
+}
+
+// fairQueue orders the requests waiting for global tokens by their virtual
+// finish time. Its fields must only be accessed while holding its mutex.
+type fairQueue struct {
+	weight  func(client string) float64
+	mutex   sync.Mutex
+	waiters []*fairWaiter
+	seq     uint64
+	virtual float64
+	finish  map[string]float64
+	running bool
+	wake    chan struct{}
+}
+
+// fairWaiter is a request waiting in the fairQueue.
+type fairWaiter struct {
+	client string
+	n      int
+	tag    float64
+	seq    uint64
+	index  int
+	ready  chan struct{}
+}
+
+// takeGlobal takes n global tokens for the client, waiting in the fair queue
+// for up to maxWait if they are not available right away. It returns how long
+// it blocked.
+func (t *Throttle) takeGlobal(ctx context.Context, client string, n int, maxWait time.Duration) (time.Duration, error) {
+	if n > t.global.Burst {
+		return 0, ErrBurstExceeded
+	}
+	q := t.fair
+	start := t.clock.Now()
+	q.mutex.Lock()
+	if len(q.waiters) == 0 {
+		// nobody is ahead of the request
+		result, err := t.store.TryConsume(ctx, globalKey, *t.global, n, 0)
+		if err != nil || result.OK {
+			q.mutex.Unlock()
+			return 0, err
+		}
+	}
+	throttled := &ThrottledError{Client: client, Tier: GlobalTier, Rate: t.global.Rate, RetryAfter: t.global.Rate * time.Duration(len(q.waiters)+n)}
+	if maxWait <= 0 {
+		q.mutex.Unlock()
+		return 0, throttled
+	}
+	if !t.metrics.startWaiting(client, t.maxWaiters) {
+		// too many requests waiting already, do not pile up another one
+		q.mutex.Unlock()
+		return 0, throttled
+	}
+	defer t.metrics.waiting(client, -1)
+	w := t.newFairWaiter(client, n)
+	defer t.releaseFairWaiter(w)
+	q.push(w)
+	if !q.running {
+		q.running = true
+		t.spawn(t.dispatchGlobal)
+	} else if w.index == 0 {
+		q.signal()
+	}
+	q.mutex.Unlock()
+
+	after, timer := t.after(maxWait)
+	defer stopTimer(timer)
+	select {
+	case <-w.ready:
+		return t.clock.Now().Sub(start), nil
+	case <-after:
+	case <-ctx.Done():
+	case <-t.done:
+	}
+	q.mutex.Lock()
+	removed := q.remove(w)
+	q.mutex.Unlock()
+	if !removed {
+		// served in the meantime
+		<-w.ready
+		return t.clock.Now().Sub(start), nil
+	}
+	switch {
+	case t.closed():
+		return t.clock.Now().Sub(start), ErrClosed
+	case ctx.Err() != nil:
+		return t.clock.Now().Sub(start), ctx.Err()
+	}
+	return t.clock.Now().Sub(start), throttled
+}
+
+// dispatchGlobal hands out the global tokens to the requests in the fair
+// queue, until it is empty or the Throttle is closed.
+func (t *Throttle) dispatchGlobal() {
+	q := t.fair
+	for {
+		q.mutex.Lock()
+		if len(q.waiters) == 0 {
+			// idle: start over with a clean slate
+			q.running, q.virtual = false, 0
+			clear(q.finish)
+			q.mutex.Unlock()
+			return
+		}
+		w := q.waiters[0]
+		result, err := t.store.TryConsume(context.Background(), globalKey, *t.global, w.n, 0)
+		if err == nil && result.OK {
+			q.remove(w)
+			q.virtual = w.tag
+			w.ready <- struct{}{}
+			q.mutex.Unlock()
+			continue
+		}
+		q.mutex.Unlock()
+		wait := t.global.Rate
+		if err == nil {
+			wait = result.Wait
+		}
+		after, timer := t.after(wait)
+		select {
+		case <-after:
+		case <-q.wake:
+		case <-t.done:
+			stopTimer(timer)
+			return
+		}
+		stopTimer(timer)
+	}
+}
+
+// push adds the request of the waiter, tagged with its virtual finish time.
+func (q *fairQueue) push(w *fairWaiter) {
+	weight := 1.0
+	if q.weight != nil {
+		if x := q.weight(w.client); x > 0 {
+			weight = x
+		}
+	}
+	w.tag = max(q.virtual, q.finish[w.client]) + float64(w.n)/weight
+	q.finish[w.client] = w.tag
+	q.seq++
+	w.seq = q.seq
+	heap.Push(q, w)
+}
+
+// remove removes the request from the queue. It reports false if the request
+// has been removed before.
+func (q *fairQueue) remove(w *fairWaiter) bool {
+	if w.index < 0 {
+		return false
+	}
+	heap.Remove(q, w.index)
+	return true
+}
+
+// signal wakes up the dispatcher, e.g. for a new request at the head.
+func (q *fairQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *fairQueue) Len() int {
+	return len(q.waiters)
+}
+
+func (q *fairQueue) Less(i, j int) bool {
+	if q.waiters[i].tag != q.waiters[j].tag {
+		return q.waiters[i].tag < q.waiters[j].tag
+	}
+	return q.waiters[i].seq < q.waiters[j].seq
+}
+
+func (q *fairQueue) Swap(i, j int) {
+	q.waiters[i], q.waiters[j] = q.waiters[j], q.waiters[i]
+	q.waiters[i].index = i
+	q.waiters[j].index = j
+}
+
+func (q *fairQueue) Push(x interface{}) {
+	w := x.(*fairWaiter)
+	w.index = len(q.waiters)
+	q.waiters = append(q.waiters, w)
+}
+
+func (q *fairQueue) Pop() interface{} {
+	n := len(q.waiters)
+	w := q.waiters[n-1]
+	q.waiters[n-1] = nil
+	q.waiters = q.waiters[:n-1]
+	w.index = -1
+	return w
+}
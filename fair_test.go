@@ -0,0 +1,118 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFairQueueing(t *testing.T) {
+	clock := newFakeClock()
+	weight := func(client string) float64 {
+		if client == "heavy" {
+			return 3
+		}
+		return 0
+	}
+	throttle := New(time.Millisecond, WithBurst(10), WithGlobalLimit(1*time.Second, 1),
+		WithMaxWait(time.Hour), WithFairQueueing(weight), WithClock(clock))
+	defer throttle.Close()
+	if !throttle.Allow("light") {
+		t.Fatal("expected the first request to take the global token")
+	}
+
+	served := make(chan string)
+	queued := 0
+	enqueue := func(client string) {
+		go func() {
+			if err := throttle.Wait(client); err != nil {
+				t.Errorf("%s: expected no error, got %v", client, err)
+			}
+			served <- client
+		}()
+		queued++
+		for clock.Waiters() < queued {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for _, client := range []string{"light", "light", "light", "heavy", "heavy", "heavy"} {
+		enqueue(client)
+	}
+	if throttle.Allow("other") {
+		t.Error("expected a request to be rejected while requests are queued")
+	}
+
+	// the heavy client gets three times the share of the light one
+	for i, expected := range []string{"heavy", "heavy", "light", "heavy", "light", "light"} {
+		for clock.Waiters() < queued+1 {
+			time.Sleep(time.Millisecond)
+		}
+		clock.Advance(1 * time.Second)
+		if client := <-served; client != expected {
+			t.Errorf("token %d: expected %s to be served, got %s", i, expected, client)
+		}
+	}
+}
+
+func TestFairQueueingTimeout(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(time.Second, WithGlobalLimit(1*time.Second, 1), WithMaxWait(500*time.Millisecond),
+		WithFairQueueing(nil), WithClock(clock))
+	defer throttle.Close()
+	if err := throttle.Wait("alice"); err != nil {
+		t.Fatalf("first request: expected no error, got %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- throttle.Wait("bob")
+	}()
+	for clock.Waiters() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(500 * time.Millisecond)
+	var throttledErr *ThrottledError
+	if err := <-done; !errors.As(err, &throttledErr) || throttledErr.Tier != GlobalTier {
+		t.Errorf("expected to be throttled by the global limit, got %v", err)
+	}
+
+	// bob's own token has been refunded
+	if result, _ := throttle.store.Peek(context.Background(), "bob", throttle.limit("bob")); result.Remaining != 1 {
+		t.Errorf("expected bob's token to be refunded, got %d remaining", result.Remaining)
+	}
+	if err := throttle.WaitN("bob", 2); !errors.Is(err, ErrBurstExceeded) {
+		t.Errorf("request beyond global burst: expected %v, got %v", ErrBurstExceeded, err)
+	}
+}
+
+func TestFairQueueingWaiters(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(time.Millisecond, WithBurst(10), WithGlobalLimit(1*time.Second, 1), WithMaxWait(time.Hour),
+		WithMaxWaiters(1), WithFairQueueing(nil), WithClock(clock))
+	defer throttle.Close()
+	throttle.Allow("alice")
+
+	done := make(chan error)
+	go func() {
+		done <- throttle.Wait("alice")
+	}()
+	for clock.Waiters() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if waiters := throttle.Stats("alice").Waiters; waiters != 1 {
+		t.Errorf("expected the queued request to count as waiting, got %d waiters", waiters)
+	}
+	var throttledErr *ThrottledError
+	if err := throttle.Wait("alice"); !errors.As(err, &throttledErr) || throttledErr.Tier != GlobalTier {
+		t.Errorf("beyond the maximum waiters: expected to be throttled by the global limit, got %v", err)
+	}
+
+	clock.Advance(1 * time.Second)
+	if err := <-done; err != nil {
+		t.Errorf("queued request: expected no error, got %v", err)
+	}
+	if waiters := throttle.Stats("alice").Waiters; waiters != 0 {
+		t.Errorf("after serving: expected no waiters, got %d", waiters)
+	}
+}
@@ -0,0 +1,154 @@
+package throttle
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// WithGCRA makes the Throttle use the generic cell rate algorithm (GCRA)
+// instead of the token bucket. The limits enforced are the same, but only a
+// single timestamp is kept per client: the theoretical arrival time of the
+// client's next request. This keeps the memory used for hundreds of thousands
+// of clients low. WithStore takes precedence over this option.
+func WithGCRA() Option {
+	return func(t *Throttle) {
+		t.newStore = func(clock Clock) Store {
+			return newGCRAStore(clock)
+		}
+	}
+}
+
+// gcraStore is a Store that implements the GCRA. A client whose theoretical
+// arrival time has passed has a full bucket, which is the same as not being
+// known at all.
+type gcraStore struct {
+	clock   Clock
+	mutex   sync.Mutex
+	clients map[string]time.Time
+}
+
+func newGCRAStore(clock Clock) *gcraStore {
+	return &gcraStore{
+		clock:   clock,
+		clients: make(map[string]time.Time),
+	}
+}
+
+func (s *gcraStore) TryConsume(ctx context.Context, client string, limit Limit, n int, maxWait time.Duration) (Result, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.clock.Now()
+	tat := s.tat(client, now)
+	wait := gcraWait(tat, limit, n, now)
+	if wait > maxWait {
+		return gcraResult(false, wait, tat, limit, now), nil
+	}
+	tat = tat.Add(limit.Rate * time.Duration(n))
+	s.clients[client] = tat
+	return gcraResult(true, wait, tat, limit, now), nil
+}
+
+func (s *gcraStore) Refund(ctx context.Context, client string, limit Limit, n int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.clock.Now()
+	tat := s.tat(client, now).Add(-limit.Rate * time.Duration(n))
+	if tat.After(now) {
+		s.clients[client] = tat
+	} else {
+		delete(s.clients, client)
+	}
+	return nil
+}
+
+func (s *gcraStore) Peek(ctx context.Context, client string, limit Limit) (Result, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.clock.Now()
+	tat := s.tat(client, now)
+	return gcraResult(false, gcraWait(tat, limit, 1, now), tat, limit, now), nil
+}
+
+// Len returns the number of clients whose bucket is not known to be full.
+func (s *gcraStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.clients)
+}
+
+// evictIdle removes the clients whose theoretical arrival time is at least
+// idleTimeout before now.
+func (s *gcraStore) evictIdle(now time.Time, idleTimeout time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for client, tat := range s.clients {
+		if now.Sub(tat) >= idleTimeout {
+			delete(s.clients, client)
+		}
+	}
+}
+
+func (s *gcraStore) forget(client string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.clients, client)
+}
+
+// tat returns the theoretical arrival time of the client's next request, which
+// is never before now. It requires the caller to hold the mutex.
+func (s *gcraStore) tat(client string, now time.Time) time.Time {
+	if tat, ok := s.clients[client]; ok && tat.After(now) {
+		return tat
+	}
+	return now
+}
+
+// gcraWait returns the time until a request for n tokens is allowed: a request
+// may arrive up to Burst-n rates before its theoretical arrival time.
+func gcraWait(tat time.Time, limit Limit, n int, now time.Time) time.Duration {
+	tolerance := limit.Rate * time.Duration(limit.Burst-n)
+	if wait := tat.Add(-tolerance).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+func gcraResult(ok bool, wait time.Duration, tat time.Time, limit Limit, now time.Time) Result {
+	reset := tat.Sub(now)
+	full := limit.Rate * time.Duration(limit.Burst)
+	remaining := 0
+	if limit.Rate > 0 && reset < full {
+		remaining = int((full - reset) / limit.Rate)
+	}
+	return Result{OK: ok, Wait: wait, Remaining: remaining, Reset: reset}
+}
+
+func (s *gcraStore) kind() string {
+	return "gcra"
+}
+
+func (s *gcraStore) snapshot() interface{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	state := make(map[string]time.Time, len(s.clients))
+	for client, tat := range s.clients {
+		state[client] = tat
+	}
+	return state
+}
+
+func (s *gcraStore) restore(data json.RawMessage) error {
+	var state map[string]time.Time
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	if state == nil {
+		state = make(map[string]time.Time)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.clients = state
+	return nil
+}
@@ -0,0 +1,55 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGCRA(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithGCRA(), WithBurst(3), WithClock(clock))
+	for i := 0; i < 3; i++ {
+		if !throttle.Allow("alice") {
+			t.Errorf("request %d: expected to be allowed within burst", i)
+		}
+	}
+	if throttle.Allow("alice") {
+		t.Errorf("request beyond burst: expected to be rejected")
+	}
+
+	clock.Advance(100 * time.Millisecond)
+	if !throttle.Allow("alice") {
+		t.Errorf("request after one rate: expected to be allowed")
+	}
+	if throttle.Allow("alice") {
+		t.Errorf("second request after one rate: expected to be rejected")
+	}
+}
+
+func TestGCRAStore(t *testing.T) {
+	clock := newFakeClock()
+	store := newGCRAStore(clock)
+	limit := Limit{Rate: 100 * time.Millisecond, Burst: 2}
+	ctx := context.Background()
+
+	expected := []Result{
+		{OK: true, Wait: 0, Remaining: 1, Reset: 100 * time.Millisecond},
+		{OK: true, Wait: 0, Remaining: 0, Reset: 200 * time.Millisecond},
+		{OK: true, Wait: 100 * time.Millisecond, Remaining: 0, Reset: 300 * time.Millisecond},
+		{OK: false, Wait: 200 * time.Millisecond, Remaining: 0, Reset: 300 * time.Millisecond},
+	}
+	for i, exp := range expected {
+		result, _ := store.TryConsume(ctx, "alice", limit, 1, 150*time.Millisecond)
+		if result != exp {
+			t.Errorf("request %d: expected %+v, got %+v", i, exp, result)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		store.Refund(ctx, "alice", limit, 1)
+	}
+	if n := store.Len(); n != 0 {
+		t.Errorf("full bucket after refunds: expected client to be forgotten, %d left", n)
+	}
+}
@@ -0,0 +1,61 @@
+// Package ginmw adapts the middleware of a throttle.Throttle to gin, so that
+// it can be used for the whole engine, a group, or single routes:
+//
+//	r := gin.New()
+//	r.Use(ginmw.Limit(api, nil))
+//	r.POST("/login", ginmw.Limit(login, ginmw.ByRoute(nil)), handleLogin)
+//
+// Requests are throttled like by throttle.Throttle.Middleware, including its
+// response headers; throttled requests are aborted.
+package ginmw
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patrickbucher/throttle"
+)
+
+// KeyFunc derives the client key from a request.
+type KeyFunc func(c *gin.Context) string
+
+// ClientIP keys requests by the client IP determined by gin, which respects
+// the engine's trusted proxies.
+func ClientIP(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// Limit returns a gin middleware throttling requests using t, keyed by keyFn.
+// If keyFn is nil, ClientIP is used.
+func Limit(t *throttle.Throttle, keyFn KeyFunc) gin.HandlerFunc {
+	if keyFn == nil {
+		keyFn = ClientIP
+	}
+	return func(c *gin.Context) {
+		passed := false
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			passed = true
+			c.Request = r
+			c.Next()
+		})
+		t.Middleware(next, func(*http.Request) string {
+			return keyFn(c)
+		}).ServeHTTP(c.Writer, c.Request)
+		if !passed {
+			c.Abort()
+		}
+	}
+}
+
+// ByRoute keys requests by the key derived using keyFn and the full path of
+// the gin route matched, e.g. "/users/:id", so that a client gets a bucket per
+// route rather than one for all routes sharing the Throttle. If keyFn is nil,
+// ClientIP is used.
+func ByRoute(keyFn KeyFunc) KeyFunc {
+	if keyFn == nil {
+		keyFn = ClientIP
+	}
+	return func(c *gin.Context) string {
+		return keyFn(c) + " " + c.FullPath()
+	}
+}
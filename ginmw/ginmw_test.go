@@ -0,0 +1,51 @@
+package ginmw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patrickbucher/throttle"
+)
+
+func TestLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := throttle.New(1*time.Hour, throttle.WithMaxWait(time.Millisecond))
+	defer limiter.Close()
+	handled := 0
+	ok := func(c *gin.Context) {
+		handled++
+		c.Status(http.StatusOK)
+	}
+	r := gin.New()
+	r.GET("/users/:id", Limit(limiter, ByRoute(nil)), ok)
+	r.GET("/posts/:id", Limit(limiter, ByRoute(nil)), ok)
+	r.GET("/health", ok)
+
+	for _, testCase := range []struct {
+		path   string
+		status int
+	}{
+		{"/users/1", http.StatusOK},
+		{"/users/2", http.StatusTooManyRequests},
+		{"/posts/1", http.StatusOK},
+		{"/health", http.StatusOK},
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, testCase.path, nil))
+		if rec.Code != testCase.status {
+			t.Errorf("%s: expected status %d, got %d", testCase.path, testCase.status, rec.Code)
+		}
+		if testCase.status == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+			t.Errorf("%s: expected Retry-After header", testCase.path)
+		}
+	}
+	if handled != 3 {
+		t.Errorf("expected 3 requests handled, got %d", handled)
+	}
+	if stats := limiter.Stats("192.0.2.1 /users/:id"); stats.Allowed != 1 || stats.Rejected != 1 {
+		t.Errorf("expected requests keyed by route, got %+v", stats)
+	}
+}
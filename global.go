@@ -0,0 +1,25 @@
+package throttle
+
+import "time"
+
+// globalKey is the key of the bucket shared by all clients in the Store.
+const globalKey = "\x00global"
+
+// GlobalTier is the Tier of a ThrottledError for a request rejected because of
+// the limit set by WithGlobalLimit.
+const GlobalTier = "global"
+
+// WithGlobalLimit caps the requests of all clients together, in addition to
+// the limit of every single client: a request has to acquire a token from both
+// its client's bucket and a global bucket, which spawns a token once per rate
+// and holds up to burst tokens. The global bucket is kept in the Throttle's
+// Store, so that Throttles sharing a Store share their global limit as well.
+// Values of burst lower than 1 are treated as 1.
+func WithGlobalLimit(rate time.Duration, burst int) Option {
+	return func(t *Throttle) {
+		if burst < 1 {
+			burst = 1
+		}
+		t.global = &Limit{Rate: rate, Burst: burst}
+	}
+}
@@ -0,0 +1,49 @@
+package throttle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGlobalLimit(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithGlobalLimit(50*time.Millisecond, 2), WithClock(clock))
+
+	for _, client := range []string{"alice", "bob"} {
+		if !throttle.Allow(client) {
+			t.Errorf("%s: expected to be allowed", client)
+		}
+	}
+	if throttle.Allow("carol") {
+		t.Errorf("carol: expected to be rejected by global limit")
+	}
+
+	// the client's token is refunded if the global bucket is empty
+	clock.Advance(100 * time.Millisecond)
+	throttle.Allow("alice")
+	throttle.Allow("bob")
+	if throttle.Allow("carol") {
+		t.Errorf("carol: expected to be rejected by global limit again")
+	}
+	clock.Advance(50 * time.Millisecond)
+	if !throttle.Allow("carol") {
+		t.Errorf("carol: expected to be allowed after a global token was spawned")
+	}
+}
+
+func TestGlobalLimitWait(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(10*time.Millisecond, WithBurst(3), WithGlobalLimit(1*time.Second, 1), WithClock(clock))
+	if err := throttle.Wait("alice"); err != nil {
+		t.Fatalf("first request: expected no error, got %v", err)
+	}
+	err := throttle.Wait("bob")
+	var throttledErr *ThrottledError
+	if !errors.As(err, &throttledErr) || throttledErr.Tier != GlobalTier || throttledErr.Rate != 1*time.Second {
+		t.Errorf("second request: expected to be throttled at global rate, got %v", err)
+	}
+	if err := throttle.WaitN("alice", 2); !errors.Is(err, ErrBurstExceeded) {
+		t.Errorf("request beyond global burst: expected %v, got %v", ErrBurstExceeded, err)
+	}
+}
@@ -0,0 +1,153 @@
+package throttle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Gossip shares the requests a Throttle allows with the Throttles of other
+// instances of a service, so that all instances enforce an approximately
+// global limit per client without an external Store: every instance
+// periodically sends the number of requests it has allowed per client to its
+// peers, which take the tokens of these requests from their own buckets.
+//
+//	g := throttle.NewGossip([]string{"http://10.0.0.2:8080/gossip"}, 1*time.Second)
+//	t := throttle.New(100*time.Millisecond, throttle.WithGossip(g))
+//	http.Handle("POST /gossip", g)
+//
+// The limit is only enforced approximately: requests allowed by different
+// instances within the same interval are only accounted for at its end, and
+// reports lost on the way are not resent. A client owes its peers at most one
+// full bucket of tokens. The handler must only be reachable by the peers.
+type Gossip struct {
+	peers    []string
+	interval time.Duration
+
+	// Client sends the reports to the peers. If nil, http.DefaultClient is
+	// used.
+	Client *http.Client
+
+	throttle *Throttle
+	mutex    sync.Mutex
+	allowed  map[string]int
+}
+
+// gossipReport is the report sent to the peers.
+type gossipReport struct {
+	Allowed map[string]int `json:"allowed"`
+}
+
+// NewGossip creates a Gossip reporting to the peers, given as the URLs of
+// their Gossip handlers, once per interval.
+func NewGossip(peers []string, interval time.Duration) *Gossip {
+	return &Gossip{
+		peers:    peers,
+		interval: interval,
+		allowed:  make(map[string]int),
+	}
+}
+
+// WithGossip makes the Throttle share its requests with its peers using the
+// given Gossip, which must only be used by one Throttle. Reports are sent
+// until the Throttle is closed.
+func WithGossip(g *Gossip) Option {
+	return func(t *Throttle) {
+		g.throttle = t
+		t.gossip = g
+		t.onAllow = append(t.onAllow, func(client string, waited time.Duration) {
+			g.mutex.Lock()
+			defer g.mutex.Unlock()
+			g.allowed[client]++
+		})
+	}
+}
+
+// ServeHTTP receives the report of a peer.
+func (g *Gossip) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var report gossipReport
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&report); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for client, n := range report.Allowed {
+		g.charge(r.Context(), client, n)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// charge takes the tokens of n requests of the client allowed by a peer from
+// all the client's levels, reserving the tokens not available yet, but at
+// most one full bucket.
+func (g *Gossip) charge(ctx context.Context, client string, n int) {
+	t := g.throttle
+	if exempt, _ := t.check(client); exempt || n < 1 {
+		return
+	}
+	for _, l := range t.levels(client) {
+		maxWait := l.limit.Rate * time.Duration(l.limit.Burst)
+		for left := n; left > 0; {
+			take := min(left, l.limit.Burst)
+			result, err := t.store.TryConsume(ctx, l.key, l.limit, take, maxWait)
+			if err != nil || !result.OK {
+				break
+			}
+			left -= take
+		}
+	}
+}
+
+// run sends reports to the peers once per interval until the Throttle is
+// closed.
+func (g *Gossip) run() {
+	for {
+		select {
+		case <-g.throttle.clock.After(g.interval):
+			g.flush(context.Background())
+		case <-g.throttle.done:
+			return
+		}
+	}
+}
+
+// flush sends the requests allowed since the last report to the peers.
+func (g *Gossip) flush(ctx context.Context) {
+	g.mutex.Lock()
+	allowed := g.allowed
+	g.allowed = make(map[string]int, len(allowed))
+	g.mutex.Unlock()
+	if len(allowed) == 0 {
+		return
+	}
+	body, err := json.Marshal(gossipReport{Allowed: allowed})
+	if err != nil {
+		return
+	}
+	client := g.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	ctx, cancel := context.WithTimeout(ctx, g.interval)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, peer := range g.peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, peer, bytes.NewReader(body))
+			if err != nil {
+				return
+			}
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := client.Do(req)
+			if err != nil {
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+}
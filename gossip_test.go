@@ -0,0 +1,62 @@
+package throttle
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGossip(t *testing.T) {
+	clock := newFakeClock()
+	peer := NewGossip(nil, time.Hour)
+	remote := New(1*time.Second, WithBurst(3), WithClock(clock), WithMaxWait(time.Millisecond), WithGossip(peer))
+	defer remote.Close()
+	server := httptest.NewServer(peer)
+	defer server.Close()
+
+	g := NewGossip([]string{server.URL}, time.Hour)
+	local := New(1*time.Second, WithBurst(3), WithClock(clock), WithMaxWait(time.Millisecond), WithGossip(g))
+	defer local.Close()
+
+	for i := 0; i < 2; i++ {
+		if !local.Allow("alice") {
+			t.Fatalf("request %d of alice throttled locally", i)
+		}
+	}
+	g.flush(context.Background())
+
+	if !remote.Allow("alice") {
+		t.Fatal("third request of alice throttled by peer")
+	}
+	if remote.Allow("alice") {
+		t.Error("fourth request of alice allowed by peer, requests reported were not charged")
+	}
+	if !remote.Allow("bob") {
+		t.Error("request of bob throttled by peer")
+	}
+
+	// nothing new to report
+	g.flush(context.Background())
+	clock.Advance(1 * time.Second)
+	if !remote.Allow("alice") {
+		t.Error("request of alice throttled by peer after a token was spawned")
+	}
+}
+
+func TestGossipDebt(t *testing.T) {
+	clock := newFakeClock()
+	g := NewGossip(nil, time.Hour)
+	throttle := New(1*time.Second, WithBurst(2), WithClock(clock), WithMaxWait(time.Millisecond), WithGossip(g))
+	defer throttle.Close()
+
+	g.charge(context.Background(), "alice", 100)
+	clock.Advance(2 * time.Second)
+	if throttle.Allow("alice") {
+		t.Error("request of alice allowed while owing a bucket of tokens")
+	}
+	clock.Advance(1 * time.Second)
+	if !throttle.Allow("alice") {
+		t.Error("request of alice throttled, more than one bucket of tokens owed")
+	}
+}
@@ -0,0 +1,45 @@
+package throttle
+
+// groupPrefix starts the keys of the buckets shared by the members of a
+// Group, which cannot collide with the keys of clients.
+const groupPrefix = "\x00group\x00"
+
+// Group makes the given clients draw their tokens from the same bucket, e.g.
+// all the IP addresses of one customer, so that a client cannot multiply its
+// limit by using several keys. The bucket of the group is used for the limits
+// of WithTier, too; the quotas of WithTierFunc and the global limit are not
+// affected. The group's bucket is refilled at the request rate of the member
+// whose request takes the tokens. A client is a member of at most one group:
+// adding it to another group moves it. Clients that are not members keep
+// their own buckets.
+func (t *Throttle) Group(name string, members ...string) {
+	t.accessMutex.Lock()
+	defer t.accessMutex.Unlock()
+	if t.groups == nil {
+		t.groups = make(map[string]string)
+	}
+	for _, member := range members {
+		t.groups[t.key(member)] = name
+	}
+}
+
+// Ungroup removes the given clients from their groups, so that they get their
+// own buckets again.
+func (t *Throttle) Ungroup(members ...string) {
+	t.accessMutex.Lock()
+	defer t.accessMutex.Unlock()
+	for _, member := range members {
+		delete(t.groups, t.key(member))
+	}
+}
+
+// bucketKey returns the key of the client's bucket: the key of its group's
+// bucket if it is a member of a group, or the client itself otherwise.
+func (t *Throttle) bucketKey(client string) string {
+	t.accessMutex.RLock()
+	defer t.accessMutex.RUnlock()
+	if name, ok := t.groups[client]; ok {
+		return groupPrefix + name
+	}
+	return client
+}
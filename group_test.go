@@ -0,0 +1,33 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGroup(t *testing.T) {
+	throttle := New(1*time.Hour, WithBurst(2), WithMaxWait(time.Millisecond))
+	defer throttle.Close()
+	throttle.Group("acme", "10.0.0.1", "10.0.0.2")
+
+	if !throttle.Allow("10.0.0.1") || !throttle.Allow("10.0.0.2") {
+		t.Fatal("first requests of the group throttled")
+	}
+	if throttle.Allow("10.0.0.1") || throttle.Allow("10.0.0.2") {
+		t.Error("members of the group got more than the group's burst")
+	}
+	if !throttle.Allow("10.0.0.3") {
+		t.Error("client outside of the group throttled")
+	}
+	if got := throttle.Remaining("10.0.0.2"); got != 0 {
+		t.Errorf("member has %d tokens remaining, want 0", got)
+	}
+
+	throttle.Ungroup("10.0.0.2")
+	if !throttle.Allow("10.0.0.2") {
+		t.Error("client removed from the group throttled")
+	}
+	if throttle.Allow("10.0.0.1") {
+		t.Error("remaining member of the group got a token back")
+	}
+}
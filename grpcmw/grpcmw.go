@@ -0,0 +1,154 @@
+// Package grpcmw provides gRPC interceptors that throttle incoming RPCs, or
+// outgoing ones on the client side, using a throttle.Throttle.
+package grpcmw
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/patrickbucher/throttle"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// KeyFunc derives the client key from the context of an incoming RPC and the
+// full name of the method called.
+type KeyFunc func(ctx context.Context, fullMethod string) string
+
+// PeerAddr keys RPCs by the host part of the peer's address.
+func PeerAddr(ctx context.Context, fullMethod string) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	addr := p.Addr.String()
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// Metadata keys RPCs by the first value of the incoming metadata entry with
+// the given name, e.g. an API key. RPCs without such an entry share the empty
+// key.
+func Metadata(name string) KeyFunc {
+	return func(ctx context.Context, fullMethod string) string {
+		values := metadata.ValueFromIncomingContext(ctx, name)
+		if len(values) == 0 {
+			return ""
+		}
+		return values[0]
+	}
+}
+
+// UnaryServerInterceptor returns an interceptor that waits for a token of the
+// client derived using keyFn before handling a unary RPC. If no token is
+// acquired in time, the RPC fails with codes.ResourceExhausted, and with
+// codes.Unavailable once the Throttle has been closed, or while it is paused
+// or being drained.
+// If keyFn is nil, PeerAddr is used.
+func UnaryServerInterceptor(t *throttle.Throttle, keyFn KeyFunc) grpc.UnaryServerInterceptor {
+	if keyFn == nil {
+		keyFn = PeerAddr
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		if err := wait(ctx, t, keyFn(ctx, info.FullMethod)); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns an interceptor that waits for a token of the
+// client derived using keyFn before handling a streaming RPC. If no token is
+// acquired in time, the RPC fails with codes.ResourceExhausted, and with
+// codes.Unavailable once the Throttle has been closed, or while it is paused
+// or being drained.
+// If keyFn is nil, PeerAddr is used.
+func StreamServerInterceptor(t *throttle.Throttle, keyFn KeyFunc) grpc.StreamServerInterceptor {
+	if keyFn == nil {
+		keyFn = PeerAddr
+	}
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		if err := wait(ctx, t, keyFn(ctx, info.FullMethod)); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// ClientKeyFunc derives the key of an outgoing RPC from its context, the
+// connection it is sent over, and the full name of the method called.
+type ClientKeyFunc func(ctx context.Context, cc *grpc.ClientConn, fullMethod string) string
+
+// Target keys outgoing RPCs by the target of their connection, e.g.
+// "dns:///orders:443", so that every service called is throttled on its own.
+func Target(ctx context.Context, cc *grpc.ClientConn, fullMethod string) string {
+	return cc.Target()
+}
+
+// Method keys outgoing RPCs by the target of their connection and the full
+// name of the method called, so that every method is throttled on its own.
+func Method(ctx context.Context, cc *grpc.ClientConn, fullMethod string) string {
+	return cc.Target() + fullMethod
+}
+
+// UnaryClientInterceptor returns an interceptor that waits for a token of the
+// key derived using keyFn before sending a unary RPC, so that a service does
+// not overwhelm the services it calls. If no token is acquired in time, the
+// RPC is not sent and fails with codes.ResourceExhausted, and with
+// codes.Unavailable once the Throttle has been closed, or while it is paused
+// or being drained.
+// If keyFn is nil, Target is used.
+func UnaryClientInterceptor(t *throttle.Throttle, keyFn ClientKeyFunc) grpc.UnaryClientInterceptor {
+	if keyFn == nil {
+		keyFn = Target
+	}
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if err := wait(ctx, t, keyFn(ctx, cc, method)); err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor returns an interceptor that waits for a token of the
+// key derived using keyFn before opening a stream, like
+// UnaryClientInterceptor. Messages sent on the stream are not throttled.
+func StreamClientInterceptor(t *throttle.Throttle, keyFn ClientKeyFunc) grpc.StreamClientInterceptor {
+	if keyFn == nil {
+		keyFn = Target
+	}
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+		streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if err := wait(ctx, t, keyFn(ctx, cc, method)); err != nil {
+			return nil, err
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// wait waits for a token and translates errors into gRPC status errors.
+func wait(ctx context.Context, t *throttle.Throttle, client string) error {
+	err := t.WaitContext(ctx, client)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, throttle.ErrThrottled) {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if errors.Is(err, throttle.ErrClosed) || errors.Is(err, throttle.ErrPaused) ||
+		errors.Is(err, throttle.ErrDraining) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.FromContextError(err).Err()
+}
@@ -0,0 +1,158 @@
+package grpcmw
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/patrickbucher/throttle"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func peerContext(addr string) context.Context {
+	tcpAddr, _ := net.ResolveTCPAddr("tcp", addr)
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: tcpAddr})
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := UnaryServerInterceptor(throttle.New(1*time.Second, throttle.WithMaxWait(10*time.Millisecond)), nil)
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+
+	expected := []codes.Code{codes.OK, codes.ResourceExhausted}
+	for i, code := range expected {
+		_, err := interceptor(peerContext("10.0.0.1:1234"), nil, info, handler)
+		if got := status.Code(err); got != code {
+			t.Errorf("request %d: expected code %v, got %v", i, code, got)
+		}
+	}
+
+	// other peers are throttled independently
+	if _, err := interceptor(peerContext("10.0.0.2:1234"), nil, info, handler); err != nil {
+		t.Errorf("other peer: expected no error, got %v", err)
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	interceptor := StreamServerInterceptor(throttle.New(1*time.Second, throttle.WithMaxWait(10*time.Millisecond)), Metadata("api-key"))
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"}
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		return nil
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("api-key", "alice"))
+
+	expected := []codes.Code{codes.OK, codes.ResourceExhausted}
+	for i, code := range expected {
+		err := interceptor(nil, &serverStream{ctx: ctx}, info, handler)
+		if got := status.Code(err); got != code {
+			t.Errorf("stream %d: expected code %v, got %v", i, code, got)
+		}
+	}
+}
+
+func newClientConn(t *testing.T, target string) *grpc.ClientConn {
+	t.Helper()
+	cc, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return cc
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	interceptor := UnaryClientInterceptor(throttle.New(1*time.Second, throttle.WithMaxWait(10*time.Millisecond)), Method)
+	orders, users := newClientConn(t, "passthrough:///orders:443"), newClientConn(t, "passthrough:///users:443")
+	sent := 0
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		sent++
+		return nil
+	}
+
+	expected := []codes.Code{codes.OK, codes.ResourceExhausted}
+	for i, code := range expected {
+		err := interceptor(context.Background(), "/orders.Service/Get", nil, nil, orders, invoker)
+		if got := status.Code(err); got != code {
+			t.Errorf("call %d: expected code %v, got %v", i, code, got)
+		}
+	}
+
+	// other methods and targets are throttled independently
+	if err := interceptor(context.Background(), "/orders.Service/List", nil, nil, orders, invoker); err != nil {
+		t.Errorf("other method: expected no error, got %v", err)
+	}
+	if err := interceptor(context.Background(), "/orders.Service/Get", nil, nil, users, invoker); err != nil {
+		t.Errorf("other target: expected no error, got %v", err)
+	}
+	if sent != 3 {
+		t.Errorf("expected 3 calls sent, got %d", sent)
+	}
+}
+
+func TestStreamClientInterceptor(t *testing.T) {
+	interceptor := StreamClientInterceptor(throttle.New(1*time.Second, throttle.WithMaxWait(10*time.Millisecond)), nil)
+	cc := newClientConn(t, "passthrough:///orders:443")
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return nil, nil
+	}
+
+	expected := []codes.Code{codes.OK, codes.ResourceExhausted}
+	for i, code := range expected {
+		_, err := interceptor(context.Background(), &grpc.StreamDesc{}, cc, "/orders.Service/Watch", streamer)
+		if got := status.Code(err); got != code {
+			t.Errorf("stream %d: expected code %v, got %v", i, code, got)
+		}
+	}
+}
+
+func TestUnaryServerInterceptorDraining(t *testing.T) {
+	th := throttle.New(200*time.Millisecond, throttle.WithMaxWait(1*time.Second))
+	interceptor := UnaryServerInterceptor(th, nil)
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	th.Allow("10.0.0.1")
+	waited := make(chan error)
+	go func() {
+		waited <- th.Wait("10.0.0.1")
+	}()
+	for th.Stats("10.0.0.1").Waiters < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	drained := make(chan error)
+	go func() {
+		drained <- th.Drain(context.Background())
+	}()
+	for !th.Draining() {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err := interceptor(peerContext("10.0.0.1:1234"), nil, info, handler)
+	if got := status.Code(err); got != codes.Unavailable {
+		t.Errorf("expected code %v, got %v", codes.Unavailable, got)
+	}
+	if err := <-waited; err != nil {
+		t.Errorf("waiting request: expected no error, got %v", err)
+	}
+	if err := <-drained; err != nil {
+		t.Errorf("expected the throttle to be drained, got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package throttle
+
+import (
+	"context"
+	"time"
+)
+
+// WaitHook is called after a request has waited for a token using Wait,
+// WaitContext, or TryWaitFor, with the request's context, the client, the time
+// the request blocked waiting for its token (0 if the token was available right
+// away), and the error returned to the request, if any.
+type WaitHook func(ctx context.Context, client string, waited time.Duration, err error)
+
+// WithWaitHook adds a hook that is called after every wait, e.g. to trace or
+// log it. Hooks are called synchronously and must therefore be fast.
+func WithWaitHook(hook WaitHook) Option {
+	return func(t *Throttle) {
+		t.waitHooks = append(t.waitHooks, hook)
+	}
+}
+
+// WithOnAllow adds a function that is called whenever a request acquired a
+// token, with the client and the time the request waited for the token.
+// Functions are called synchronously and must therefore be fast.
+func WithOnAllow(f func(client string, waited time.Duration)) Option {
+	return func(t *Throttle) {
+		t.onAllow = append(t.onAllow, f)
+	}
+}
+
+// WithOnReject adds a function that is called whenever a request was rejected
+// because no token could be acquired in time. Functions are called
+// synchronously and must therefore be fast.
+func WithOnReject(f func(client string)) Option {
+	return func(t *Throttle) {
+		t.onReject = append(t.onReject, f)
+	}
+}
+
+// allowed records that a request of the client made at the given time
+// acquired a token after waiting for the given duration.
+func (t *Throttle) allowed(client string, at time.Time, waited time.Duration) {
+	t.metrics.allow(client, at, waited)
+	for _, f := range t.onAllow {
+		f(client, waited)
+	}
+}
+
+// rejected records that a request of the client made at the given time was
+// rejected.
+func (t *Throttle) rejected(client string, at time.Time) {
+	t.metrics.reject(client, at)
+	if t.penalties != nil {
+		t.penalties.offend(client, at)
+	}
+	for _, f := range t.onReject {
+		f(client)
+	}
+}
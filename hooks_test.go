@@ -0,0 +1,63 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type contextKey struct{}
+
+func TestWaitHook(t *testing.T) {
+	type call struct {
+		value  interface{}
+		client string
+		err    error
+	}
+	var calls []call
+	hook := func(ctx context.Context, client string, waited time.Duration, err error) {
+		calls = append(calls, call{ctx.Value(contextKey{}), client, err})
+	}
+	throttle := New(1*time.Second, WithWaitHook(hook), WithMaxWait(10*time.Millisecond))
+
+	ctx := context.WithValue(context.Background(), contextKey{}, "request")
+	throttle.WaitContext(ctx, "alice")
+	throttle.WaitContext(ctx, "alice")
+	throttle.Allow("alice")
+
+	if len(calls) != 2 {
+		t.Fatalf("expected %d calls, got %d", 2, len(calls))
+	}
+	for i, c := range calls {
+		if c.value != "request" || c.client != "alice" {
+			t.Errorf("call %d: expected context and client of request, got %v, %q", i, c.value, c.client)
+		}
+	}
+	if calls[0].err != nil || !errors.Is(calls[1].err, ErrThrottled) {
+		t.Errorf("errors: expected nil and %v, got %v and %v", ErrThrottled, calls[0].err, calls[1].err)
+	}
+}
+
+func TestOnAllowOnReject(t *testing.T) {
+	var allowed, rejected []string
+	onAllow := func(client string, waited time.Duration) {
+		allowed = append(allowed, client)
+	}
+	onReject := func(client string) {
+		rejected = append(rejected, client)
+	}
+	throttle := New(1*time.Second, WithOnAllow(onAllow), WithOnReject(onReject), WithMaxWait(10*time.Millisecond))
+
+	throttle.Wait("alice")
+	throttle.Wait("alice")
+	throttle.Allow("bob")
+	throttle.Allow("bob")
+
+	if len(allowed) != 2 || allowed[0] != "alice" || allowed[1] != "bob" {
+		t.Errorf("allowed: expected [alice bob], got %v", allowed)
+	}
+	if len(rejected) != 2 || rejected[0] != "alice" || rejected[1] != "bob" {
+		t.Errorf("rejected: expected [alice bob], got %v", rejected)
+	}
+}
@@ -0,0 +1,192 @@
+package throttle
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostThrottle throttles requests per host, e.g. for a web crawler that must
+// be polite to every domain it visits. A HostThrottle must not be copied after
+// first use.
+type HostThrottle struct {
+	// Throttle paces the requests, keyed by host.
+	Throttle *Throttle
+
+	// Client sends the requests made using Do, and fetches robots.txt. If
+	// nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Robots makes the HostThrottle fetch the robots.txt of every host before
+	// its first request, and use the host's Crawl-delay for UserAgent, if any,
+	// as the host's request rate.
+	Robots bool
+
+	// UserAgent is the name of the crawler looked up in robots.txt. If no
+	// group of robots.txt matches it, the group for all crawlers is used.
+	UserAgent string
+
+	robots sync.Map
+}
+
+// NewHostThrottle creates a new HostThrottle allowing one request per host in
+// the given request rate.
+func NewHostThrottle(requestRate time.Duration, opts ...Option) *HostThrottle {
+	return &HostThrottle{Throttle: New(requestRate, opts...)}
+}
+
+// Wait waits for a token of the URL's host, like Throttle.Wait does for a
+// client.
+func (h *HostThrottle) Wait(u *url.URL) error {
+	return h.WaitContext(context.Background(), u)
+}
+
+// WaitContext works like Wait, but gives up as soon as ctx is cancelled or its
+// deadline expires.
+func (h *HostThrottle) WaitContext(ctx context.Context, u *url.URL) error {
+	if h.Robots {
+		h.fetchRobots(u)
+	}
+	return h.Throttle.WaitContext(ctx, u.Host)
+}
+
+// Do waits for a token of the request's host and sends the request using the
+// HostThrottle's Client. If no token is acquired in time, the request is not
+// sent, and the Throttle's error is returned.
+func (h *HostThrottle) Do(req *http.Request) (*http.Response, error) {
+	if err := h.WaitContext(req.Context(), req.URL); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return h.client().Do(req)
+}
+
+func (h *HostThrottle) client() *http.Client {
+	if h.Client == nil {
+		return http.DefaultClient
+	}
+	return h.Client
+}
+
+const (
+	// robotsTimeout is the time a HostThrottle waits for a robots.txt.
+	robotsTimeout = 10 * time.Second
+
+	// robotsRetry is the time after which a HostThrottle fetches a robots.txt
+	// again that could not be fetched.
+	robotsRetry = 1 * time.Minute
+)
+
+// robots is the state of the robots.txt of a host.
+type robots struct {
+	mutex   sync.Mutex
+	fetched bool
+	retry   time.Time
+}
+
+// fetchRobots sets the request rate of the URL's host to the Crawl-delay of
+// its robots.txt, which is fetched once per host, independently of the
+// caller's context. Hosts without a robots.txt or a Crawl-delay keep the
+// Throttle's request rate; a robots.txt that could not be fetched, e.g. on a
+// timeout or a server error, is fetched again by a request after robotsRetry.
+func (h *HostThrottle) fetchRobots(u *url.URL) {
+	state, _ := h.robots.LoadOrStore(u.Host, new(robots))
+	r := state.(*robots)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	now := h.Throttle.clock.Now()
+	if r.fetched || now.Before(r.retry) {
+		return
+	}
+	if r.fetched = h.fetchCrawlDelay(u); !r.fetched {
+		r.retry = now.Add(robotsRetry)
+	}
+}
+
+// fetchCrawlDelay fetches the robots.txt of the URL's host, and sets the
+// host's request rate to its Crawl-delay, if any. It reports whether the
+// host answered, with either a robots.txt or a client error.
+func (h *HostThrottle) fetchCrawlDelay(u *url.URL) bool {
+	// the robots.txt is shared by all requests, not just the first caller's
+	ctx, cancel := context.WithTimeout(context.Background(), robotsTimeout)
+	defer cancel()
+	robots := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robots.String(), nil)
+	if err != nil {
+		// no request to the host could be made, do not try again
+		return true
+	}
+	resp, err := h.client().Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		// e.g. no robots.txt at all, unless the server failed
+		return resp.StatusCode < 500
+	}
+	if delay, ok := crawlDelay(io.LimitReader(resp.Body, 1<<20), h.UserAgent); ok {
+		h.Throttle.SetClientRate(u.Host, delay)
+	}
+	return true
+}
+
+// crawlDelay returns the Crawl-delay of robots.txt for the user agent, or the
+// one for all user agents if there is no group for the user agent.
+func crawlDelay(r io.Reader, userAgent string) (time.Duration, bool) {
+	userAgent = strings.ToLower(userAgent)
+	var delay, fallback time.Duration
+	var found, foundFallback bool
+	var agents []string
+	inRules := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		field = strings.ToLower(strings.TrimSpace(field))
+		value = strings.TrimSpace(value)
+		switch field {
+		case "user-agent":
+			if inRules {
+				// a user agent after rules starts a new group
+				agents, inRules = nil, false
+			}
+			agents = append(agents, strings.ToLower(value))
+		case "crawl-delay":
+			inRules = true
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds < 0 {
+				continue
+			}
+			d := time.Duration(seconds * float64(time.Second))
+			for _, agent := range agents {
+				switch {
+				case agent == "*":
+					fallback, foundFallback = d, true
+				case userAgent != "" && strings.Contains(userAgent, agent):
+					delay, found = d, true
+				}
+			}
+		default:
+			inRules = true
+		}
+	}
+	if found {
+		return delay, true
+	}
+	return fallback, foundFallback
+}
@@ -0,0 +1,127 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHostThrottle(t *testing.T) {
+	hosts := NewHostThrottle(1*time.Second, WithMaxWait(10*time.Millisecond))
+	for _, raw := range []string{"https://example.com/a", "https://example.org/a"} {
+		u, _ := url.Parse(raw)
+		if err := hosts.Wait(u); err != nil {
+			t.Errorf("%s: expected no error, got %v", raw, err)
+		}
+	}
+	u, _ := url.Parse("https://example.com/b")
+	if err := hosts.Wait(u); !errors.Is(err, ErrThrottled) {
+		t.Errorf("same host again: expected %v, got %v", ErrThrottled, err)
+	}
+}
+
+func TestHostThrottleDo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	hosts := NewHostThrottle(1*time.Second, WithMaxWait(10*time.Millisecond))
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := hosts.Do(req)
+	if err != nil {
+		t.Fatalf("first request: expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	if _, err := hosts.Do(req); !errors.Is(err, ErrThrottled) {
+		t.Errorf("second request: expected %v, got %v", ErrThrottled, err)
+	}
+}
+
+func TestHostThrottleRobots(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nCrawl-delay: 5\n"))
+		}
+	}))
+	defer server.Close()
+
+	hosts := NewHostThrottle(1*time.Second, WithMaxWait(10*time.Millisecond))
+	hosts.Robots = true
+	u, _ := url.Parse(server.URL + "/page")
+	if err := hosts.Wait(u); err != nil {
+		t.Fatalf("first request: expected no error, got %v", err)
+	}
+	if rate := hosts.Throttle.rate(u.Host); rate != 5*time.Second {
+		t.Errorf("expected Crawl-delay of %v as rate, got %v", 5*time.Second, rate)
+	}
+}
+
+func TestHostThrottleRobotsRetry(t *testing.T) {
+	var fetched atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" && fetched.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("User-agent: *\nCrawl-delay: 5\n"))
+	}))
+	defer server.Close()
+
+	clock := newFakeClock()
+	hosts := NewHostThrottle(1*time.Second, WithMaxWait(10*time.Millisecond), WithClock(clock))
+	hosts.Robots = true
+	u, _ := url.Parse(server.URL + "/page")
+
+	// the first caller giving up does not prevent the robots.txt from being
+	// fetched, nor does a server error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	hosts.WaitContext(ctx, u)
+	if rate := hosts.Throttle.rate(u.Host); rate != 1*time.Second {
+		t.Errorf("server error: expected the Throttle's rate %v, got %v", 1*time.Second, rate)
+	}
+	hosts.WaitContext(ctx, u)
+	if n := fetched.Load(); n != 1 {
+		t.Errorf("before retrying: expected robots.txt to be fetched %d times, got %d", 1, n)
+	}
+	clock.Advance(robotsRetry)
+	hosts.WaitContext(ctx, u)
+	if rate := hosts.Throttle.rate(u.Host); rate != 5*time.Second {
+		t.Errorf("after retrying: expected Crawl-delay of %v as rate, got %v", 5*time.Second, rate)
+	}
+}
+
+func TestCrawlDelay(t *testing.T) {
+	robots := `# example
+User-agent: *
+Disallow: /private
+Crawl-delay: 10
+
+User-agent: FooBot
+User-agent: BarBot
+Crawl-delay: 0.5
+`
+	tests := []struct {
+		userAgent string
+		delay     time.Duration
+	}{
+		{"", 10 * time.Second},
+		{"OtherBot/1.0", 10 * time.Second},
+		{"BarBot/2.1", 500 * time.Millisecond},
+	}
+	for _, test := range tests {
+		delay, ok := crawlDelay(strings.NewReader(robots), test.userAgent)
+		if !ok || delay != test.delay {
+			t.Errorf("%q: expected %v, got %v (%v)", test.userAgent, test.delay, delay, ok)
+		}
+	}
+	if _, ok := crawlDelay(strings.NewReader("User-agent: *\nDisallow:\n"), "FooBot"); ok {
+		t.Errorf("without Crawl-delay: expected none to be found")
+	}
+}
@@ -0,0 +1,138 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Middleware returns a handler that waits for a token of the client derived
+// from the request using keyFn before passing on the request to next. If no
+// token is acquired in time, the request is rejected with status 429 (Too Many
+// Requests) and a Retry-After header, unless WithRejectionHandler says
+// otherwise. If the Throttle has been closed, paused, or is being drained, the
+// request is rejected with status 503 (Service Unavailable), and if its Store
+// fails, with status 500 (Internal Server Error). If keyFn is nil, requests
+// are keyed by the host part of their remote address; ParseKey returns a keyFn
+// for other attributes of the request. Every request takes the number of
+// tokens set by WithCostFunc; a request costing more than the burst is
+// rejected with status 429, but without a Retry-After header, and so is a
+// request of a client having the maximum number of requests in flight set by
+// WithMaxInFlight. Requests matched by WithBypass are passed on right away.
+//
+// Every response carries the RateLimit-Limit (the client's burst),
+// RateLimit-Remaining (the tokens left), and RateLimit-Reset (the seconds until
+// all tokens are available again) headers, so that clients can pace
+// themselves. Clients beyond the soft limit set by WithSoftLimit also get a
+// RateLimit-Warning header.
+func (t *Throttle) Middleware(next http.Handler, keyFn func(*http.Request) string) http.Handler {
+	if keyFn == nil {
+		keyFn = remoteHost
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if t.bypassed(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		client := t.key(keyFn(r))
+		release, err := t.begin(r.Context(), client, t.cost(r))
+		if err != nil && r.Context().Err() != nil {
+			// the client went away, nobody is listening
+			return
+		}
+		if errors.Is(err, ErrClosed) || errors.Is(err, ErrPaused) || errors.Is(err, ErrDraining) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if errors.Is(err, ErrBurstExceeded) || errors.Is(err, ErrTooManyInFlight) {
+			// there is no telling when the request could be served
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		var throttledErr *ThrottledError
+		if err != nil && !errors.As(err, &throttledErr) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		t.setRateLimitHeaders(r.Context(), w.Header(), client)
+		if throttledErr != nil {
+			w.Header().Set("Retry-After", seconds(throttledErr.RetryAfter))
+			if t.rejectFn != nil {
+				t.rejectFn(w, r, throttledErr)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		defer release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WithRejectionHandler makes Middleware respond to throttled requests using
+// handle instead of a plain text response with status 429, e.g. to send a
+// JSON problem detail. The RateLimit and Retry-After headers have been set
+// when handle is called, and can be changed. Requests rejected for other
+// reasons than a *ThrottledError are not passed to handle.
+func WithRejectionHandler(handle func(w http.ResponseWriter, r *http.Request, err *ThrottledError)) Option {
+	return func(t *Throttle) {
+		t.rejectFn = handle
+	}
+}
+
+// WithBypass makes Middleware pass on the requests for which bypass returns
+// true without throttling them, e.g. health checks and probes of an
+// orchestrator, or requests bearing a token of a trusted service. Bypassed
+// requests take no token, are not counted in the stats, and get no RateLimit
+// headers. If WithBypass is given more than once, a request bypassed by any of
+// the functions is.
+func WithBypass(bypass func(*http.Request) bool) Option {
+	return func(t *Throttle) {
+		t.bypass = append(t.bypass, bypass)
+	}
+}
+
+// bypassed reports whether the request is not to be throttled.
+func (t *Throttle) bypassed(r *http.Request) bool {
+	for _, bypass := range t.bypass {
+		if bypass(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteHost returns the host part of the request's remote address.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// setRateLimitHeaders sets the RateLimit headers for the given client. The
+// headers are omitted if the Throttle's Store fails.
+func (t *Throttle) setRateLimitHeaders(ctx context.Context, header http.Header, client string) {
+	limit := t.limit(client)
+	result, err := t.store.Peek(ctx, t.bucketKey(client), limit)
+	if err != nil {
+		return
+	}
+	header.Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", seconds(result.Reset))
+	if t.beyondSoftLimit(limit, result.Remaining) {
+		header.Set("RateLimit-Warning", fmt.Sprintf("%d of %d requests left", result.Remaining, limit.Burst))
+	}
+}
+
+// seconds formats d as a number of seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
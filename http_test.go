@@ -0,0 +1,167 @@
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	throttle := New(1*time.Second, WithMaxWait(10*time.Millisecond))
+	handler := throttle.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), nil)
+
+	expected := []int{http.StatusOK, http.StatusTooManyRequests}
+	for i, status := range expected {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != status {
+			t.Errorf("request %d: expected status %d, got %d", i, status, rec.Code)
+		}
+		if status == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "1" {
+			t.Errorf("request %d: expected Retry-After 1, got %q", i, rec.Header().Get("Retry-After"))
+		}
+	}
+}
+
+func TestMiddlewareKeyFn(t *testing.T) {
+	throttle := New(1*time.Second, WithMaxWait(10*time.Millisecond))
+	keyFn := func(r *http.Request) string {
+		return r.Header.Get("X-API-Key")
+	}
+	handler := throttle.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), keyFn)
+
+	for _, key := range []string{"alice", "bob"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("client %s: expected status %d, got %d", key, http.StatusOK, rec.Code)
+		}
+	}
+}
+
+func TestMiddlewareRateLimitHeaders(t *testing.T) {
+	throttle := New(1*time.Second, WithBurst(2), WithMaxWait(10*time.Millisecond))
+	handler := throttle.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), nil)
+
+	expected := []struct {
+		status    int
+		remaining string
+		reset     string
+	}{
+		{http.StatusOK, "1", "1"},
+		{http.StatusOK, "0", "2"},
+		{http.StatusTooManyRequests, "0", "2"},
+	}
+	for i, exp := range expected {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != exp.status {
+			t.Errorf("request %d: expected status %d, got %d", i, exp.status, rec.Code)
+		}
+		headers := map[string]string{
+			"RateLimit-Limit":     "2",
+			"RateLimit-Remaining": exp.remaining,
+			"RateLimit-Reset":     exp.reset,
+		}
+		for name, value := range headers {
+			if got := rec.Header().Get(name); got != value {
+				t.Errorf("request %d: expected %s %q, got %q", i, name, value, got)
+			}
+		}
+	}
+}
+
+func TestMiddlewareClosed(t *testing.T) {
+	throttle := New(1 * time.Second)
+	throttle.Close()
+	handler := throttle.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+}
+
+func TestMiddlewareDeadline(t *testing.T) {
+	throttle := New(1*time.Second, WithMaxWait(1*time.Minute))
+	throttle.Allow("192.0.2.1")
+	handler := throttle.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status %d, got %d", http.StatusTooManyRequests, rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected Retry-After 1, got %q", rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestMiddlewareRejectionHandler(t *testing.T) {
+	throttle := New(1*time.Second, WithMaxWait(10*time.Millisecond),
+		WithRejectionHandler(func(w http.ResponseWriter, r *http.Request, err *ThrottledError) {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, `{"title": "slow down", "client": %q}`, err.Client)
+		}))
+	handler := throttle.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), nil)
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if i == 0 {
+			continue
+		}
+		if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("throttled request: expected custom response, got %d and %q", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if rec.Header().Get("Retry-After") != "1" || !strings.Contains(rec.Body.String(), `"client": "192.0.2.1"`) {
+			t.Errorf("throttled request: expected Retry-After and client, got %q and %s", rec.Header().Get("Retry-After"), rec.Body.String())
+		}
+	}
+}
+
+func TestMiddlewareBypass(t *testing.T) {
+	throttle := New(1*time.Second, WithMaxWait(10*time.Millisecond), WithBypass(func(r *http.Request) bool {
+		return r.URL.Path == "/healthz"
+	}))
+	handler := throttle.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), nil)
+
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		if rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "" {
+			t.Errorf("probe %d: expected to bypass the throttle, got %d", i, rec.Code)
+		}
+	}
+	for i, expected := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != expected {
+			t.Errorf("request %d: expected %d, got %d", i, expected, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrTooManyInFlight is returned for requests of a client that already has the
+// maximum number of requests in flight set by WithMaxInFlight.
+var ErrTooManyInFlight = errors.New("too many requests in flight")
+
+// inFlight counts the requests in flight per client.
+type inFlight struct {
+	mutex   sync.Mutex
+	max     int
+	clients map[string]int
+}
+
+// WithMaxInFlight limits the number of requests of every client that are
+// processed at the same time to n, in addition to the request rate, so that
+// slow requests cannot pile up. Only requests made using Acquire, which
+// includes those passing Middleware, are counted. Values of n lower than 1
+// are ignored.
+func WithMaxInFlight(n int) Option {
+	return func(t *Throttle) {
+		if n >= 1 {
+			t.inFlight = &inFlight{max: n, clients: make(map[string]int)}
+		}
+	}
+}
+
+// Acquire works like WaitContext, but also takes one of the client's slots for
+// requests in flight, which has to be given back by calling release once the
+// request has been processed. If all slots are taken, ErrTooManyInFlight is
+// returned right away. Without WithMaxInFlight, release does nothing. If an
+// error is returned, release is nil.
+func (t *Throttle) Acquire(ctx context.Context, client string) (release func(), err error) {
+	return t.begin(ctx, t.key(client), 1)
+}
+
+// begin takes a slot of the client and waits for n of its tokens.
+func (t *Throttle) begin(ctx context.Context, client string, n int) (release func(), err error) {
+	release = func() {}
+	if t.inFlight != nil {
+		if !t.inFlight.take(client) {
+			t.rejected(client, t.clock.Now())
+			return nil, ErrTooManyInFlight
+		}
+		var once sync.Once
+		release = func() {
+			once.Do(func() { t.inFlight.give(client) })
+		}
+	}
+	if err := t.waitN(ctx, client, n); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// InFlight returns the number of the client's requests in flight.
+func (t *Throttle) InFlight(client string) int {
+	if t.inFlight == nil {
+		return 0
+	}
+	t.inFlight.mutex.Lock()
+	defer t.inFlight.mutex.Unlock()
+	return t.inFlight.clients[t.key(client)]
+}
+
+// take takes a slot of the client, if one is left.
+func (f *inFlight) take(client string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.clients[client] >= f.max {
+		return false
+	}
+	f.clients[client]++
+	return true
+}
+
+// give gives back a slot of the client.
+func (f *inFlight) give(client string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.clients[client]--; f.clients[client] <= 0 {
+		delete(f.clients, client)
+	}
+}
@@ -0,0 +1,69 @@
+package throttle
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMaxInFlight(t *testing.T) {
+	throttle := New(1*time.Millisecond, WithBurst(10), WithMaxInFlight(2))
+	ctx := context.Background()
+
+	first, err := throttle.Acquire(ctx, "alice")
+	if err != nil {
+		t.Fatalf("first request: expected no error, got %v", err)
+	}
+	if _, err := throttle.Acquire(ctx, "alice"); err != nil {
+		t.Fatalf("second request: expected no error, got %v", err)
+	}
+	if _, err := throttle.Acquire(ctx, "alice"); err != ErrTooManyInFlight {
+		t.Errorf("third request: expected %v, got %v", ErrTooManyInFlight, err)
+	}
+	if _, err := throttle.Acquire(ctx, "bob"); err != nil {
+		t.Errorf("other client: expected no error, got %v", err)
+	}
+
+	// releasing twice gives back a single slot
+	first()
+	first()
+	if n := throttle.InFlight("alice"); n != 1 {
+		t.Errorf("in flight after release: expected %d, got %d", 1, n)
+	}
+	if _, err := throttle.Acquire(ctx, "alice"); err != nil {
+		t.Errorf("request after release: expected no error, got %v", err)
+	}
+}
+
+func TestMiddlewareMaxInFlight(t *testing.T) {
+	throttle := New(1*time.Millisecond, WithBurst(10), WithMaxInFlight(1))
+	entered := make(chan struct{})
+	proceed := make(chan struct{})
+	handler := throttle.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-proceed
+	}), nil)
+
+	done := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		done <- rec.Code
+	}()
+	<-entered
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("concurrent request: expected status %d, got %d", http.StatusTooManyRequests, rec.Code)
+	}
+	close(proceed)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("first request: expected status %d, got %d", http.StatusOK, code)
+	}
+	if n := throttle.InFlight("192.0.2.1"); n != 0 {
+		t.Errorf("in flight after request: expected %d, got %d", 0, n)
+	}
+}
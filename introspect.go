@@ -0,0 +1,46 @@
+package throttle
+
+import (
+	"context"
+	"time"
+)
+
+// Remaining returns the number of requests the client could make right away,
+// e.g. to tell a user how many calls are left. If the Store fails, 0 is
+// returned.
+func (t *Throttle) Remaining(client string) int {
+	remaining, _, err := t.peek(t.key(client))
+	if err != nil {
+		return 0
+	}
+	return remaining
+}
+
+// NextTokenAt returns the time the client's next token becomes available,
+// which is now or in the past if the client has tokens left. Tokens are not
+// reserved, so another request might take the token first. If the Store
+// fails, the zero time is returned.
+func (t *Throttle) NextTokenAt(client string) time.Time {
+	now := t.clock.Now()
+	_, wait, err := t.peek(t.key(client))
+	if err != nil {
+		return time.Time{}
+	}
+	return now.Add(wait)
+}
+
+// peek returns the tokens left in the client's most constraining level, and
+// the time until a token is available in all of them.
+func (t *Throttle) peek(client string) (remaining int, wait time.Duration, err error) {
+	for i, l := range t.levels(client) {
+		result, err := t.store.Peek(context.Background(), l.key, l.limit)
+		if err != nil {
+			return 0, 0, err
+		}
+		if i == 0 || result.Remaining < remaining {
+			remaining = result.Remaining
+		}
+		wait = max(wait, result.Wait)
+	}
+	return remaining, wait, nil
+}
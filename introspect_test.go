@@ -0,0 +1,25 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRemaining(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Second, WithClock(clock), WithBurst(5), WithTier("minute", 3, 1*time.Minute))
+	if n := throttle.Remaining("alice"); n != 3 {
+		t.Errorf("new client: expected the tier's %d requests remaining, got %d", 3, n)
+	}
+	if at := throttle.NextTokenAt("alice"); !at.Equal(clock.Now()) {
+		t.Errorf("new client: expected next token now, got %v", at.Sub(clock.Now()))
+	}
+
+	throttle.AllowN("alice", 3)
+	if n := throttle.Remaining("alice"); n != 0 {
+		t.Errorf("exhausted tier: expected no requests remaining, got %d", n)
+	}
+	if wait := throttle.NextTokenAt("alice").Sub(clock.Now()); wait != 20*time.Second {
+		t.Errorf("exhausted tier: expected next token in %v, got %v", 20*time.Second, wait)
+	}
+}
@@ -0,0 +1,109 @@
+package throttle
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Reader returns a Reader reading from r at a throughput of bytesPerSec on
+// average. The bytes read take the tokens of a Throttle allowing bursts of up
+// to one second's worth of bytes, one token per chunk of bytes, so that
+// bandwidths of more than a byte per nanosecond can be paced, too. Reader
+// panics if bytesPerSec is not positive.
+func Reader(r io.Reader, bytesPerSec int) io.Reader {
+	return &reader{r: r, throttle: newByteThrottle(bytesPerSec)}
+}
+
+// Writer returns a Writer writing to w at a throughput of bytesPerSec on
+// average, like Reader does for reading.
+func Writer(w io.Writer, bytesPerSec int) io.Writer {
+	return &writer{w: w, throttle: newByteThrottle(bytesPerSec)}
+}
+
+// chunksPerSec is the number of chunks a second's worth of bytes is split
+// into at most, so that the rate of a chunk is far coarser than a nanosecond.
+const chunksPerSec = 1000
+
+// byteThrottle paces bytes using a Throttle spawning one token per chunk.
+type byteThrottle struct {
+	throttle *Throttle
+	chunk    int
+	pending  int
+}
+
+// newByteThrottle returns a byteThrottle passing bytesPerSec bytes per second.
+func newByteThrottle(bytesPerSec int) *byteThrottle {
+	if bytesPerSec < 1 {
+		panic(fmt.Sprintf("throttle: %d bytes per second is not positive", bytesPerSec))
+	}
+	chunk := (bytesPerSec + chunksPerSec - 1) / chunksPerSec
+	rate := time.Duration(chunk) * time.Second / time.Duration(bytesPerSec)
+	return &byteThrottle{
+		throttle: New(rate, WithBurst(max(bytesPerSec/chunk, 1))),
+		chunk:    chunk,
+	}
+}
+
+// burst returns the number of bytes passed at once at most.
+func (b *byteThrottle) burst() int {
+	return b.throttle.burst * b.chunk
+}
+
+// wait waits for the tokens of n bytes. The bytes of a chunk that is not
+// complete yet are carried over to the next call.
+func (b *byteThrottle) wait(n int) error {
+	b.pending += n
+	chunks := b.pending / b.chunk
+	b.pending %= b.chunk
+	if chunks < 1 {
+		return nil
+	}
+	return b.throttle.WaitN("", chunks)
+}
+
+type reader struct {
+	r        io.Reader
+	throttle *byteThrottle
+}
+
+// Read reads up to a burst of bytes and then waits for the tokens of the bytes
+// read, so that reading does not block before data is available.
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > r.throttle.burst() {
+		p = p[:r.throttle.burst()]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.throttle.wait(n); waitErr != nil && err == nil {
+			err = waitErr
+		}
+	}
+	return n, err
+}
+
+type writer struct {
+	w        io.Writer
+	throttle *byteThrottle
+}
+
+// Write waits for the tokens of every burst of bytes before writing it.
+func (w *writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > w.throttle.burst() {
+			chunk = chunk[:w.throttle.burst()]
+		}
+		if err := w.throttle.wait(len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := w.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
@@ -0,0 +1,60 @@
+package throttle
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestReader(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 3000)
+	start := time.Now()
+	read, err := io.ReadAll(Reader(bytes.NewReader(data), 10000))
+	elapsed := time.Since(start)
+	if err != nil || !bytes.Equal(read, data) {
+		t.Fatalf("expected to read %d bytes, got %d, %v", len(data), len(read), err)
+	}
+	// the first 10000 bytes are a burst
+	if elapsed > 100*time.Millisecond {
+		t.Errorf("within burst: expected no delay, took %v", elapsed)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := Writer(&buf, 1000)
+	data := bytes.Repeat([]byte("x"), 1200)
+	start := time.Now()
+	n, err := w.Write(data)
+	elapsed := time.Since(start)
+	if err != nil || n != len(data) || buf.Len() != len(data) {
+		t.Fatalf("expected to write %d bytes, got %d, %v", len(data), n, err)
+	}
+	// 1000 bytes of burst, then 200 bytes at 1000 bytes per second
+	if elapsed < 150*time.Millisecond || elapsed > 1*time.Second {
+		t.Errorf("expected to take about 200ms, took %v", elapsed)
+	}
+}
+
+func TestByteThrottle(t *testing.T) {
+	for _, bytesPerSec := range []int{1, 999, 1000, 1001, 3_000_000_000} {
+		b := newByteThrottle(bytesPerSec)
+		rate := b.throttle.Rate()
+		if rate <= 0 {
+			t.Errorf("%d bytes per second: expected a positive rate, got %v", bytesPerSec, rate)
+			continue
+		}
+		perSec := float64(b.chunk) * float64(time.Second) / float64(rate)
+		if perSec < 0.999*float64(bytesPerSec) || perSec > 1.001*float64(bytesPerSec) {
+			t.Errorf("%d bytes per second: expected to be paced accurately, got %.0f", bytesPerSec, perSec)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("0 bytes per second: expected a panic")
+		}
+	}()
+	newByteThrottle(0)
+}
@@ -0,0 +1,94 @@
+package throttle
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// IPKeyConfig configures the key function returned by IPKey.
+type IPKeyConfig struct {
+	// TrustedProxies are the networks of the reverse proxies in front of the
+	// server. Only requests coming from a trusted proxy have their
+	// X-Forwarded-For and X-Real-IP headers evaluated.
+	TrustedProxies []netip.Prefix
+
+	// IPv4Prefix groups IPv4 clients by networks of the given prefix length,
+	// e.g. 24, so that clients within the same network share a bucket. If 0,
+	// every address is a client of its own.
+	IPv4Prefix int
+
+	// IPv6Prefix groups IPv6 clients like IPv4Prefix does, e.g. with 64.
+	IPv6Prefix int
+}
+
+// IPKey returns a function keying requests by their client's IP address, to
+// be used with Middleware. If the request comes from a trusted proxy, the
+// client is the last address of the X-Forwarded-For header not belonging to a
+// trusted proxy, or the address of the X-Real-IP header if there is no
+// X-Forwarded-For header. Addresses are grouped by network as configured, in
+// which case the key is the network in CIDR notation.
+func IPKey(config IPKeyConfig) func(*http.Request) string {
+	return func(r *http.Request) string {
+		addr, err := netip.ParseAddr(remoteHost(r))
+		if err != nil {
+			return remoteHost(r)
+		}
+		addr = addr.Unmap()
+		if config.trusted(addr) {
+			addr = config.forwardedFor(r, addr)
+		}
+		return config.group(addr)
+	}
+}
+
+// trusted reports whether the address belongs to a trusted proxy.
+func (c IPKeyConfig) trusted(addr netip.Addr) bool {
+	for _, prefix := range c.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the address of the client the request has been
+// forwarded for by the trusted proxy at addr.
+func (c IPKeyConfig) forwardedFor(r *http.Request, addr netip.Addr) netip.Addr {
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if len(forwarded) == 0 {
+		if real, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+			return real.Unmap()
+		}
+		return addr
+	}
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// whatever comes before is made up
+			return addr
+		}
+		addr = hop.Unmap()
+		if !c.trusted(addr) {
+			return addr
+		}
+	}
+	return addr
+}
+
+// group returns the key of the address' network.
+func (c IPKeyConfig) group(addr netip.Addr) string {
+	bits := c.IPv6Prefix
+	if addr.Is4() {
+		bits = c.IPv4Prefix
+	}
+	if bits <= 0 || bits >= addr.BitLen() {
+		return addr.String()
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return addr.String()
+	}
+	return prefix.String()
+}
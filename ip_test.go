@@ -0,0 +1,42 @@
+package throttle
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestIPKey(t *testing.T) {
+	keyFn := IPKey(IPKeyConfig{
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		IPv4Prefix:     24,
+		IPv6Prefix:     64,
+	})
+	tests := []struct {
+		remote    string
+		forwarded string
+		realIP    string
+		key       string
+	}{
+		{"192.0.2.17:1234", "", "", "192.0.2.0/24"},
+		{"[2001:db8::1]:1234", "", "", "2001:db8::/64"},
+		{"192.0.2.17:1234", "198.51.100.7", "", "192.0.2.0/24"},
+		{"10.0.0.1:1234", "198.51.100.7", "", "198.51.100.0/24"},
+		{"10.0.0.1:1234", "203.0.113.9, 198.51.100.7, 10.0.0.2", "", "198.51.100.0/24"},
+		{"10.0.0.1:1234", "", "203.0.113.9", "203.0.113.0/24"},
+		{"10.0.0.1:1234", "garbage, 10.0.0.2", "", "10.0.0.0/24"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = test.remote
+		if test.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		if test.realIP != "" {
+			req.Header.Set("X-Real-IP", test.realIP)
+		}
+		if key := keyFn(req); key != test.key {
+			t.Errorf("%s forwarding %q: expected key %q, got %q", test.remote, test.forwarded, test.key, key)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package throttle
+
+import (
+	"math/rand"
+	"time"
+)
+
+// WithJitter randomizes the interval at which tokens are spawned by up to
+// ±fraction of the request rate, so that clients throttled at the same rate do
+// not get in sync and hit the downstream service in lockstep. On average,
+// tokens are still spawned at the request rate. The fraction is capped to the
+// range [0, 1); by default, there is no jitter.
+func WithJitter(fraction float64) Option {
+	return func(t *Throttle) {
+		switch {
+		case fraction < 0:
+			fraction = 0
+		case fraction >= 1:
+			fraction = 0.99
+		}
+		t.jitterFactor = fraction
+	}
+}
+
+// jitter returns the rate moved by a random amount of up to ±jitterFactor.
+func (t *Throttle) jitter(rate time.Duration) time.Duration {
+	if t.jitterFactor == 0 {
+		return rate
+	}
+	return time.Duration(float64(rate) * (1 + t.jitterFactor*(2*rand.Float64()-1)))
+}
@@ -0,0 +1,29 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJitter(t *testing.T) {
+	throttle := New(100*time.Millisecond, WithJitter(0.2))
+	min, max := time.Duration(1<<62), time.Duration(0)
+	for i := 0; i < 1000; i++ {
+		rate := throttle.limit("alice").Rate
+		if rate < min {
+			min = rate
+		}
+		if rate > max {
+			max = rate
+		}
+	}
+	if min < 80*time.Millisecond || max > 120*time.Millisecond {
+		t.Errorf("expected rates within [80ms, 120ms], got [%v, %v]", min, max)
+	}
+	if max-min < 20*time.Millisecond {
+		t.Errorf("expected rates to spread, got [%v, %v]", min, max)
+	}
+	if rate := New(100 * time.Millisecond).limit("alice").Rate; rate != 100*time.Millisecond {
+		t.Errorf("without jitter: expected %v, got %v", 100*time.Millisecond, rate)
+	}
+}
@@ -0,0 +1,146 @@
+package throttle
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ParseKey returns a function deriving the key of a request as described by
+// spec, e.g. for Middleware. A spec names one of these attributes:
+//
+//   - "remote": the host of the remote address
+//   - "ip": the client's IP address, see IPKey
+//   - "cert:cn", "cert:san", or "cert:spki": the client certificate, see CertKey
+//   - "header:<name>": the value of a header
+//   - "query:<name>": the value of a query parameter
+//   - "cookie:<name>": the value of a cookie
+//   - "method", "host", or "path": the method, host, and path of the request
+//
+// Attributes joined by "+" are combined, e.g. "header:X-API-Key+path" keys
+// every API key's requests per path. Alternatives separated by "," are tried
+// in order, and the first one whose attributes are all present is used, e.g.
+// "header:X-API-Key,ip" keys authenticated requests by their API key, and
+// anonymous ones by their IP address. If none is present, the last one is
+// used anyway. The keys of an alternative are prefixed by its attributes,
+// e.g. "ip=192.0.2.1", so that a client cannot pose as another one by sending
+// the other's key in a different attribute.
+func ParseKey(spec string) (func(*http.Request) string, error) {
+	return parseKey(spec, IPKeyConfig{})
+}
+
+// alternative is one of the alternatives of a key spec.
+type alternative struct {
+	prefix string
+	parts  []func(*http.Request) string
+}
+
+// parseKey works like ParseKey, deriving IP addresses as configured.
+func parseKey(spec string, ip IPKeyConfig) (func(*http.Request) string, error) {
+	var alternatives []alternative
+	for _, attributeSpec := range strings.Split(spec, ",") {
+		var attributes []string
+		var parts []func(*http.Request) string
+		for _, attribute := range strings.Split(attributeSpec, "+") {
+			attribute = strings.TrimSpace(attribute)
+			part, err := keyPart(attribute, ip)
+			if err != nil {
+				return nil, err
+			}
+			attributes = append(attributes, attribute)
+			parts = append(parts, part)
+		}
+		alternatives = append(alternatives, alternative{prefix: strings.Join(attributes, "+") + "=", parts: parts})
+	}
+	if len(alternatives) == 1 && len(alternatives[0].parts) == 1 {
+		return alternatives[0].parts[0], nil
+	}
+	if len(alternatives) == 1 {
+		// all keys are made up of the same attributes, there is no need to
+		// tell them apart
+		alternatives[0].prefix = ""
+	}
+	return func(r *http.Request) string {
+		var key string
+		for _, alternative := range alternatives {
+			var present bool
+			if key, present = combine(r, alternative); present {
+				break
+			}
+		}
+		return key
+	}, nil
+}
+
+// combine returns the key made up of the prefix and the parts of the
+// alternative derived from the request, and reports whether all of them are
+// present. The parts are escaped, so that they contain neither "+" nor "=",
+// and no two distinct combinations make up the same key. It returns the empty
+// key if none of the parts is present.
+func combine(r *http.Request, alternative alternative) (string, bool) {
+	var b strings.Builder
+	b.WriteString(alternative.prefix)
+	present, absent := true, true
+	for i, part := range alternative.parts {
+		value := part(r)
+		present = present && value != ""
+		absent = absent && value == ""
+		if i > 0 {
+			b.WriteByte('+')
+		}
+		b.WriteString(url.QueryEscape(value))
+	}
+	if absent {
+		return "", false
+	}
+	return b.String(), present
+}
+
+// keyPart returns the function deriving a single attribute from a request.
+func keyPart(attribute string, ip IPKeyConfig) (func(*http.Request) string, error) {
+	source, name, _ := strings.Cut(attribute, ":")
+	if name == "" && (source == "header" || source == "query" || source == "cookie") {
+		return nil, fmt.Errorf("key %q lacks a name", attribute)
+	}
+	switch source {
+	case "", "remote":
+		return remoteHost, nil
+	case "ip":
+		return IPKey(ip), nil
+	case "cert":
+		identity, ok := map[string]CertIdentity{"cn": CertCommonName, "san": CertSAN, "spki": CertSPKI}[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q", attribute)
+		}
+		return CertKey(identity), nil
+	case "header":
+		return func(r *http.Request) string {
+			return r.Header.Get(name)
+		}, nil
+	case "query":
+		return func(r *http.Request) string {
+			return r.URL.Query().Get(name)
+		}, nil
+	case "cookie":
+		return func(r *http.Request) string {
+			if cookie, err := r.Cookie(name); err == nil {
+				return cookie.Value
+			}
+			return ""
+		}, nil
+	case "method":
+		return func(r *http.Request) string {
+			return r.Method
+		}, nil
+	case "host":
+		return func(r *http.Request) string {
+			return r.Host
+		}, nil
+	case "path":
+		return func(r *http.Request) string {
+			return r.URL.Path
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", attribute)
+}
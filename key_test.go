@@ -0,0 +1,59 @@
+package throttle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseKey(t *testing.T) {
+	request := func(apiKey string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "http://example.com/search?q=go", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.AddCookie(&http.Cookie{Name: "session", Value: "s3cr3t"})
+		if apiKey != "" {
+			r.Header.Set("X-API-Key", apiKey)
+		}
+		return r
+	}
+	tests := []struct {
+		spec     string
+		apiKey   string
+		expected string
+	}{
+		{"", "", "192.0.2.1"},
+		{"remote", "", "192.0.2.1"},
+		{"ip", "", "192.0.2.1"},
+		{"header:X-API-Key", "alice", "alice"},
+		{"query:q", "", "go"},
+		{"cookie:session", "", "s3cr3t"},
+		{"cookie:missing", "", ""},
+		{"method", "", "POST"},
+		{"host", "", "example.com"},
+		{"path", "", "/search"},
+		{"header:X-API-Key+path", "alice", "alice+%2Fsearch"},
+		{"header:X-API-Key+method", "a+b", "a%2Bb+POST"},
+		{"header:X-API-Key, ip", "alice", "header:X-API-Key=alice"},
+		{"header:X-API-Key, ip", "", "ip=192.0.2.1"},
+		{"header:X-API-Key,ip", "192.0.2.1", "header:X-API-Key=192.0.2.1"},
+		{"header:X-API-Key,ip+path", "a+b", "header:X-API-Key=a%2Bb"},
+		{"header:X-API-Key+path,ip+path", "", "ip+path=192.0.2.1+%2Fsearch"},
+		{"header:X-API-Key,cookie:missing", "", ""},
+	}
+	for _, test := range tests {
+		keyFn, err := ParseKey(test.spec)
+		if err != nil {
+			t.Errorf("%q: expected no error, got %v", test.spec, err)
+			continue
+		}
+		if key := keyFn(request(test.apiKey)); key != test.expected {
+			t.Errorf("%q with API key %q: expected key %q, got %q", test.spec, test.apiKey, test.expected, key)
+		}
+	}
+
+	for _, spec := range []string{"cookie", "header:X-API-Key+jwt", "ip,cert:fingerprint"} {
+		if _, err := ParseKey(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Keyed throttles clients identified by keys of type K, e.g. int user IDs,
+// netip.Addr, or structs like {tenant, endpoint}, using a Throttle. Every key
+// is formatted into the Throttle's string key only once, when it is first
+// seen, rather than on every request. The formatted keys are forgotten along
+// with the clients of the Throttle, see WithIdleTimeout and WithMaxClients.
+type Keyed[K comparable] struct {
+	// Throttle paces the requests of the clients.
+	Throttle *Throttle
+
+	format  func(K) string
+	keys    sync.Map
+	clients sync.Map
+}
+
+// keyCache is implemented by the caches of formatted keys kept outside of the
+// Throttle, which forget about the keys along with the Throttle's clients.
+type keyCache interface {
+	// forget removes the key of the client.
+	forget(client string)
+
+	// clear removes all keys.
+	clear()
+}
+
+// NewKeyed creates a new Keyed throttling clients using t. If format is nil,
+// keys are formatted using fmt.Sprint; format has to return distinct strings
+// for distinct keys.
+func NewKeyed[K comparable](t *Throttle, format func(K) string) *Keyed[K] {
+	if format == nil {
+		format = func(key K) string {
+			return fmt.Sprint(key)
+		}
+	}
+	k := &Keyed[K]{Throttle: t, format: format}
+	t.accessMutex.Lock()
+	t.keyCaches = append(t.keyCaches, k)
+	t.accessMutex.Unlock()
+	return k
+}
+
+// Wait works like Throttle.Wait for the client with the given key.
+func (k *Keyed[K]) Wait(key K) error {
+	return k.Throttle.Wait(k.client(key))
+}
+
+// WaitContext works like Throttle.WaitContext for the client with the given
+// key.
+func (k *Keyed[K]) WaitContext(ctx context.Context, key K) error {
+	return k.Throttle.WaitContext(ctx, k.client(key))
+}
+
+// WaitN works like Throttle.WaitN for the client with the given key.
+func (k *Keyed[K]) WaitN(key K, n int) error {
+	return k.Throttle.WaitN(k.client(key), n)
+}
+
+// Allow works like Throttle.Allow for the client with the given key.
+func (k *Keyed[K]) Allow(key K) bool {
+	return k.Throttle.Allow(k.client(key))
+}
+
+// Reserve works like Throttle.Reserve for the client with the given key.
+func (k *Keyed[K]) Reserve(key K) *Reservation {
+	return k.Throttle.Reserve(k.client(key))
+}
+
+// client returns the formatted key.
+func (k *Keyed[K]) client(key K) string {
+	if client, ok := k.keys.Load(key); ok {
+		return client.(string)
+	}
+	client, loaded := k.keys.LoadOrStore(key, k.format(key))
+	if !loaded {
+		k.clients.Store(client, key)
+	}
+	return client.(string)
+}
+
+func (k *Keyed[K]) forget(client string) {
+	if key, ok := k.clients.LoadAndDelete(client); ok {
+		k.keys.Delete(key)
+	}
+}
+
+func (k *Keyed[K]) clear() {
+	k.keys.Clear()
+	k.clients.Clear()
+}
+
+// forgetKeyCaches removes the client's keys from the caches of all Keyed
+// using the Throttle.
+func (t *Throttle) forgetKeyCaches(client string) {
+	t.accessMutex.RLock()
+	defer t.accessMutex.RUnlock()
+	for _, c := range t.keyCaches {
+		c.forget(client)
+	}
+}
+
+// clearKeyCaches empties the caches of all Keyed using the Throttle, which
+// format the keys of their clients anew.
+func (t *Throttle) clearKeyCaches() {
+	t.accessMutex.RLock()
+	defer t.accessMutex.RUnlock()
+	for _, c := range t.keyCaches {
+		c.clear()
+	}
+}
@@ -0,0 +1,70 @@
+package throttle
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestKeyed(t *testing.T) {
+	type route struct {
+		tenant   int
+		endpoint string
+	}
+	formatted := 0
+	keyed := NewKeyed(New(1*time.Second), func(r route) string {
+		formatted++
+		return strconv.Itoa(r.tenant) + "/" + r.endpoint
+	})
+
+	alice := route{1, "/export"}
+	if !keyed.Allow(alice) {
+		t.Errorf("first request: expected to be allowed")
+	}
+	if keyed.Allow(alice) {
+		t.Errorf("second request: expected to be rejected")
+	}
+	if !keyed.Allow(route{2, "/export"}) {
+		t.Errorf("other tenant: expected to be allowed")
+	}
+	if formatted != 2 {
+		t.Errorf("expected %d keys to be formatted once, formatted %d times", 2, formatted)
+	}
+	if stats := keyed.Throttle.Stats("1//export"); stats.Allowed != 1 || stats.Rejected != 1 {
+		t.Errorf("expected stats under formatted key, got %+v", stats)
+	}
+}
+
+func TestKeyedDefaultFormat(t *testing.T) {
+	keyed := NewKeyed[int](New(1*time.Second), nil)
+	keyed.Allow(42)
+	if stats := keyed.Throttle.Stats("42"); stats.Allowed != 1 {
+		t.Errorf("expected key to be formatted using fmt.Sprint, got %+v", stats)
+	}
+}
+
+func TestKeyedForget(t *testing.T) {
+	formatted := 0
+	keyed := NewKeyed(New(1*time.Second, WithMaxClients(1)), func(id int) string {
+		formatted++
+		return strconv.Itoa(id)
+	})
+
+	keyed.Allow(1)
+	keyed.Allow(2) // evicts 1
+	if _, ok := keyed.keys.Load(1); ok {
+		t.Errorf("expected the key of the evicted client to be forgotten")
+	}
+	keyed.Allow(1)
+	if formatted != 3 {
+		t.Errorf("expected the key of the evicted client to be formatted anew, formatted %d times", formatted)
+	}
+}
+
+func BenchmarkKeyedAllow(b *testing.B) {
+	keyed := NewKeyed[int](New(1*time.Nanosecond, WithBurst(1<<30)), nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		keyed.Allow(i % 100)
+	}
+}
@@ -0,0 +1,69 @@
+package throttle
+
+import (
+	"math"
+	"time"
+)
+
+// Latency returns the 50th, 95th, and 99th percentile of the time the allowed
+// requests waited for their tokens, e.g. to enforce an SLO on the delay added
+// by throttling. The percentiles are estimated from the wait histogram of
+// Metrics by interpolating linearly within its buckets; waits beyond the
+// largest bucket are estimated as up to the longest wait so far. All
+// percentiles are 0 before the first request is allowed.
+func (t *Throttle) Latency() (p50, p95, p99 time.Duration) {
+	m := t.metrics
+	counts := make([]uint64, len(waitBuckets))
+	for i := range counts {
+		counts[i] = m.waits[i].Load()
+	}
+	immediate := m.immediate.Load()
+	longest := time.Duration(m.longest.Load())
+	total := m.allowed.Load()
+	quantile := func(q float64) time.Duration {
+		return waitQuantile(q, total, immediate, counts, longest)
+	}
+	return quantile(0.5), quantile(0.95), quantile(0.99)
+}
+
+// RejectionRatio returns the share of requests rejected so far, between 0 and
+// 1.
+func (t *Throttle) RejectionRatio() float64 {
+	rejected := t.metrics.rejected.Load()
+	allowed := t.metrics.allowed.Load()
+	if allowed+rejected == 0 {
+		return 0
+	}
+	return float64(rejected) / float64(allowed+rejected)
+}
+
+// waitQuantile estimates the q-quantile of total waits, given the number of
+// immediate waits, the cumulative counts of the waitBuckets, and the longest
+// wait.
+func waitQuantile(q float64, total, immediate uint64, counts []uint64, longest time.Duration) time.Duration {
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(total)))
+	if rank <= immediate {
+		return 0
+	}
+	lower, below := time.Duration(0), immediate
+	for i, upper := range waitBuckets {
+		count := min(counts[i], total)
+		if rank <= count {
+			return interpolate(lower, upper, rank-below, count-below)
+		}
+		lower, below = upper, max(count, below)
+	}
+	return interpolate(lower, max(longest, lower), rank-below, total-below)
+}
+
+// interpolate returns the duration at the share n/of of the way from lower to
+// upper.
+func interpolate(lower, upper time.Duration, n, of uint64) time.Duration {
+	if of == 0 {
+		return upper
+	}
+	return lower + time.Duration(float64(upper-lower)*float64(n)/float64(of))
+}
@@ -0,0 +1,62 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatency(t *testing.T) {
+	throttle := New(1 * time.Second)
+	defer throttle.Close()
+	if p50, p95, p99 := throttle.Latency(); p50 != 0 || p95 != 0 || p99 != 0 {
+		t.Errorf("no requests: expected percentiles of 0, got %v, %v, %v", p50, p95, p99)
+	}
+
+	// 90 immediate, 5 within 50ms to 100ms, 4 within 1s to 2.5s, 1 of 30s
+	m := throttle.metrics
+	for i := 0; i < 90; i++ {
+		m.allow("alice", time.Now(), 0)
+	}
+	for i := 0; i < 5; i++ {
+		m.allow("alice", time.Now(), 75*time.Millisecond)
+	}
+	for i := 0; i < 4; i++ {
+		m.allow("alice", time.Now(), 2*time.Second)
+	}
+	m.allow("alice", time.Now(), 30*time.Second)
+
+	p50, p95, p99 := throttle.Latency()
+	if p50 != 0 {
+		t.Errorf("p50: expected 0, got %v", p50)
+	}
+	if p95 != 100*time.Millisecond {
+		t.Errorf("p95: expected 100ms, got %v", p95)
+	}
+	if p99 != 2500*time.Millisecond {
+		t.Errorf("p99: expected 2.5s, got %v", p99)
+	}
+
+	m.allow("alice", time.Now(), 30*time.Second)
+	for i := 0; i < 98; i++ {
+		m.allow("alice", time.Now(), 0)
+	}
+	// 199 requests, the 198th is halfway between the largest bucket and the
+	// longest wait
+	if _, _, p99 := throttle.Latency(); p99 != 20*time.Second {
+		t.Errorf("p99 beyond the largest bucket: expected 20s, got %v", p99)
+	}
+}
+
+func TestRejectionRatio(t *testing.T) {
+	throttle := New(1*time.Hour, WithMaxWait(time.Millisecond))
+	defer throttle.Close()
+	if ratio := throttle.RejectionRatio(); ratio != 0 {
+		t.Errorf("no requests: expected 0, got %v", ratio)
+	}
+	for i := 0; i < 4; i++ {
+		throttle.Allow("alice")
+	}
+	if ratio := throttle.RejectionRatio(); ratio != 0.75 {
+		t.Errorf("expected 0.75, got %v", ratio)
+	}
+}
@@ -0,0 +1,28 @@
+package throttle
+
+import "time"
+
+// NewLeakyBucket creates a new Throttle that queues the requests of every
+// client and lets them pass at a constant rate, one per request rate, in the
+// order of their arrival. Up to capacity requests can be queued per client;
+// requests arriving at a full queue are rejected right away. Every request is
+// assigned its slot in the queue when it arrives, so that later arrivals can
+// never overtake it. WithBurst, WithMaxWait, and WithStore are ignored.
+func NewLeakyBucket(requestRate time.Duration, capacity int, opts ...Option) *Throttle {
+	if capacity < 0 {
+		capacity = 0
+	}
+	opts = append(opts, WithBurst(1), withQueue(capacity), withStoreFactory(func(clock Clock) Store {
+		return newGCRAStore(clock)
+	}))
+	return New(requestRate, opts...)
+}
+
+// withQueue makes requests wait for up to capacity requests queued before
+// them, instead of for the maximum waiting time.
+func withQueue(capacity int) Option {
+	return func(t *Throttle) {
+		t.queued = true
+		t.queueCapacity = capacity
+	}
+}
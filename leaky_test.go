@@ -0,0 +1,66 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeakyBucket(t *testing.T) {
+	clock := newFakeClock()
+	throttle := NewLeakyBucket(100*time.Millisecond, 3, WithClock(clock))
+
+	// the first request passes, three are queued, and the fifth is rejected
+	type served struct {
+		nth int
+		err error
+	}
+	results := make(chan served, 5)
+	for i := 0; i < 5; i++ {
+		go func(nth int) {
+			results <- served{nth, throttle.Wait("alice")}
+		}(i)
+		for len(results)+clock.Waiters() < i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	var order []int
+	rejected := 0
+	for len(order)+rejected < 2 {
+		if r := <-results; r.err == nil {
+			order = append(order, r.nth)
+		} else {
+			rejected++
+		}
+	}
+	if len(order) != 1 || rejected != 1 {
+		t.Fatalf("right away: expected 1 served and 1 rejected, got %v served and %d rejected", order, rejected)
+	}
+
+	// the queue drains at a constant rate, in arrival order
+	for len(order) < 4 {
+		clock.Advance(100 * time.Millisecond)
+		r := <-results
+		if r.err != nil {
+			t.Fatalf("queued request %d: expected no error, got %v", r.nth, r.err)
+		}
+		order = append(order, r.nth)
+	}
+	if order[0] > order[1] || order[1] > order[2] || order[2] > order[3] {
+		t.Errorf("expected requests to be served in arrival order, got %v", order)
+	}
+}
+
+func TestLeakyBucketWithoutQueue(t *testing.T) {
+	throttle := NewLeakyBucket(1*time.Second, 0)
+	if err := throttle.Wait("alice"); err != nil {
+		t.Errorf("first request: expected no error, got %v", err)
+	}
+	start := time.Now()
+	if err := throttle.Wait("alice"); err == nil {
+		t.Errorf("second request: expected to be rejected")
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("second request: expected to be rejected right away, took %v", elapsed)
+	}
+}
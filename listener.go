@@ -0,0 +1,45 @@
+package throttle
+
+import "net"
+
+// Listener returns a Listener accepting connections from l, but closing every
+// connection whose client, derived from the connection using keyFn, has no
+// token available right away. Since waiting for a token would hold up the
+// connections of all other clients, connections are never delayed. If keyFn
+// is nil, connections are keyed by the host part of their remote address.
+func Listener(l net.Listener, t *Throttle, keyFn func(net.Conn) string) net.Listener {
+	if keyFn == nil {
+		keyFn = remoteConnHost
+	}
+	return &listener{Listener: l, throttle: t, keyFn: keyFn}
+}
+
+type listener struct {
+	net.Listener
+	throttle *Throttle
+	keyFn    func(net.Conn) string
+}
+
+// Accept waits for the next connection of a client having a token available.
+func (l *listener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.throttle.Allow(l.keyFn(conn)) {
+			return conn, nil
+		}
+		conn.Close()
+	}
+}
+
+// remoteConnHost returns the host part of the connection's remote address.
+func remoteConnHost(conn net.Conn) string {
+	addr := conn.RemoteAddr().String()
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
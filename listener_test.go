@@ -0,0 +1,47 @@
+package throttle
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	throttled := Listener(l, New(1*time.Hour), nil)
+	defer throttled.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := throttled.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("connection %d: expected no error, got %v", i, err)
+		}
+		defer conn.Close()
+		if i == 1 {
+			// the throttled connection is closed by the server
+			conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+			if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+				t.Errorf("second connection: expected %v, got %v", io.EOF, err)
+			}
+		}
+	}
+	if len(accepted) != 1 {
+		t.Errorf("expected %d connection to be accepted, got %d", 1, len(accepted))
+	}
+	(<-accepted).Close()
+}
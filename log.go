@@ -0,0 +1,35 @@
+package throttle
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// WithLogger makes the Throttle emit debug records to logger whenever a
+// request acquires a token or is rejected, clients are forgotten, and rates
+// change, with the client and timing as attributes, so that its behaviour can
+// be audited. Records are only built if the logger is enabled for debug
+// records.
+func WithLogger(logger *slog.Logger) Option {
+	return func(t *Throttle) {
+		if logger == nil {
+			return
+		}
+		t.logger = logger
+		t.onAllow = append(t.onAllow, func(client string, waited time.Duration) {
+			t.debug("token granted", slog.String("client", client), slog.Duration("waited", waited))
+		})
+		t.onReject = append(t.onReject, func(client string) {
+			t.debug("request rejected", slog.String("client", client), slog.Duration("rate", t.rate(client)))
+		})
+	}
+}
+
+// debug emits a debug record, if there is a logger enabled for it.
+func (t *Throttle) debug(msg string, attrs ...slog.Attr) {
+	if t.logger == nil || !t.logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	t.logger.LogAttrs(context.Background(), slog.LevelDebug, msg, attrs...)
+}
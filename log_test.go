@@ -0,0 +1,45 @@
+package throttle
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	throttle := New(1*time.Hour, WithLogger(logger), WithMaxWait(time.Millisecond), WithMaxClients(1))
+	defer throttle.Close()
+
+	throttle.Allow("alice")
+	throttle.Allow("alice")
+	throttle.SetClientRate("alice", time.Minute)
+	throttle.SetRate(time.Second)
+	throttle.Allow("bob")
+
+	for _, want := range []string{
+		`msg="token granted" client=alice waited=0s`,
+		`msg="request rejected" client=alice rate=1h0m0s`,
+		`msg="client rate changed" client=alice rate=1m0s`,
+		`msg="rate changed" rate=1s`,
+		`msg="client forgotten" client=alice`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected record %s, got:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestLoggerDisabled(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	throttle := New(1*time.Hour, WithLogger(logger))
+	defer throttle.Close()
+	throttle.Allow("alice")
+	if buf.Len() > 0 {
+		t.Errorf("expected no debug records at level info, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,140 @@
+package throttle
+
+import (
+	"container/list"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// WithMaxClients bounds the number of clients the Throttle keeps track of to
+// n: once n clients are tracked, a new client makes the Throttle forget about
+// the client used least recently, including its bucket, stats, penalty, and
+// warm-up, so that a flood of random keys cannot exhaust memory. A forgotten
+// client starts anew, with a full bucket. For large n, the clients are
+// split into shards evicting their least recently used clients
+// independently, so the client forgotten is not necessarily the least
+// recently used one overall. Buckets kept using WithStore are not affected.
+// By default, or if n is 0, the number of clients is not bounded.
+func WithMaxClients(n int) Option {
+	return func(t *Throttle) {
+		if n < 1 {
+			t.lru = nil
+			return
+		}
+		t.lru = newLRU(n)
+	}
+}
+
+// lru tracks the order in which the clients have been used.
+type lru struct {
+	shards []lruShard
+}
+
+// lruShard tracks a part of the clients, the most recently used at the front.
+type lruShard struct {
+	mutex    sync.Mutex
+	capacity int
+	order    list.List
+	elements map[string]*list.Element
+}
+
+func newLRU(n int) *lru {
+	shards := 1
+	if n >= numShards*64 {
+		// large enough for the shards to be about equally full
+		shards = numShards
+	}
+	l := &lru{shards: make([]lruShard, shards)}
+	for i := range l.shards {
+		l.shards[i].capacity = n / shards
+		l.shards[i].elements = make(map[string]*list.Element)
+	}
+	return l
+}
+
+// touch records that the client has been used. If that makes a client's shard
+// exceed its capacity, the least recently used client of the shard is removed
+// and returned.
+func (l *lru) touch(client string) (evicted string, ok bool) {
+	s := &l.shards[shardHash(client)%uint32(len(l.shards))]
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if e, ok := s.elements[client]; ok {
+		s.order.MoveToFront(e)
+		return "", false
+	}
+	s.elements[client] = s.order.PushFront(client)
+	if s.order.Len() <= s.capacity {
+		return "", false
+	}
+	evicted = s.order.Remove(s.order.Back()).(string)
+	delete(s.elements, evicted)
+	return evicted, true
+}
+
+// remove stops tracking the client.
+func (l *lru) remove(client string) {
+	s := &l.shards[shardHash(client)%uint32(len(l.shards))]
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if e, ok := s.elements[client]; ok {
+		s.order.Remove(e)
+		delete(s.elements, client)
+	}
+}
+
+// track records that the client has been used, and forgets about the client
+// evicted to make room for it, if any.
+func (t *Throttle) track(client string) {
+	if t.lru == nil {
+		return
+	}
+	if evicted, ok := t.lru.touch(client); ok {
+		t.forget(evicted)
+	}
+}
+
+// forgetter is implemented by the Stores keeping their state in memory.
+type forgetter interface {
+	// forget removes the bucket of the given key.
+	forget(key string)
+}
+
+// forget removes all state kept about the client. The buckets it shares with
+// other clients, the global one and those of its Group, are kept.
+func (t *Throttle) forget(client string) {
+	t.debug("client forgotten", slog.String("client", client))
+	if store, ok := t.store.(forgetter); ok {
+		for _, l := range t.levels(client) {
+			if l.key != globalKey && !strings.HasPrefix(l.key, groupPrefix) {
+				store.forget(l.key)
+			}
+		}
+	}
+	t.metrics.clients.delete(client)
+	t.tierKeys.delete(client)
+	t.forgetKeyCaches(client)
+	if t.warmUp != nil {
+		t.warmUp.mutex.Lock()
+		delete(t.warmUp.clients, client)
+		t.warmUp.mutex.Unlock()
+	}
+	if t.coldStart != nil {
+		t.coldStart.mutex.Lock()
+		delete(t.coldStart.clients, client)
+		t.coldStart.mutex.Unlock()
+	}
+	if t.penalties != nil {
+		t.penalties.forgive(client)
+	}
+	if t.breaker != nil {
+		t.breaker.forget(client)
+	}
+	if t.anomalies != nil {
+		t.anomalies.forget(client)
+	}
+	if t.observer != nil {
+		t.observer.clients.delete(client)
+	}
+}
@@ -0,0 +1,68 @@
+package throttle
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMaxClients(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Hour, WithMaxClients(2), WithClock(clock))
+	defer throttle.Close()
+
+	throttle.Allow("alice")
+	throttle.Allow("bob")
+	throttle.Allow("alice") // rejected, but used more recently than bob
+	throttle.Allow("carol")
+
+	if stats := throttle.Stats("bob"); stats != (ClientStats{}) {
+		t.Errorf("least recently used client not forgotten: %+v", stats)
+	}
+	if stats := throttle.Stats("alice"); stats.Allowed != 1 || stats.Rejected != 1 {
+		t.Errorf("recently used client forgotten: %+v", stats)
+	}
+	if n := throttle.store.(*memoryStore).Len(); n != 2 {
+		t.Errorf("expected 2 buckets, got %d", n)
+	}
+	if !throttle.Allow("bob") {
+		t.Error("forgotten client did not start with a full bucket")
+	}
+	if throttle.Allow("carol") {
+		t.Error("client tracked lost its bucket")
+	}
+}
+
+func TestMaxClientsGroup(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Hour, WithMaxClients(2), WithTier("plan", 1, 1*time.Hour), WithClock(clock))
+	defer throttle.Close()
+	throttle.Group("acme", "alice", "bob")
+
+	throttle.Allow("alice")
+	throttle.Allow("carol")
+	throttle.Allow("dave") // evicts alice
+
+	if stats := throttle.Stats("alice"); stats != (ClientStats{}) {
+		t.Errorf("least recently used client not forgotten: %+v", stats)
+	}
+	if throttle.Allow("bob") {
+		t.Error("forgetting a member reset the bucket of its group")
+	}
+}
+
+func TestMaxClientsSharded(t *testing.T) {
+	const n = numShards * 64
+	throttle := New(1*time.Hour, WithMaxClients(n), WithTier("hourly", 10, time.Hour))
+	defer throttle.Close()
+	for i := 0; i < 10*n; i++ {
+		throttle.Allow(fmt.Sprintf("client-%d", i))
+	}
+	// every client has a bucket and a tier bucket
+	if got := throttle.store.(*memoryStore).Len(); got > 2*n {
+		t.Errorf("expected at most %d buckets, got %d", 2*n, got)
+	}
+	if got := len(throttle.AllStats()); got > n {
+		t.Errorf("expected stats of at most %d clients, got %d", n, got)
+	}
+}
@@ -0,0 +1,196 @@
+// Package memcachethrottle provides a throttle.Store that keeps the token
+// buckets in memcached, so that multiple instances of a service enforce one
+// shared limit per client:
+//
+//	t := throttle.New(time.Second, throttle.WithStore(memcachethrottle.NewStore(memcache.New("localhost:11211"))))
+//
+// Buckets are updated atomically using compare-and-swap. Unlike Redis,
+// memcached does not provide the time, so the instances' clocks must agree.
+package memcachethrottle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/patrickbucher/throttle"
+)
+
+// maxKeyLength is the maximum length of a memcached key.
+const maxKeyLength = 250
+
+// maxAttempts is the number of times an update of a bucket is attempted
+// before giving up because of concurrent updates.
+const maxAttempts = 16
+
+// ErrContention is returned if a bucket could not be updated because other
+// instances kept on updating it at the same time.
+var ErrContention = errors.New("memcachethrottle: too many concurrent updates")
+
+// Client is the part of a memcached client used by Store, which is
+// implemented by *memcache.Client.
+type Client interface {
+	Get(key string) (*memcache.Item, error)
+	Add(item *memcache.Item) error
+	CompareAndSwap(item *memcache.Item) error
+}
+
+// Store is a throttle.Store keeping the token buckets in memcached.
+type Store struct {
+	client Client
+	prefix string
+	now    func() time.Time
+}
+
+// Option configures a Store created by NewStore.
+type Option func(*Store)
+
+// WithPrefix sets the prefix of the memcached keys holding the clients'
+// buckets, which is "throttle:" by default. Throttles enforcing different
+// limits must use different prefixes.
+func WithPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.prefix = prefix
+	}
+}
+
+// WithClock makes the Store use the given clock instead of the system's
+// clock.
+func WithClock(clock throttle.Clock) Option {
+	return func(s *Store) {
+		s.now = clock.Now
+	}
+}
+
+// NewStore creates a new Store using the given memcached client, e.g. a
+// *memcache.Client.
+func NewStore(client Client, opts ...Option) *Store {
+	store := Store{
+		client: client,
+		prefix: "throttle:",
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(&store)
+	}
+	return &store
+}
+
+// TryConsume implements throttle.Store.
+func (s *Store) TryConsume(ctx context.Context, client string, limit throttle.Limit, n int, maxWait time.Duration) (throttle.Result, error) {
+	var result throttle.Result
+	err := s.update(ctx, client, limit, func(b *throttle.TokenBucket) bool {
+		result = b.TryConsume(limit, n, maxWait)
+		return result.OK
+	})
+	return result, err
+}
+
+// Refund implements throttle.Store.
+func (s *Store) Refund(ctx context.Context, client string, limit throttle.Limit, n int) error {
+	return s.update(ctx, client, limit, func(b *throttle.TokenBucket) bool {
+		b.Refund(limit, n)
+		return true
+	})
+}
+
+// Peek implements throttle.Store.
+func (s *Store) Peek(ctx context.Context, client string, limit throttle.Limit) (throttle.Result, error) {
+	var result throttle.Result
+	err := s.update(ctx, client, limit, func(b *throttle.TokenBucket) bool {
+		result = b.Peek(limit)
+		return false
+	})
+	return result, err
+}
+
+// update applies f to the client's bucket, with the tokens spawned until now
+// added, and stores the bucket if f returns true. The update is retried if
+// the bucket was changed concurrently.
+func (s *Store) update(ctx context.Context, client string, limit throttle.Limit, f func(*throttle.TokenBucket) bool) error {
+	key := s.key(client)
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		now := s.now()
+		b := throttle.NewTokenBucket(limit, now)
+		item, err := s.client.Get(key)
+		switch {
+		case errors.Is(err, memcache.ErrCacheMiss):
+			item = nil
+		case err != nil:
+			return err
+		default:
+			if b, err = decode(item.Value); err != nil {
+				return err
+			}
+			b.Advance(limit, now)
+		}
+		if !f(&b) {
+			return nil
+		}
+
+		value := &memcache.Item{Key: key, Value: encode(b), Expiration: expiration(b, limit)}
+		if item == nil {
+			err = s.client.Add(value)
+		} else {
+			value.CasID = item.CasID
+			err = s.client.CompareAndSwap(value)
+		}
+		if errors.Is(err, memcache.ErrNotStored) || errors.Is(err, memcache.ErrCASConflict) || errors.Is(err, memcache.ErrCacheMiss) {
+			// another instance was faster, try again with its state
+			continue
+		}
+		return err
+	}
+	return ErrContention
+}
+
+// key returns the memcached key of the client's bucket. Clients are escaped,
+// for memcached keys must not contain spaces or control characters, and
+// hashed if too long.
+func (s *Store) key(client string) string {
+	key := s.prefix + url.QueryEscape(client)
+	if len(key) > maxKeyLength {
+		sum := sha256.Sum256([]byte(client))
+		key = s.prefix + hex.EncodeToString(sum[:])
+	}
+	return key
+}
+
+// expiration returns the seconds until the bucket is full, and can be
+// forgotten, plus a second.
+func expiration(b throttle.TokenBucket, limit throttle.Limit) int32 {
+	return int32(math.Min(math.Ceil(b.UntilFull(limit).Seconds())+1, 30*24*60*60))
+}
+
+// encode encodes the bucket as the tokens and the time of the last update in
+// microseconds since the epoch, separated by a space.
+func encode(b throttle.TokenBucket) []byte {
+	return fmt.Appendf(nil, "%s %d", strconv.FormatFloat(b.Tokens, 'g', -1, 64), b.Last.UnixMicro())
+}
+
+func decode(value []byte) (throttle.TokenBucket, error) {
+	tokens, last, ok := strings.Cut(string(value), " ")
+	if !ok {
+		return throttle.TokenBucket{}, fmt.Errorf("memcachethrottle: malformed bucket %q", value)
+	}
+	t, err := strconv.ParseFloat(tokens, 64)
+	if err != nil {
+		return throttle.TokenBucket{}, fmt.Errorf("memcachethrottle: malformed bucket %q: %w", value, err)
+	}
+	micros, err := strconv.ParseInt(last, 10, 64)
+	if err != nil {
+		return throttle.TokenBucket{}, fmt.Errorf("memcachethrottle: malformed bucket %q: %w", value, err)
+	}
+	return throttle.TokenBucket{Tokens: t, Last: time.UnixMicro(micros)}, nil
+}
@@ -0,0 +1,156 @@
+package memcachethrottle
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/patrickbucher/throttle"
+)
+
+// fakeMemcache is an in-memory Client, which checks the keys like memcached.
+type fakeMemcache struct {
+	mutex sync.Mutex
+	items map[string]memcache.Item
+	casID uint64
+}
+
+func newFakeMemcache() *fakeMemcache {
+	return &fakeMemcache{items: make(map[string]memcache.Item)}
+}
+
+func (f *fakeMemcache) Get(key string) (*memcache.Item, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	item, ok := f.items[key]
+	if !ok {
+		return nil, memcache.ErrCacheMiss
+	}
+	return &item, nil
+}
+
+func (f *fakeMemcache) Add(item *memcache.Item) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := checkKey(item.Key); err != nil {
+		return err
+	}
+	if _, ok := f.items[item.Key]; ok {
+		return memcache.ErrNotStored
+	}
+	f.store(item)
+	return nil
+}
+
+func (f *fakeMemcache) CompareAndSwap(item *memcache.Item) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	stored, ok := f.items[item.Key]
+	if !ok {
+		return memcache.ErrCacheMiss
+	}
+	if stored.CasID != item.CasID {
+		return memcache.ErrCASConflict
+	}
+	f.store(item)
+	return nil
+}
+
+func (f *fakeMemcache) store(item *memcache.Item) {
+	f.casID++
+	stored := *item
+	stored.CasID = f.casID
+	f.items[item.Key] = stored
+}
+
+func checkKey(key string) error {
+	if len(key) > maxKeyLength || strings.ContainsFunc(key, func(r rune) bool { return r <= ' ' || r == 0x7f }) {
+		return memcache.ErrMalformedKey
+	}
+	return nil
+}
+
+func TestWait(t *testing.T) {
+	limiter := throttle.New(100*time.Millisecond, throttle.WithBurst(2), throttle.WithStore(NewStore(newFakeMemcache())))
+	for i := 0; i < 3; i++ {
+		if err := limiter.Wait("alice"); err != nil {
+			t.Errorf("request %d: expected no error, got %v", i, err)
+		}
+	}
+}
+
+func TestAllow(t *testing.T) {
+	limiter := throttle.New(1*time.Second, throttle.WithStore(NewStore(newFakeMemcache())))
+	expected := []bool{true, false}
+	for i, exp := range expected {
+		if allowed := limiter.Allow("alice"); allowed != exp {
+			t.Errorf("request %d: expected allowed to be %v, got %v", i, exp, allowed)
+		}
+	}
+}
+
+func TestRefund(t *testing.T) {
+	store := NewStore(newFakeMemcache())
+	limit := throttle.Limit{Rate: 1 * time.Second, Burst: 1}
+	ctx := context.Background()
+
+	result, err := store.TryConsume(ctx, "alice", limit, 1, 0)
+	if err != nil || !result.OK {
+		t.Fatalf("first token: expected to be taken, got %v, %v", result, err)
+	}
+	if err := store.Refund(ctx, "alice", limit, 1); err != nil {
+		t.Fatalf("refund: expected no error, got %v", err)
+	}
+	result, err = store.Peek(ctx, "alice", limit)
+	if err != nil || result.Remaining != 1 {
+		t.Errorf("peek: expected %d remaining, got %v, %v", 1, result, err)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	limiter := throttle.New(1*time.Second, throttle.WithStore(NewStore(newFakeMemcache())),
+		throttle.WithGlobalLimit(1*time.Millisecond, 10))
+	for _, client := range []string{"alice smith", strings.Repeat("x", 300)} {
+		if !limiter.Allow(client) {
+			t.Errorf("%.20s: expected to be allowed", client)
+		}
+		if limiter.Allow(client) {
+			t.Errorf("%.20s: expected second request to be rejected", client)
+		}
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	store := NewStore(newFakeMemcache())
+	limit := throttle.Limit{Rate: 1 * time.Hour, Burst: 50}
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	taken := 0
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				result, err := store.TryConsume(ctx, "alice", limit, 1, 0)
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+					return
+				}
+				if result.OK {
+					mutex.Lock()
+					taken++
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if taken != limit.Burst {
+		t.Errorf("expected exactly %d tokens to be taken, got %d", limit.Burst, taken)
+	}
+}
@@ -0,0 +1,233 @@
+package throttle
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// waitBuckets are the upper bounds of the buckets used to count waiting times.
+var waitBuckets = []time.Duration{
+	1 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Metrics describes the decisions a Throttle has made so far.
+type Metrics struct {
+	// Allowed is the number of requests that acquired a token.
+	Allowed uint64
+
+	// Rejected is the number of requests that were rejected because no token
+	// could be acquired in time.
+	Rejected uint64
+
+	// Clients is the number of clients tracked by the Store, or -1 if the
+	// Store cannot tell.
+	Clients int
+
+	// Wait counts how long the allowed requests waited for their token.
+	Wait Histogram
+}
+
+// Histogram counts durations.
+type Histogram struct {
+	// Count is the number of durations counted.
+	Count uint64
+
+	// Sum is the total of all durations counted.
+	Sum time.Duration
+
+	// Buckets are the cumulative counts of durations not exceeding the
+	// buckets' upper bounds, ordered by increasing upper bound.
+	Buckets []Bucket
+}
+
+// Bucket is a bucket of a Histogram.
+type Bucket struct {
+	// UpperBound is the longest duration counted in the bucket.
+	UpperBound time.Duration
+
+	// Count is the number of durations not exceeding UpperBound.
+	Count uint64
+}
+
+// ClientStats describes the requests of a single client.
+type ClientStats struct {
+	// Allowed is the number of the client's requests that acquired a token.
+	Allowed uint64
+
+	// Rejected is the number of the client's requests that were rejected
+	// because no token could be acquired in time.
+	Rejected uint64
+
+	// LastRequest is the time of the client's last request.
+	LastRequest time.Time
+
+	// Waiters is the number of the client's requests currently waiting for
+	// their token.
+	Waiters int
+}
+
+// metrics keeps the counters of a Throttle. The totals are updated atomically,
+// and the stats of the clients are kept in a sharded map.
+type metrics struct {
+	allowed   atomic.Uint64
+	rejected  atomic.Uint64
+	waitSum   atomic.Int64
+	waits     []atomic.Uint64
+	immediate atomic.Uint64
+	longest   atomic.Int64
+	waiters   atomic.Int64
+	clients   *shardedMap[*ClientStats]
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		waits:   make([]atomic.Uint64, len(waitBuckets)),
+		clients: newShardedMap[*ClientStats](),
+	}
+}
+
+// allow counts an allowed request of the client made at the given time that
+// has waited for the given duration.
+func (m *metrics) allow(client string, at time.Time, waited time.Duration) {
+	// the total is counted before the buckets, which are read first, so that
+	// no bucket of a snapshot exceeds its total
+	m.allowed.Add(1)
+	m.waitSum.Add(int64(waited))
+	if waited == 0 {
+		m.immediate.Add(1)
+	}
+	for longest := m.longest.Load(); int64(waited) > longest; longest = m.longest.Load() {
+		if m.longest.CompareAndSwap(longest, int64(waited)) {
+			break
+		}
+	}
+	for i, upperBound := range waitBuckets {
+		if waited <= upperBound {
+			m.waits[i].Add(1)
+		}
+	}
+	shard := m.clients.shard(client)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	stats := m.client(shard, client)
+	stats.Allowed++
+	stats.LastRequest = at
+}
+
+// reject counts a rejected request of the client made at the given time.
+func (m *metrics) reject(client string, at time.Time) {
+	m.rejected.Add(1)
+	shard := m.clients.shard(client)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	stats := m.client(shard, client)
+	stats.Rejected++
+	stats.LastRequest = at
+}
+
+// waiting adds delta to the number of the client's waiting requests.
+func (m *metrics) waiting(client string, delta int) {
+	shard := m.clients.shard(client)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	m.client(shard, client).Waiters += delta
+	m.waiters.Add(int64(delta))
+}
+
+// startWaiting adds a waiting request of the client, unless max requests of
+// the client are waiting already. If max is 0, there is no limit.
+func (m *metrics) startWaiting(client string, max int) bool {
+	shard := m.clients.shard(client)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	stats := m.client(shard, client)
+	if max > 0 && stats.Waiters >= max {
+		return false
+	}
+	stats.Waiters++
+	m.waiters.Add(1)
+	return true
+}
+
+// client returns the client's stats, which are created on first use. It
+// requires the caller to hold the mutex of the client's shard.
+func (m *metrics) client(shard *shard[*ClientStats], client string) *ClientStats {
+	stats, ok := shard.entries[client]
+	if !ok {
+		stats = &ClientStats{}
+		shard.entries[client] = stats
+	}
+	return stats
+}
+
+// evictIdle removes the stats of clients without waiting requests whose last
+// request was made at least idleTimeout before now.
+func (m *metrics) evictIdle(now time.Time, idleTimeout time.Duration) {
+	m.clients.each(func(client string, stats *ClientStats) bool {
+		return stats.Waiters > 0 || now.Sub(stats.LastRequest) < idleTimeout
+	})
+}
+
+// Metrics returns a snapshot of the Throttle's counters. The number of
+// clients is only known for the default Store and for Stores providing a
+// Len() int method.
+func (t *Throttle) Metrics() Metrics {
+	buckets := make([]Bucket, len(waitBuckets))
+	for i, upperBound := range waitBuckets {
+		buckets[i] = Bucket{UpperBound: upperBound, Count: t.metrics.waits[i].Load()}
+	}
+	allowed := t.metrics.allowed.Load()
+	return Metrics{
+		Allowed:  allowed,
+		Rejected: t.metrics.rejected.Load(),
+		Clients:  t.clients(),
+		Wait: Histogram{
+			Count:   allowed,
+			Sum:     time.Duration(t.metrics.waitSum.Load()),
+			Buckets: buckets,
+		},
+	}
+}
+
+// clients returns the number of clients tracked by the Store, or -1 if the
+// Store cannot tell.
+func (t *Throttle) clients() int {
+	if s, ok := t.store.(interface{ Len() int }); ok {
+		return s.Len()
+	}
+	return -1
+}
+
+// Stats returns the stats of the given client. Stats of clients evicted using
+// WithIdleTimeout are reset.
+func (t *Throttle) Stats(client string) ClientStats {
+	client = t.key(client)
+	shard := t.metrics.clients.shard(client)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	if stats, ok := shard.entries[client]; ok {
+		return *stats
+	}
+	return ClientStats{}
+}
+
+// AllStats returns the stats of all clients, keyed by client.
+func (t *Throttle) AllStats() map[string]ClientStats {
+	all := make(map[string]ClientStats)
+	t.metrics.clients.each(func(client string, stats *ClientStats) bool {
+		all[client] = *stats
+		return true
+	})
+	return all
+}
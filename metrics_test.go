@@ -0,0 +1,90 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithClock(clock), WithMaxWait(1*time.Second))
+	throttle.Allow("alice")
+	throttle.Allow("alice")
+	throttle.Allow("bob")
+	throttle.TryWaitFor("alice", 0)
+
+	// wait for the next token of alice
+	done := make(chan error)
+	go func() {
+		done <- throttle.Wait("alice")
+	}()
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(100 * time.Millisecond)
+	if err := <-done; err != nil {
+		t.Fatalf("waiting request: expected no error, got %v", err)
+	}
+
+	metrics := throttle.Metrics()
+	if metrics.Allowed != 3 || metrics.Rejected != 2 {
+		t.Errorf("allowed/rejected: expected %d/%d, got %d/%d", 3, 2, metrics.Allowed, metrics.Rejected)
+	}
+	if metrics.Clients != 2 {
+		t.Errorf("clients: expected %d, got %d", 2, metrics.Clients)
+	}
+	if metrics.Wait.Count != 3 || metrics.Wait.Sum != 100*time.Millisecond {
+		t.Errorf("wait: expected %d waits totalling %v, got %d totalling %v",
+			3, 100*time.Millisecond, metrics.Wait.Count, metrics.Wait.Sum)
+	}
+	for _, bucket := range metrics.Wait.Buckets {
+		expected := uint64(2)
+		if bucket.UpperBound >= 100*time.Millisecond {
+			expected = 3
+		}
+		if bucket.Count != expected {
+			t.Errorf("wait bucket %v: expected %d, got %d", bucket.UpperBound, expected, bucket.Count)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithClock(clock), WithMaxWait(1*time.Second))
+	throttle.Allow("alice")
+	throttle.Allow("alice")
+	throttle.Allow("bob")
+
+	done := make(chan error)
+	go func() {
+		done <- throttle.Wait("alice")
+	}()
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if stats := throttle.Stats("alice"); stats.Waiters != 1 {
+		t.Errorf("waiting request: expected %d waiter, got %d", 1, stats.Waiters)
+	}
+	clock.Advance(100 * time.Millisecond)
+	<-done
+
+	expected := map[string]ClientStats{
+		"alice": {Allowed: 2, Rejected: 1, LastRequest: clock.Now().Add(-100 * time.Millisecond)},
+		"bob":   {Allowed: 1, LastRequest: clock.Now().Add(-100 * time.Millisecond)},
+	}
+	all := throttle.AllStats()
+	if len(all) != len(expected) {
+		t.Errorf("expected stats of %d clients, got %d", len(expected), len(all))
+	}
+	for client, stats := range expected {
+		if all[client] != stats {
+			t.Errorf("%s: expected %+v, got %+v", client, stats, all[client])
+		}
+		if got := throttle.Stats(client); got != stats {
+			t.Errorf("%s: expected %+v, got %+v", client, stats, got)
+		}
+	}
+	if stats := throttle.Stats("carol"); stats != (ClientStats{}) {
+		t.Errorf("unknown client: expected empty stats, got %+v", stats)
+	}
+}
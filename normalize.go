@@ -0,0 +1,41 @@
+package throttle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// WithKeyNormalizer makes the Throttle normalize every client key using f
+// before it is looked up, so that e.g. "Alice@example.com " and
+// "alice@example.com" share a bucket. Every method taking a client applies f
+// once; the keys passed to hooks, reported by ThrottledError and AllStats,
+// kept in a Snapshot, and sent to a Store, or to peers using WithGossip, are
+// the normalized ones.
+func WithKeyNormalizer(f func(client string) string) Option {
+	return func(t *Throttle) {
+		t.normalize = f
+	}
+}
+
+// LowerTrim normalizes a key by trimming white space around it and turning it
+// into lower case, e.g. for email addresses or user names.
+func LowerTrim(client string) string {
+	return strings.ToLower(strings.TrimSpace(client))
+}
+
+// Hashed normalizes a key by replacing it with its SHA-256 hash, so that keys
+// containing personal data, like email addresses, are not kept in memory in
+// plain text.
+func Hashed(client string) string {
+	sum := sha256.Sum256([]byte(client))
+	return hex.EncodeToString(sum[:])
+}
+
+// key returns the normalized key of the client.
+func (t *Throttle) key(client string) string {
+	if t.normalize == nil {
+		return client
+	}
+	return t.normalize(client)
+}
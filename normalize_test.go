@@ -0,0 +1,63 @@
+package throttle
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyNormalizer(t *testing.T) {
+	throttle := New(1*time.Hour, WithMaxWait(time.Millisecond), WithKeyNormalizer(LowerTrim))
+	defer throttle.Close()
+
+	if !throttle.Allow("Alice@Example.com ") {
+		t.Fatal("first request throttled")
+	}
+	if throttle.Allow("alice@example.com") {
+		t.Error("second request allowed with a key normalized alike")
+	}
+	if stats := throttle.Stats(" ALICE@example.com"); stats.Allowed != 1 || stats.Rejected != 1 {
+		t.Errorf("stats %+v", stats)
+	}
+	throttle.SetClientRate("ALICE@EXAMPLE.COM", time.Nanosecond)
+	if got := throttle.rate("alice@example.com"); got != time.Nanosecond {
+		t.Errorf("rate %v set for a key normalized alike", got)
+	}
+}
+
+func TestKeyNormalizerHashed(t *testing.T) {
+	calls := 0
+	normalize := func(client string) string {
+		calls++
+		return Hashed(client)
+	}
+	throttle := New(1*time.Hour, WithMaxWait(time.Millisecond), WithKeyNormalizer(normalize), WithAIMD(AIMD{ErrorThreshold: 0.01}))
+	defer throttle.Close()
+
+	for _, op := range []func(){
+		func() { throttle.Wait("alice@example.com") },
+		func() { throttle.Allow("alice@example.com") },
+		func() { throttle.Report("alice@example.com", Outcome{Err: ErrThrottled}) },
+		func() { throttle.Stats("alice@example.com") },
+	} {
+		calls = 0
+		op()
+		if calls != 1 {
+			t.Errorf("key normalized %d times, want once", calls)
+		}
+	}
+	for client := range throttle.AllStats() {
+		if strings.Contains(client, "alice") {
+			t.Errorf("client %q kept in plain text", client)
+		}
+	}
+	if got := throttle.Stats("alice@example.com"); got.Allowed != 1 || got.Rejected != 1 {
+		t.Errorf("stats %+v", got)
+	}
+	if got := throttle.rate("alice@example.com"); got != time.Hour {
+		t.Errorf("rate %v of unnormalized key changed", got)
+	}
+	if got := throttle.rate(Hashed("alice@example.com")); got != 2*time.Hour {
+		t.Errorf("rate %v of normalized key, want it doubled by Report", got)
+	}
+}
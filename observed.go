@@ -0,0 +1,121 @@
+package throttle
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+)
+
+// ClientRate is the observed request rate of a client.
+type ClientRate struct {
+	// Client is the key of the client.
+	Client string
+
+	// Rate is the client's observed number of requests per second.
+	Rate float64
+}
+
+// observer holds the observed request rates of the clients.
+type observer struct {
+	window  time.Duration
+	clients *shardedMap[*observedRate]
+}
+
+// observedRate is the moving average of a client's request rate as of its
+// last request.
+type observedRate struct {
+	rate float64
+	last time.Time
+}
+
+// WithObservedRate tracks the rate at which every client actually makes
+// requests, whether they are allowed or not, as an exponentially weighted
+// moving average over the given window, e.g. one minute, so that limits can
+// be tuned to the measured demand using ObservedRate and TopObserved. A
+// request made a window ago weighs about a third of one made just now. If the
+// window is 0, it is one minute.
+func WithObservedRate(window time.Duration) Option {
+	return func(t *Throttle) {
+		if window <= 0 {
+			window = time.Minute
+		}
+		o := &observer{window: window, clients: newShardedMap[*observedRate]()}
+		t.observer = o
+		t.onAllow = append(t.onAllow, func(client string, waited time.Duration) {
+			o.observe(client, t.clock.Now())
+		})
+		t.onReject = append(t.onReject, func(client string) {
+			o.observe(client, t.clock.Now())
+		})
+	}
+}
+
+// ObservedRate returns the client's observed number of requests per second.
+// It returns 0 for clients that have not made any request, and without
+// WithObservedRate.
+func (t *Throttle) ObservedRate(client string) float64 {
+	if t.observer == nil {
+		return 0
+	}
+	client = t.key(client)
+	o := t.observer
+	shard := o.clients.shard(client)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	r, ok := shard.entries[client]
+	if !ok {
+		return 0
+	}
+	return o.decayed(r, t.clock.Now())
+}
+
+// TopObserved returns the n clients with the highest observed request rates,
+// highest first. Without WithObservedRate, it returns nil.
+func (t *Throttle) TopObserved(n int) []ClientRate {
+	if t.observer == nil || n < 1 {
+		return nil
+	}
+	o, now := t.observer, t.clock.Now()
+	var rates []ClientRate
+	o.clients.each(func(client string, r *observedRate) bool {
+		rates = append(rates, ClientRate{Client: client, Rate: o.decayed(r, now)})
+		return true
+	})
+	slices.SortFunc(rates, func(a, b ClientRate) int {
+		if c := cmp.Compare(b.Rate, a.Rate); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Client, b.Client)
+	})
+	return rates[:min(n, len(rates))]
+}
+
+// observe counts a request of the client made at the given time.
+func (o *observer) observe(client string, now time.Time) {
+	shard := o.clients.shard(client)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	r, ok := shard.entries[client]
+	if !ok {
+		r = &observedRate{last: now}
+		shard.entries[client] = r
+	}
+	// every request adds to the rate what decays over one window
+	r.rate = o.decayed(r, now) + 1/o.window.Seconds()
+	r.last = now
+}
+
+// decayed returns the rate as of now, decayed since the last request.
+func (o *observer) decayed(r *observedRate, now time.Time) float64 {
+	elapsed := max(now.Sub(r.last), 0)
+	return r.rate * math.Exp(-float64(elapsed)/float64(o.window))
+}
+
+// evictIdle forgets the clients that have not made a request since
+// idleTimeout before now.
+func (o *observer) evictIdle(now time.Time, idleTimeout time.Duration) {
+	o.clients.each(func(client string, r *observedRate) bool {
+		return now.Sub(r.last) < idleTimeout
+	})
+}
@@ -0,0 +1,51 @@
+package throttle
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestObservedRate(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Hour, WithBurst(100), WithObservedRate(1*time.Minute), WithClock(clock))
+	if throttle.ObservedRate("alice") != 0 || len(throttle.TopObserved(3)) != 0 {
+		t.Fatal("expected no rates before any request")
+	}
+
+	// alice makes one request per second, bob one per ten seconds; most of
+	// alice's requests are rejected, which still counts
+	for i := 0; i < 600; i++ {
+		throttle.Allow("alice")
+		if i%10 == 0 {
+			throttle.Allow("bob")
+		}
+		clock.Advance(1 * time.Second)
+	}
+	near := func(rate, expected float64) bool {
+		return math.Abs(rate-expected) < expected/10
+	}
+	if rate := throttle.ObservedRate("alice"); !near(rate, 1) {
+		t.Errorf("alice: expected about 1 request per second, got %.3f", rate)
+	}
+	if rate := throttle.ObservedRate("bob"); !near(rate, 0.1) {
+		t.Errorf("bob: expected about 0.1 requests per second, got %.3f", rate)
+	}
+	top := throttle.TopObserved(1)
+	if len(top) != 1 || top[0].Client != "alice" {
+		t.Errorf("expected alice to be at the top, got %v", top)
+	}
+	if top := throttle.TopObserved(5); len(top) != 2 || top[1].Client != "bob" {
+		t.Errorf("expected alice and bob, got %v", top)
+	}
+
+	before := throttle.ObservedRate("alice")
+	clock.Advance(1 * time.Minute)
+	if rate := throttle.ObservedRate("alice"); !near(rate, before/math.E) {
+		t.Errorf("idle alice: expected the rate to decay to %.3f, got %.3f", before/math.E, rate)
+	}
+
+	if New(1*time.Second).ObservedRate("alice") != 0 {
+		t.Errorf("without WithObservedRate: expected no rate")
+	}
+}
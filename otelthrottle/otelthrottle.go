@@ -0,0 +1,65 @@
+// Package otelthrottle records the waits of a throttle.Throttle in
+// OpenTelemetry traces, so that throttling shows up as such instead of as
+// unexplained latency:
+//
+//	t := throttle.New(time.Second, otelthrottle.WithTracing())
+package otelthrottle
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/patrickbucher/throttle"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// EventName is the name of the span events recorded.
+const EventName = "throttle.wait"
+
+// Outcomes of a wait, as recorded in the throttle.outcome attribute.
+const (
+	OutcomeAllowed   = "allowed"
+	OutcomeRejected  = "rejected"
+	OutcomeCancelled = "cancelled"
+	OutcomeError     = "error"
+)
+
+// WithTracing returns an option that records an event on the span of the
+// waiting request's context whenever a request had to wait for its token or
+// did not get one. The event carries the client, the time waited, and the
+// outcome of the wait.
+func WithTracing() throttle.Option {
+	return throttle.WithWaitHook(record)
+}
+
+func record(ctx context.Context, client string, waited time.Duration, err error) {
+	outcome := outcome(err)
+	if outcome == OutcomeAllowed && waited == 0 {
+		// the token was available right away, there is nothing to explain
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	span.AddEvent(EventName, trace.WithAttributes(
+		attribute.String("throttle.client", client),
+		attribute.Float64("throttle.waited_seconds", waited.Seconds()),
+		attribute.String("throttle.outcome", outcome),
+	))
+}
+
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeAllowed
+	case errors.Is(err, throttle.ErrThrottled):
+		return OutcomeRejected
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return OutcomeCancelled
+	default:
+		return OutcomeError
+	}
+}
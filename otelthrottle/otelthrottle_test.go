@@ -0,0 +1,49 @@
+package otelthrottle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/patrickbucher/throttle"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestWithTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	limiter := throttle.New(50*time.Millisecond, WithTracing(), throttle.WithMaxWait(100*time.Millisecond))
+
+	ctx, span := tracer.Start(context.Background(), "request")
+	limiter.WaitContext(ctx, "alice") // served right away
+	limiter.WaitContext(ctx, "alice") // waits for the next token
+	limiter.TryWaitFor("alice", 0)    // no span in context
+	span.End()
+
+	events := recorder.Ended()[0].Events()
+	if len(events) != 1 {
+		t.Fatalf("expected %d event, got %d", 1, len(events))
+	}
+	attrs := attribute.NewSet(events[0].Attributes...)
+	if v, _ := attrs.Value("throttle.outcome"); v.AsString() != OutcomeAllowed {
+		t.Errorf("outcome: expected %q, got %q", OutcomeAllowed, v.AsString())
+	}
+	if v, _ := attrs.Value("throttle.waited_seconds"); v.AsFloat64() <= 0 {
+		t.Errorf("waited: expected to be positive, got %v", v.AsFloat64())
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	limiter := throttle.New(1*time.Second, throttle.WithMaxWait(10*time.Millisecond))
+	limiter.Wait("alice")
+	if o := outcome(limiter.Wait("alice")); o != OutcomeRejected {
+		t.Errorf("expected %q, got %q", OutcomeRejected, o)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if o := outcome(ctx.Err()); o != OutcomeCancelled {
+		t.Errorf("expected %q, got %q", OutcomeCancelled, o)
+	}
+}
@@ -0,0 +1,84 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Run waits for a token of the client and calls f, returning its error, or
+// the error of the Throttle if no token is acquired, in which case f is not
+// called.
+func (t *Throttle) Run(ctx context.Context, client string, f func() error) error {
+	if err := t.WaitContext(ctx, client); err != nil {
+		return err
+	}
+	return f()
+}
+
+// Pacer runs functions no faster than its Throttle allows, e.g. for batch jobs
+// like sending mails or delivering webhooks, using up to a fixed number of
+// workers at once. A Throttle created using WithPacing makes the functions
+// wait for as long as it takes; otherwise, functions whose token would come
+// too late are not run, and fail with the Throttle's error.
+//
+//	pacer := throttle.NewPacer(ctx, throttle.New(100*time.Millisecond, throttle.WithPacing()), 4)
+//	for _, mail := range mails {
+//		pacer.Go(mail.Domain, func() error { return send(mail) })
+//	}
+//	err := pacer.Wait()
+type Pacer struct {
+	ctx      context.Context
+	throttle *Throttle
+	workers  chan struct{}
+	wg       sync.WaitGroup
+	mutex    sync.Mutex
+	errs     []error
+}
+
+// NewPacer creates a Pacer running the functions paced by t using at most the
+// given number of workers, or one if workers is less than one. Functions that
+// have not been run when ctx is done fail with the context's error.
+func NewPacer(ctx context.Context, t *Throttle, workers int) *Pacer {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Pacer{ctx: ctx, throttle: t, workers: make(chan struct{}, workers)}
+}
+
+// Go runs f in a worker once the client has a token. If all workers are busy,
+// Go blocks until one is free, so that the functions queue up in the caller
+// rather than in memory.
+func (p *Pacer) Go(client string, f func() error) {
+	p.wg.Add(1)
+	select {
+	case p.workers <- struct{}{}:
+	case <-p.ctx.Done():
+		p.fail(p.ctx.Err())
+		p.wg.Done()
+		return
+	}
+	p.throttle.spawn(func() {
+		defer p.wg.Done()
+		defer func() { <-p.workers }()
+		if err := p.throttle.Run(p.ctx, client, f); err != nil {
+			p.fail(err)
+		}
+	})
+}
+
+// Wait waits for all functions passed to Go, and returns their errors joined
+// using errors.Join, or nil if all of them succeeded.
+func (p *Pacer) Wait() error {
+	p.wg.Wait()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return errors.Join(p.errs...)
+}
+
+// fail records the error of a function.
+func (p *Pacer) fail(err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.errs = append(p.errs, err)
+}
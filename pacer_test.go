@@ -0,0 +1,85 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	throttle := New(1*time.Hour, WithMaxWait(time.Millisecond))
+	defer throttle.Close()
+	failed := errors.New("failed")
+	calls := 0
+	f := func() error {
+		calls++
+		return failed
+	}
+	if err := throttle.Run(context.Background(), "alice", f); err != failed {
+		t.Errorf("expected error of f, got %v", err)
+	}
+	if err := throttle.Run(context.Background(), "alice", f); !errors.Is(err, ErrThrottled) {
+		t.Errorf("expected %v, got %v", ErrThrottled, err)
+	}
+	if calls != 1 {
+		t.Errorf("expected f to be called once, got %d", calls)
+	}
+}
+
+func TestPacer(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Second, WithPacing(), WithClock(clock))
+	defer throttle.Close()
+	pacer := NewPacer(context.Background(), throttle, 2)
+
+	var done atomic.Int32
+	failed := errors.New("failed")
+	go func() {
+		for i := 0; i < 4; i++ {
+			pacer.Go("mail", func() error {
+				done.Add(1)
+				if i == 3 {
+					return failed
+				}
+				return nil
+			})
+		}
+	}()
+
+	for done.Load() < 4 {
+		if clock.Waiters() > 0 {
+			clock.Advance(1 * time.Second)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := pacer.Wait(); !errors.Is(err, failed) {
+		t.Errorf("expected %v, got %v", failed, err)
+	}
+	if stats := throttle.Stats("mail"); stats.Allowed != 4 {
+		t.Errorf("expected 4 tokens taken, got %+v", stats)
+	}
+}
+
+func TestPacerCancelled(t *testing.T) {
+	throttle := New(1*time.Hour, WithPacing())
+	defer throttle.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	pacer := NewPacer(ctx, throttle, 1)
+
+	ran := 0
+	pacer.Go("mail", func() error { ran++; return nil })
+	pacer.Go("mail", func() error { ran++; return nil })
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	pacer.Go("mail", func() error { ran++; return nil })
+	if err := pacer.Wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	if ran != 1 {
+		t.Errorf("expected only the first function to run, got %d", ran)
+	}
+}
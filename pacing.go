@@ -0,0 +1,24 @@
+package throttle
+
+import (
+	"math"
+	"time"
+)
+
+// WithPacing makes the Throttle shape the traffic of every client rather than
+// police it: a client's requests are spaced one request rate apart, and each
+// request is delayed just as long as that takes, instead of being rejected
+// once its wait exceeds the maximum waiting time. This suits downstreams that
+// tolerate any volume, provided that it is evenly spread. The burst is one,
+// whatever is given to WithBurst. A request still gives up when its context
+// is done; WithMaxWait and WithMaxWaiters bound the delay and the number of
+// delayed requests, if given.
+func WithPacing() Option {
+	return func(t *Throttle) {
+		t.pacing = true
+	}
+}
+
+// unlimitedWait is the maximum waiting time of a request of a pacing
+// Throttle without WithMaxWait.
+const unlimitedWait = time.Duration(math.MaxInt64)
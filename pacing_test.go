@@ -0,0 +1,57 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPacing(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Second, WithPacing(), WithBurst(10), WithClock(clock))
+	defer throttle.Close()
+
+	const requests = 5
+	done := make(chan time.Time, requests)
+	for i := 0; i < requests; i++ {
+		go func() {
+			if err := throttle.Wait("alice"); err != nil {
+				t.Errorf("paced request rejected: %v", err)
+			}
+			done <- clock.Now()
+		}()
+	}
+	start := clock.Now()
+	for i := 0; i < requests; i++ {
+		if i > 0 {
+			for clock.Waiters() < requests-i {
+				time.Sleep(time.Millisecond)
+			}
+			clock.Advance(1 * time.Second)
+		}
+		if got, want := (<-done).Sub(start), time.Duration(i)*time.Second; got != want {
+			t.Errorf("request %d emitted after %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestPacingContext(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Hour, WithPacing(), WithClock(clock))
+	defer throttle.Close()
+	throttle.Allow("alice")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- throttle.WaitContext(ctx, "alice")
+	}()
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled paced request: expected %v, got %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,24 @@
+package throttle
+
+import "errors"
+
+// ErrPaused is returned for requests to a Throttle that has been paused.
+var ErrPaused = errors.New("throttle paused")
+
+// Pause rejects all requests with ErrPaused until Resume is called, e.g.
+// during maintenance or while a downstream dependency is down. Requests of
+// exempted clients still pass, and requests already waiting for a token are
+// served as usual.
+func (t *Throttle) Pause() {
+	t.paused.Store(true)
+}
+
+// Resume lets requests pass again after Pause.
+func (t *Throttle) Resume() {
+	t.paused.Store(false)
+}
+
+// Paused reports whether the Throttle is paused.
+func (t *Throttle) Paused() bool {
+	return t.paused.Load()
+}
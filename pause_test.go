@@ -0,0 +1,38 @@
+package throttle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPause(t *testing.T) {
+	throttle := New(1 * time.Millisecond)
+	throttle.Exempt("health")
+	throttle.Pause()
+	if !throttle.Paused() {
+		t.Errorf("expected to be paused")
+	}
+	if err := throttle.Wait("alice"); err != ErrPaused {
+		t.Errorf("paused: expected %v, got %v", ErrPaused, err)
+	}
+	if throttle.Allow("alice") {
+		t.Errorf("paused: expected Allow to report false")
+	}
+	if err := throttle.Wait("health"); err != nil {
+		t.Errorf("exempted client: expected no error, got %v", err)
+	}
+
+	handler := throttle.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("middleware: expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+
+	throttle.Resume()
+	if err := throttle.Wait("alice"); err != nil {
+		t.Errorf("resumed: expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// maxPenalty is the factor by which the request rate of a client is slowed
+// down at most as a penalty.
+const maxPenalty = 64
+
+// penalties holds the offences of the clients rejected recently.
+type penalties struct {
+	threshold int
+	window    time.Duration
+	mutex     sync.Mutex
+	clients   map[string]*offender
+}
+
+// offender is the record of a client's rejections.
+type offender struct {
+	start     time.Time
+	count     int
+	factor    int
+	escalated time.Time
+}
+
+// WithPenalty punishes clients that keep on making requests despite being
+// rejected: once a client has been rejected threshold times within the
+// window, its request rate is doubled, up to 64 times the normal rate. Every
+// window without escalation halves the client's penalty again.
+func WithPenalty(threshold int, window time.Duration) Option {
+	return func(t *Throttle) {
+		if threshold < 1 || window <= 0 {
+			return
+		}
+		t.penalties = &penalties{
+			threshold: threshold,
+			window:    window,
+			clients:   make(map[string]*offender),
+		}
+	}
+}
+
+// offend records a rejection of the client at the given time.
+func (p *penalties) offend(client string, at time.Time) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	o, ok := p.clients[client]
+	if !ok {
+		o = &offender{start: at, factor: 1}
+		p.clients[client] = o
+	}
+	o.factor = o.decayed(at, p.window)
+	if at.Sub(o.start) >= p.window {
+		o.start, o.count = at, 0
+	}
+	if o.count++; o.count >= p.threshold {
+		if o.factor *= 2; o.factor > maxPenalty {
+			o.factor = maxPenalty
+		}
+		o.start, o.count, o.escalated = at, 0, at
+	}
+}
+
+// factor returns the factor by which the client's rate is slowed down at the
+// given time.
+func (p *penalties) factor(client string, now time.Time) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	o, ok := p.clients[client]
+	if !ok {
+		return 1
+	}
+	factor := o.decayed(now, p.window)
+	if factor == 1 && now.Sub(o.start) >= p.window {
+		// neither penalized nor offending, so the client can be forgotten
+		delete(p.clients, client)
+	}
+	return factor
+}
+
+// forgive forgets the client's offences.
+func (p *penalties) forgive(client string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.clients, client)
+}
+
+// decayed returns the offender's factor halved for every window since it was
+// escalated.
+func (o *offender) decayed(now time.Time, window time.Duration) int {
+	if o.factor == 1 {
+		return 1
+	}
+	halvings := now.Sub(o.escalated) / window
+	if halvings >= 7 {
+		return 1
+	}
+	if factor := o.factor >> halvings; factor > 1 {
+		return factor
+	}
+	return 1
+}
+
+// penalize returns the rate slowed down by the client's penalty.
+func (t *Throttle) penalize(client string, rate time.Duration) time.Duration {
+	if t.penalties == nil {
+		return rate
+	}
+	return rate * time.Duration(t.penalties.factor(client, t.clock.Now()))
+}
@@ -0,0 +1,45 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPenalty(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithPenalty(3, 1*time.Minute), WithClock(clock))
+	throttle.Allow("alice")
+
+	reject := func(n int) {
+		for i := 0; i < n; i++ {
+			if throttle.Allow("alice") {
+				t.Fatalf("request %d: expected to be rejected", i)
+			}
+		}
+	}
+	reject(2)
+	if rate := throttle.limit("alice").Rate; rate != 100*time.Millisecond {
+		t.Errorf("below threshold: expected rate %v, got %v", 100*time.Millisecond, rate)
+	}
+	reject(1)
+	if rate := throttle.limit("alice").Rate; rate != 200*time.Millisecond {
+		t.Errorf("at threshold: expected rate %v, got %v", 200*time.Millisecond, rate)
+	}
+	reject(3)
+	if rate := throttle.limit("alice").Rate; rate != 400*time.Millisecond {
+		t.Errorf("repeated offence: expected rate %v, got %v", 400*time.Millisecond, rate)
+	}
+	if rate := throttle.limit("bob").Rate; rate != 100*time.Millisecond {
+		t.Errorf("other client: expected rate %v, got %v", 100*time.Millisecond, rate)
+	}
+
+	// penalties decay by half per window
+	clock.Advance(1 * time.Minute)
+	if rate := throttle.limit("alice").Rate; rate != 200*time.Millisecond {
+		t.Errorf("one window later: expected rate %v, got %v", 200*time.Millisecond, rate)
+	}
+	clock.Advance(1 * time.Minute)
+	if rate := throttle.limit("alice").Rate; rate != 100*time.Millisecond {
+		t.Errorf("two windows later: expected rate %v, got %v", 100*time.Millisecond, rate)
+	}
+}
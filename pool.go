@@ -0,0 +1,66 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// WithTimerPool makes the Throttle reuse the timers of waiting requests and
+// the nodes of its queues, which is the default, so that sustained traffic of
+// waiting requests does not churn the garbage collector. Without the pool,
+// every waiting request allocates its own, which makes it simpler to reason
+// about, e.g. when debugging, at the cost of throughput.
+func WithTimerPool(on bool) Option {
+	return func(t *Throttle) {
+		t.unpooled = !on
+	}
+}
+
+// fairWaiters are the nodes of the fair queue ready to be reused.
+var fairWaiters sync.Pool
+
+// newWaiter returns a waiter for a request for n tokens of the given priority
+// and rank, to be pushed to a queue. Its ready channel is signalled exactly
+// once when the request has been served or dropped.
+func (t *Throttle) newWaiter(prio Priority, rank time.Time, n int) *waiter {
+	var w *waiter
+	if !t.unpooled {
+		w, _ = t.queues.pool.Get().(*waiter)
+	}
+	if w == nil {
+		w = &waiter{ready: make(chan struct{}, 1)}
+	}
+	w.prio, w.rank, w.n, w.err = prio, rank, n, nil
+	return w
+}
+
+// releaseWaiter puts a waiter back into the pool. It requires the waiter to be
+// removed from its queue, and its ready channel to be drained.
+func (t *Throttle) releaseWaiter(w *waiter) {
+	if !t.unpooled {
+		w.err = nil
+		t.queues.pool.Put(w)
+	}
+}
+
+// newFairWaiter returns a fairWaiter for a request for n tokens of the
+// client, like newWaiter.
+func (t *Throttle) newFairWaiter(client string, n int) *fairWaiter {
+	var w *fairWaiter
+	if !t.unpooled {
+		w, _ = fairWaiters.Get().(*fairWaiter)
+	}
+	if w == nil {
+		w = &fairWaiter{ready: make(chan struct{}, 1)}
+	}
+	w.client, w.n = client, n
+	return w
+}
+
+// releaseFairWaiter puts a fairWaiter back into the pool, like releaseWaiter.
+func (t *Throttle) releaseFairWaiter(w *fairWaiter) {
+	if !t.unpooled {
+		w.client = ""
+		fairWaiters.Put(w)
+	}
+}
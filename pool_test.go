@@ -0,0 +1,78 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimerPool(t *testing.T) {
+	for _, on := range []bool{true, false} {
+		t.Run(fmt.Sprintf("pool %v", on), func(t *testing.T) {
+			throttle := New(1*time.Millisecond, WithPriorities(), WithMaxWait(1*time.Second), WithTimerPool(on))
+			defer throttle.Close()
+			if _, timer := throttle.after(time.Millisecond); (timer != nil) != on {
+				t.Errorf("expected pooled timer %v, got %v", on, timer)
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 3; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 20; j++ {
+						if err := throttle.Wait("alice"); err != nil {
+							t.Errorf("expected no error, got %v", err)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			// a request giving up leaves its waiter ready for the next one
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Microsecond)
+			defer cancel()
+			throttle.Allow("bob")
+			throttle.WaitContext(ctx, "bob")
+			if err := throttle.Wait("bob"); err != nil {
+				t.Errorf("after cancellation: expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFairWaiterPool(t *testing.T) {
+	throttle := New(1*time.Nanosecond, WithBurst(10), WithGlobalLimit(1*time.Millisecond, 1),
+		WithFairQueueing(nil), WithMaxWait(1*time.Second))
+	defer throttle.Close()
+	var wg sync.WaitGroup
+	for _, client := range []string{"alice", "bob"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				if err := throttle.Wait(client); err != nil && !errors.Is(err, ErrThrottled) {
+					t.Errorf("%s: expected no error, got %v", client, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkWaitQueued(b *testing.B) {
+	// every request waits in the priority queue for its token
+	for _, on := range []bool{true, false} {
+		b.Run(fmt.Sprintf("pool=%v", on), func(b *testing.B) {
+			throttle := New(1*time.Microsecond, WithPriorities(), WithMaxWait(1*time.Hour), WithTimerPool(on))
+			defer throttle.Close()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				throttle.Wait("alice")
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package throttle
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// Priority orders the waiting requests of a client made using WaitPriority.
+type Priority int
+
+const (
+	// PriorityLow is for requests that may wait for all others.
+	PriorityLow Priority = -1
+
+	// PriorityNormal is the priority of requests made using Wait.
+	PriorityNormal Priority = 0
+
+	// PriorityHigh is for requests that go first, e.g. health checks.
+	PriorityHigh Priority = 1
+)
+
+// WithPriorities makes the requests of a client that have to wait for a token
+// line up in a queue ordered by their priority, rather than reserve a token
+// upon arrival. Whenever a token becomes available, it is given to the waiting
+// request of the highest priority, and to the one that arrived first among
+// requests of the same priority. Requests that are not served within the
+// maximum waiting time are rejected. Allow and Reserve do not wait, and thus
+// take tokens regardless of the requests waiting.
+func WithPriorities() Option {
+	return func(t *Throttle) {
+		t.queues = &priorityQueues{clients: make(map[string]*priorityQueue)}
+	}
+}
+
+// WithAging raises the priority of a request waiting in a queue of
+// WithPriorities by one level for every d it has been waiting, so that under
+// sustained contention requests of a low priority are not starved by
+// requests of a higher priority arriving all the time: a request waiting
+// longer than d times the difference of their priorities goes before any
+// request arriving later. Aging only takes effect with WithPriorities.
+func WithAging(d time.Duration) Option {
+	return func(t *Throttle) {
+		t.aging = d
+	}
+}
+
+// rank returns the time a request of the given priority arriving at now is
+// served by, relative to requests of other priorities, when aging. Since all
+// waiting requests age at the same pace, the order of their ranks never
+// changes. Without aging, the zero time is returned.
+func (t *Throttle) rank(prio Priority, now time.Time) time.Time {
+	if t.aging <= 0 {
+		return time.Time{}
+	}
+	return now.Add(-time.Duration(prio) * t.aging)
+}
+
+// WaitPriority works like Wait, but lets requests of a higher priority acquire
+// the client's tokens before requests of a lower priority. Priorities only
+// take effect with WithPriorities; requests made using Wait have
+// PriorityNormal.
+func (t *Throttle) WaitPriority(client string, prio Priority) error {
+	client = t.key(client)
+	_, err := t.wait(context.Background(), client, 1, prio, t.timeout(client, 1))
+	return err
+}
+
+// priorityQueues holds the queues of the clients having waiting requests. Its
+// waiters ready to be reused are pooled per Throttle, so that a dispatcher
+// holding on to a waiter reused in the meantime only ever sees it change under
+// the mutex.
+type priorityQueues struct {
+	mutex   sync.Mutex
+	seq     uint64
+	clients map[string]*priorityQueue
+	pool    sync.Pool
+}
+
+// priorityQueue is a heap of the waiting requests of a client, which is served
+// by its own goroutine as long as it is not empty.
+type priorityQueue struct {
+	waiters []*waiter
+	wake    chan struct{}
+}
+
+// waiter is a request waiting in a priorityQueue. Since waiters are reused,
+// the queue and the seq tell whether a waiter is still the one queued.
+type waiter struct {
+	prio  Priority
+	rank  time.Time
+	seq   uint64
+	n     int
+	index int
+	queue *priorityQueue
+	err   error
+	ready chan struct{}
+}
+
+// acquireQueued takes n tokens for the client within the timeout, waiting in
+// the client's queue if they are not available right away. It returns how long
+// it blocked waiting for the tokens.
+func (t *Throttle) acquireQueued(ctx context.Context, client string, n int, prio Priority, timeout time.Duration) (time.Duration, error) {
+	if t.closed() {
+		return 0, ErrClosed
+	}
+	if n < 1 {
+		return 0, nil
+	}
+	start := t.clock.Now()
+	if !t.queues.waiting(client) {
+		// nobody is ahead of the request
+		_, err := t.consume(ctx, client, n, 0)
+		throttledErr, ok := asThrottled(err)
+		switch {
+		case err == nil:
+			t.allowed(client, start, 0)
+			return 0, nil
+		case !ok:
+			return 0, err
+		case throttledErr.RetryAfter > timeout:
+			t.rejected(client, start)
+			return 0, err
+		}
+	}
+	rank := t.rank(prio, start)
+	if !t.metrics.startWaiting(client, t.maxWaiters) && !t.dropWaiter(client, prio, rank) {
+		t.rejected(client, start)
+		return 0, &ThrottledError{Client: client, Rate: t.rate(client), RetryAfter: t.rate(client)}
+	}
+	defer t.metrics.waiting(client, -1)
+
+	w := t.newWaiter(prio, rank, n)
+	t.queues.push(client, w, func(client string, queue *priorityQueue) {
+		t.spawn(func() { t.dispatch(client, queue) })
+	})
+	after, timer := t.after(timeout)
+	defer stopTimer(timer)
+	select {
+	case <-w.ready:
+		defer t.releaseWaiter(w)
+		return t.served(client, w, start)
+	case <-after:
+		defer t.releaseWaiter(w)
+		if !t.queues.remove(w, w.seq) {
+			<-w.ready
+			return t.served(client, w, start)
+		}
+		t.rejected(client, t.clock.Now())
+		return t.clock.Now().Sub(start), &ThrottledError{Client: client, Rate: t.rate(client), RetryAfter: t.rate(client)}
+	case <-t.done:
+		// the waiter may still be queued, so it is not reused
+		return t.clock.Now().Sub(start), ErrClosed
+	case <-ctx.Done():
+		defer t.releaseWaiter(w)
+		if !t.queues.remove(w, w.seq) {
+			// served in the meantime: the caller is no longer interested
+			<-w.ready
+			if w.err == nil {
+				t.refund(client, n)
+			}
+		}
+		return t.clock.Now().Sub(start), ctx.Err()
+	}
+}
+
+// served completes the request of a waiter whose ready channel has been
+// signalled.
+func (t *Throttle) served(client string, w *waiter, start time.Time) (time.Duration, error) {
+	waited := t.clock.Now().Sub(start)
+	if w.err != nil {
+		return waited, w.err
+	}
+	t.allowed(client, start, waited)
+	return waited, nil
+}
+
+// dispatch hands out the client's tokens to the requests in its queue, until
+// the queue is empty or the Throttle is closed.
+func (t *Throttle) dispatch(client string, queue *priorityQueue) {
+	for {
+		w, seq, n := t.queues.head(client, queue)
+		if w == nil {
+			return
+		}
+		// the request may give up while the tokens are taken, and its waiter
+		// be reused by another one, so only seq and n are to be relied upon
+		_, err := t.consume(context.Background(), client, n, 0)
+		if throttledErr, ok := asThrottled(err); ok {
+			// wait for the tokens or for a request going first
+			after, timer := t.after(throttledErr.RetryAfter)
+			select {
+			case <-after:
+			case <-queue.wake:
+			case <-t.done:
+				stopTimer(timer)
+				return
+			}
+			stopTimer(timer)
+			continue
+		}
+		if !t.queues.remove(w, seq) {
+			// the request gave up in the meantime
+			if err == nil {
+				t.refund(client, n)
+			}
+			continue
+		}
+		w.err = err
+		w.ready <- struct{}{}
+	}
+}
+
+// waiting reports whether requests of the client are waiting.
+func (q *priorityQueues) waiting(client string) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	_, ok := q.clients[client]
+	return ok
+}
+
+// push adds the request of the waiter to the client's queue, ordered by its
+// rank and priority. If the queue is new, dispatch is called to start serving
+// it in a goroutine of its own.
+func (q *priorityQueues) push(client string, w *waiter, dispatch func(string, *priorityQueue)) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.seq++
+	w.seq = q.seq
+	queue, ok := q.clients[client]
+	if !ok {
+		queue = &priorityQueue{wake: make(chan struct{}, 1)}
+		q.clients[client] = queue
+		dispatch(client, queue)
+	}
+	heap.Push(queue, w)
+	if w.index == 0 {
+		select {
+		case queue.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// head returns the first request of the client's queue, together with its
+// seq and the number of tokens it takes. If the queue is empty, it is removed,
+// and nil is returned.
+func (q *priorityQueues) head(client string, queue *priorityQueue) (*waiter, uint64, int) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if queue.Len() == 0 {
+		delete(q.clients, client)
+		return nil, 0, 0
+	}
+	w := queue.waiters[0]
+	return w, w.seq, w.n
+}
+
+// remove removes the request queued with the given seq from its queue. It
+// reports false if the request has been removed before, even if the waiter
+// has been queued again for another request since.
+func (q *priorityQueues) remove(w *waiter, seq uint64) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if w.queue == nil || w.seq != seq {
+		return false
+	}
+	heap.Remove(w.queue, w.index)
+	return true
+}
+
+func (q *priorityQueue) Len() int {
+	return len(q.waiters)
+}
+
+func (q *priorityQueue) Less(i, j int) bool {
+	return q.waiters[i].before(q.waiters[j])
+}
+
+// before reports whether the request is served before the other one.
+func (w *waiter) before(other *waiter) bool {
+	if !w.rank.Equal(other.rank) {
+		return w.rank.Before(other.rank)
+	}
+	if w.prio != other.prio {
+		return w.prio > other.prio
+	}
+	return w.seq < other.seq
+}
+
+func (q *priorityQueue) Swap(i, j int) {
+	q.waiters[i], q.waiters[j] = q.waiters[j], q.waiters[i]
+	q.waiters[i].index = i
+	q.waiters[j].index = j
+}
+
+func (q *priorityQueue) Push(x interface{}) {
+	w := x.(*waiter)
+	w.index, w.queue = len(q.waiters), q
+	q.waiters = append(q.waiters, w)
+}
+
+func (q *priorityQueue) Pop() interface{} {
+	last := len(q.waiters) - 1
+	w := q.waiters[last]
+	q.waiters[last] = nil
+	q.waiters = q.waiters[:last]
+	w.index, w.queue = -1, nil
+	return w
+}
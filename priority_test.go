@@ -0,0 +1,147 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// queued returns the number of the client's requests in its priority queue.
+func queued(throttle *Throttle, client string) int {
+	throttle.queues.mutex.Lock()
+	defer throttle.queues.mutex.Unlock()
+	if queue, ok := throttle.queues.clients[client]; ok {
+		return queue.Len()
+	}
+	return 0
+}
+
+func TestWaitPriority(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithPriorities(), WithMaxWait(1*time.Second), WithClock(clock))
+	throttle.Allow("alice")
+
+	served := make(chan Priority, 3)
+	wait := func(prio Priority) {
+		if err := throttle.WaitPriority("alice", prio); err != nil {
+			t.Errorf("priority %d: expected no error, got %v", prio, err)
+		}
+		served <- prio
+	}
+	for i, prio := range []Priority{PriorityLow, PriorityNormal, PriorityHigh} {
+		go wait(prio)
+		for queued(throttle, "alice") < i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	expected := []Priority{PriorityHigh, PriorityNormal, PriorityLow}
+	for i, exp := range expected {
+		clock.Advance(100 * time.Millisecond)
+		if prio := <-served; prio != exp {
+			t.Errorf("token %d: expected to be given to priority %d, got %d", i, exp, prio)
+		}
+	}
+}
+
+func TestWaitPriorityAging(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(10*time.Second, WithPriorities(), WithAging(1*time.Second), WithMaxWait(1*time.Minute), WithClock(clock))
+	throttle.Allow("alice")
+
+	served := make(chan Priority, 3)
+	wait := func(prio Priority) {
+		if err := throttle.WaitPriority("alice", prio); err != nil {
+			t.Errorf("priority %d: expected no error, got %v", prio, err)
+		}
+		served <- prio
+	}
+	go wait(PriorityLow)
+	for queued(throttle, "alice") < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	// the low priority request has aged beyond the high priority ones
+	clock.Advance(3 * time.Second)
+	for i, prio := range []Priority{PriorityNormal, PriorityHigh} {
+		go wait(prio)
+		for queued(throttle, "alice") < i+2 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	expected := []Priority{PriorityLow, PriorityHigh, PriorityNormal}
+	for i, exp := range expected {
+		clock.Advance(10 * time.Second)
+		if prio := <-served; prio != exp {
+			t.Errorf("token %d: expected to be given to priority %d, got %d", i, exp, prio)
+		}
+	}
+}
+
+func TestWaitPriorityTimeout(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithPriorities(), WithMaxWait(1*time.Second), WithClock(clock))
+	throttle.Allow("alice")
+
+	done := make(chan error)
+	go func() {
+		done <- throttle.WaitPriority("alice", PriorityLow)
+	}()
+	for queued(throttle, "alice") < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	rejected := make(chan error)
+	go func() {
+		rejected <- throttle.TryWaitFor("alice", 50*time.Millisecond)
+	}()
+	for queued(throttle, "alice") < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(50 * time.Millisecond)
+	if err := <-rejected; !errors.Is(err, ErrThrottled) {
+		t.Errorf("request behind queued one: expected %v, got %v", ErrThrottled, err)
+	}
+	clock.Advance(50 * time.Millisecond)
+	if err := <-done; err != nil {
+		t.Errorf("queued request: expected no error, got %v", err)
+	}
+}
+
+// slowStore is a Store taking its time to take tokens.
+type slowStore struct {
+	*memoryStore
+}
+
+func (s slowStore) TryConsume(ctx context.Context, client string, limit Limit, n int, maxWait time.Duration) (Result, error) {
+	time.Sleep(100 * time.Microsecond)
+	return s.memoryStore.TryConsume(ctx, client, limit, n, maxWait)
+}
+
+func TestWaitPriorityCancelled(t *testing.T) {
+	throttle := New(200*time.Microsecond, WithPriorities(), WithMaxWait(1*time.Second),
+		WithStore(slowStore{newMemoryStore(realClock{})}))
+	defer throttle.Close()
+
+	// requests giving up while the dispatcher takes their tokens must not
+	// have their waiters reused behind its back
+	var wg sync.WaitGroup
+	for i := range 32 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client := fmt.Sprintf("client-%d", i%4)
+			for j := range 50 {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Duration(j%5)*100*time.Microsecond)
+				err := throttle.WaitContext(ctx, client)
+				cancel()
+				if err != nil && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, ErrThrottled) {
+					t.Errorf("%s: expected no error or a timeout, got %v", client, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,61 @@
+// Package promthrottle exports the metrics of a throttle.Throttle to
+// Prometheus:
+//
+//	prometheus.MustRegister(promthrottle.NewCollector(t, prometheus.Labels{"throttle": "api"}))
+package promthrottle
+
+import (
+	"github.com/patrickbucher/throttle"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a prometheus.Collector for the metrics of a Throttle.
+type Collector struct {
+	throttle *throttle.Throttle
+	allowed  *prometheus.Desc
+	rejected *prometheus.Desc
+	wait     *prometheus.Desc
+	clients  *prometheus.Desc
+}
+
+// NewCollector creates a Collector for the given Throttle. The labels are
+// attached to all metrics, so that multiple Throttles can be told apart.
+func NewCollector(t *throttle.Throttle, labels prometheus.Labels) *Collector {
+	return &Collector{
+		throttle: t,
+		allowed: prometheus.NewDesc("throttle_requests_allowed_total",
+			"Number of requests that acquired a token.", nil, labels),
+		rejected: prometheus.NewDesc("throttle_requests_rejected_total",
+			"Number of requests rejected because no token could be acquired in time.", nil, labels),
+		wait: prometheus.NewDesc("throttle_wait_seconds",
+			"Time allowed requests waited for their token.", nil, labels),
+		clients: prometheus.NewDesc("throttle_clients",
+			"Number of clients tracked.", nil, labels),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.allowed
+	ch <- c.rejected
+	ch <- c.wait
+	ch <- c.clients
+}
+
+// Collect implements prometheus.Collector. The number of clients is omitted if
+// the Throttle's Store cannot tell.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	metrics := c.throttle.Metrics()
+	ch <- prometheus.MustNewConstMetric(c.allowed, prometheus.CounterValue, float64(metrics.Allowed))
+	ch <- prometheus.MustNewConstMetric(c.rejected, prometheus.CounterValue, float64(metrics.Rejected))
+
+	buckets := make(map[float64]uint64, len(metrics.Wait.Buckets))
+	for _, bucket := range metrics.Wait.Buckets {
+		buckets[bucket.UpperBound.Seconds()] = bucket.Count
+	}
+	ch <- prometheus.MustNewConstHistogram(c.wait, metrics.Wait.Count, metrics.Wait.Sum.Seconds(), buckets)
+
+	if metrics.Clients >= 0 {
+		ch <- prometheus.MustNewConstMetric(c.clients, prometheus.GaugeValue, float64(metrics.Clients))
+	}
+}
@@ -0,0 +1,39 @@
+package promthrottle
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/patrickbucher/throttle"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	limiter := throttle.New(1 * time.Second)
+	limiter.Allow("alice")
+	limiter.Allow("alice")
+	limiter.Allow("bob")
+
+	collector := NewCollector(limiter, prometheus.Labels{"throttle": "test"})
+	expected := `
+# HELP throttle_clients Number of clients tracked.
+# TYPE throttle_clients gauge
+throttle_clients{throttle="test"} 2
+# HELP throttle_requests_allowed_total Number of requests that acquired a token.
+# TYPE throttle_requests_allowed_total counter
+throttle_requests_allowed_total{throttle="test"} 2
+# HELP throttle_requests_rejected_total Number of requests rejected because no token could be acquired in time.
+# TYPE throttle_requests_rejected_total counter
+throttle_requests_rejected_total{throttle="test"} 1
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"throttle_clients", "throttle_requests_allowed_total", "throttle_requests_rejected_total")
+	if err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(collector, "throttle_wait_seconds"); n != 1 {
+		t.Errorf("wait histogram: expected %d metric, got %d", 1, n)
+	}
+}
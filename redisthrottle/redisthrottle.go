@@ -0,0 +1,253 @@
+// Package redisthrottle provides a throttle.Store that keeps the token buckets
+// in Redis, so that multiple instances of a service enforce one shared limit
+// per client:
+//
+//	t := throttle.New(time.Second, throttle.WithStore(redisthrottle.NewStore(client)))
+package redisthrottle
+
+import (
+	"context"
+	"time"
+
+	"github.com/patrickbucher/throttle"
+	"github.com/redis/go-redis/v9"
+)
+
+// prelude adds the tokens spawned since the last update to a client's bucket.
+// All times are measured in microseconds using the Redis server's clock, so
+// that the instances do not have to agree on the time.
+const prelude = `
+redis.replicate_commands()
+local now = redis.call('TIME')
+now = tonumber(now[1]) * 1000000 + tonumber(now[2])
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+
+local tokens = burst
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+if state[1] then
+  local elapsed = math.max(0, now - tonumber(state[2]))
+  tokens = math.min(burst, tonumber(state[1]) + elapsed / rate)
+end
+local wait = 0
+if tokens < n then
+  wait = math.ceil((n - tokens) * rate)
+end
+
+local function save()
+  redis.call('HSET', KEYS[1], 'tokens', tokens, 'last', now)
+  -- a full bucket is the same as no bucket, so it can be forgotten then
+  redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) * rate / 1000) + 1000)
+end
+
+local function result(taken)
+  return {taken, wait, math.floor(math.max(tokens, 0)), math.ceil((burst - tokens) * rate)}
+end
+`
+
+var (
+	consumeScript = redis.NewScript(prelude + `
+local taken = 0
+if wait <= tonumber(ARGV[4]) then
+  tokens = tokens - n
+  taken = 1
+  save()
+end
+return result(taken)
+`)
+	refundScript = redis.NewScript(prelude + `
+tokens = math.min(burst, tokens + n)
+save()
+return result(0)
+`)
+	peekScript = redis.NewScript(prelude + `
+return result(0)
+`)
+)
+
+// consumeAllScript takes the tokens from several buckets, given as KEYS, at
+// once: ARGV holds n and maxWait, followed by the rate and burst of every
+// bucket. Either all buckets provide their tokens in time, or none is changed.
+var consumeAllScript = redis.NewScript(`
+redis.replicate_commands()
+local now = redis.call('TIME')
+now = tonumber(now[1]) * 1000000 + tonumber(now[2])
+local n = tonumber(ARGV[1])
+local maxWait = tonumber(ARGV[2])
+
+local buckets = {}
+local fits = true
+for i, key in ipairs(KEYS) do
+  local rate = tonumber(ARGV[1 + 2 * i])
+  local burst = tonumber(ARGV[2 + 2 * i])
+  local tokens = burst
+  local state = redis.call('HMGET', key, 'tokens', 'last')
+  if state[1] then
+    local elapsed = math.max(0, now - tonumber(state[2]))
+    tokens = math.min(burst, tonumber(state[1]) + elapsed / rate)
+  end
+  local wait = 0
+  if tokens < n then
+    wait = math.ceil((n - tokens) * rate)
+  end
+  if wait > maxWait then
+    fits = false
+  end
+  buckets[i] = {rate = rate, burst = burst, tokens = tokens, wait = wait}
+end
+
+local taken = 0
+if fits then
+  taken = 1
+end
+local results = {}
+for i, key in ipairs(KEYS) do
+  local b = buckets[i]
+  if fits then
+    b.tokens = b.tokens - n
+    redis.call('HSET', key, 'tokens', b.tokens, 'last', now)
+    redis.call('PEXPIRE', key, math.ceil((b.burst - b.tokens) * b.rate / 1000) + 1000)
+  end
+  table.insert(results, taken)
+  table.insert(results, b.wait)
+  table.insert(results, math.floor(math.max(b.tokens, 0)))
+  table.insert(results, math.ceil((b.burst - b.tokens) * b.rate))
+end
+return results
+`)
+
+// Store is a throttle.Store keeping the token buckets in Redis. It is a
+// throttle.BatchStore, taking the tokens of all limits of a request at once,
+// e.g. those of a client and of its tiers, if created using
+// WithAtomicLevels.
+type Store struct {
+	client redis.Scripter
+	prefix string
+	atomic bool
+}
+
+// Option configures a Store created by NewStore.
+type Option func(*Store)
+
+// WithPrefix sets the prefix of the Redis keys holding the clients' buckets,
+// which is "throttle:" by default. Throttles enforcing different limits must
+// use different prefixes.
+func WithPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.prefix = prefix
+	}
+}
+
+// WithAtomicLevels makes the Store take the tokens of all limits of a request
+// using a single script, so that they are checked and taken atomically, and
+// concurrent requests never see some of the buckets taken from and others
+// not. Since the script accesses the keys of all the buckets, they must be
+// kept on the same node by a *redis.ClusterClient, e.g. using a prefix with a
+// hash tag like "{throttle}:". Without WithAtomicLevels, the tokens are taken
+// bucket by bucket, and put back if a bucket comes up short.
+func WithAtomicLevels() Option {
+	return func(s *Store) {
+		s.atomic = true
+	}
+}
+
+// NewStore creates a new Store using the given Redis client, e.g. a
+// *redis.Client or *redis.ClusterClient.
+func NewStore(client redis.Scripter, opts ...Option) *Store {
+	store := Store{
+		client: client,
+		prefix: "throttle:",
+	}
+	for _, opt := range opts {
+		opt(&store)
+	}
+	return &store
+}
+
+// TryConsume implements throttle.Store.
+func (s *Store) TryConsume(ctx context.Context, client string, limit throttle.Limit, n int, maxWait time.Duration) (throttle.Result, error) {
+	return s.run(ctx, consumeScript, client, limit, n, maxWait.Microseconds())
+}
+
+// TryConsumeAll implements throttle.BatchStore.
+func (s *Store) TryConsumeAll(ctx context.Context, buckets []throttle.KeyedLimit, n int, maxWait time.Duration) ([]throttle.Result, error) {
+	if !s.atomic {
+		return s.consumeEach(ctx, buckets, n, maxWait)
+	}
+	keys := make([]string, len(buckets))
+	args := []interface{}{n, maxWait.Microseconds()}
+	for i, b := range buckets {
+		keys[i] = s.prefix + b.Key
+		args = append(args, rate(b.Limit), b.Limit.Burst)
+	}
+	values, err := consumeAllScript.Run(ctx, s.client, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]throttle.Result, len(buckets))
+	for i := range results {
+		results[i] = result(values[4*i:])
+	}
+	return results, nil
+}
+
+// consumeEach takes the tokens from one bucket after the other, putting them
+// back if a bucket cannot provide its tokens in time.
+func (s *Store) consumeEach(ctx context.Context, buckets []throttle.KeyedLimit, n int, maxWait time.Duration) ([]throttle.Result, error) {
+	results := make([]throttle.Result, len(buckets))
+	for i, b := range buckets {
+		result, err := s.TryConsume(ctx, b.Key, b.Limit, n, maxWait)
+		if err == nil && result.OK {
+			results[i] = result
+			continue
+		}
+		for j, taken := range buckets[:i] {
+			s.Refund(ctx, taken.Key, taken.Limit, n)
+			results[j].OK = false
+		}
+		if err != nil {
+			return nil, err
+		}
+		results[i] = result
+		return results[:i+1], nil
+	}
+	return results, nil
+}
+
+// Refund implements throttle.Store.
+func (s *Store) Refund(ctx context.Context, client string, limit throttle.Limit, n int) error {
+	_, err := s.run(ctx, refundScript, client, limit, n)
+	return err
+}
+
+// Peek implements throttle.Store.
+func (s *Store) Peek(ctx context.Context, client string, limit throttle.Limit) (throttle.Result, error) {
+	return s.run(ctx, peekScript, client, limit, 1)
+}
+
+// run runs the script for n tokens of the client's bucket and converts its
+// result.
+func (s *Store) run(ctx context.Context, script *redis.Script, client string, limit throttle.Limit, n int, args ...interface{}) (throttle.Result, error) {
+	args = append([]interface{}{rate(limit), limit.Burst, n}, args...)
+	values, err := script.Run(ctx, s.client, []string{s.prefix + client}, args...).Int64Slice()
+	if err != nil {
+		return throttle.Result{}, err
+	}
+	return result(values), nil
+}
+
+// rate returns the limit's rate in microseconds, which is at least one.
+func rate(limit throttle.Limit) int64 {
+	return max(limit.Rate.Microseconds(), 1)
+}
+
+// result converts the four values of a bucket returned by a script.
+func result(values []int64) throttle.Result {
+	return throttle.Result{
+		OK:        values[0] == 1,
+		Wait:      time.Duration(values[1]) * time.Microsecond,
+		Remaining: int(values[2]),
+		Reset:     time.Duration(values[3]) * time.Microsecond,
+	}
+}
@@ -0,0 +1,125 @@
+package redisthrottle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/patrickbucher/throttle"
+	"github.com/redis/go-redis/v9"
+)
+
+func newStore(t *testing.T) *Store {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewStore(client)
+}
+
+func TestWait(t *testing.T) {
+	limiter := throttle.New(100*time.Millisecond, throttle.WithBurst(2), throttle.WithStore(newStore(t)))
+	for i := 0; i < 3; i++ {
+		if err := limiter.Wait("alice"); err != nil {
+			t.Errorf("request %d: expected no error, got %v", i, err)
+		}
+	}
+}
+
+func TestAllow(t *testing.T) {
+	limiter := throttle.New(1*time.Second, throttle.WithStore(newStore(t)))
+	expected := []bool{true, false}
+	for i, exp := range expected {
+		if allowed := limiter.Allow("alice"); allowed != exp {
+			t.Errorf("request %d: expected allowed to be %v, got %v", i, exp, allowed)
+		}
+	}
+}
+
+func TestRefund(t *testing.T) {
+	store := newStore(t)
+	limit := throttle.Limit{Rate: 1 * time.Second, Burst: 1}
+	ctx := context.Background()
+
+	result, err := store.TryConsume(ctx, "alice", limit, 1, 0)
+	if err != nil || !result.OK {
+		t.Fatalf("first token: expected to be taken, got %v, %v", result, err)
+	}
+	if err := store.Refund(ctx, "alice", limit, 1); err != nil {
+		t.Fatalf("refund: expected no error, got %v", err)
+	}
+	result, err = store.Peek(ctx, "alice", limit)
+	if err != nil || result.Remaining != 1 {
+		t.Errorf("peek: expected %d remaining, got %v, %v", 1, result, err)
+	}
+}
+
+func TestWeighted(t *testing.T) {
+	store := newStore(t)
+	limit := throttle.Limit{Rate: 1 * time.Second, Burst: 4}
+	ctx := context.Background()
+
+	result, err := store.TryConsume(ctx, "alice", limit, 3, 0)
+	if err != nil || !result.OK || result.Remaining != 1 {
+		t.Fatalf("three tokens: expected to be taken, got %v, %v", result, err)
+	}
+	result, err = store.TryConsume(ctx, "alice", limit, 3, 0)
+	if err != nil || result.OK || result.Wait <= 1*time.Second {
+		t.Errorf("three more tokens: expected to wait for two, got %v, %v", result, err)
+	}
+	if err := store.Refund(ctx, "alice", limit, 3); err != nil {
+		t.Fatalf("refund: expected no error, got %v", err)
+	}
+	result, err = store.Peek(ctx, "alice", limit)
+	if err != nil || result.Remaining != 4 {
+		t.Errorf("peek: expected %d remaining, got %v, %v", 4, result, err)
+	}
+}
+
+func TestSharedLimit(t *testing.T) {
+	store := newStore(t)
+	first := throttle.New(1*time.Second, throttle.WithMaxWait(10*time.Millisecond), throttle.WithStore(store))
+	second := throttle.New(1*time.Second, throttle.WithMaxWait(10*time.Millisecond), throttle.WithStore(store))
+
+	if err := first.Wait("alice"); err != nil {
+		t.Fatalf("first instance: expected no error, got %v", err)
+	}
+	err := second.Wait("alice")
+	if !errors.Is(err, throttle.ErrThrottled) {
+		t.Errorf("second instance: expected %v, got %v", throttle.ErrThrottled, err)
+	}
+	if err := second.Wait("bob"); err != nil {
+		t.Errorf("other client: expected no error, got %v", err)
+	}
+}
+
+func TestConsumeAll(t *testing.T) {
+	for _, atomic := range []bool{false, true} {
+		server := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		defer client.Close()
+		var opts []Option
+		if atomic {
+			opts = append(opts, WithAtomicLevels())
+		}
+		limiter := throttle.New(1*time.Minute, throttle.WithBurst(5), throttle.WithMaxWait(10*time.Millisecond),
+			throttle.WithTier("minute", 2, time.Minute), throttle.WithStore(NewStore(client, opts...)))
+
+		for i := 0; i < 2; i++ {
+			if err := limiter.Wait("alice"); err != nil {
+				t.Errorf("atomic %v, request %d: expected no error, got %v", atomic, i, err)
+			}
+		}
+		err := limiter.Wait("alice")
+		var throttledErr *throttle.ThrottledError
+		if !errors.As(err, &throttledErr) || throttledErr.Tier != "minute" {
+			t.Errorf("atomic %v: expected to be throttled by the tier, got %v", atomic, err)
+		}
+		// the client's own bucket is left untouched
+		result, err := NewStore(client).Peek(context.Background(), "alice", throttle.Limit{Rate: 1 * time.Minute, Burst: 5})
+		if err != nil || result.Remaining != 3 {
+			t.Errorf("atomic %v: expected 3 tokens left in the client's bucket, got %v, %v", atomic, result, err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package throttle
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registry manages the named Throttles of an application, so that they need
+// not be kept in global variables. Names are grouped into namespaces, which
+// are separated by slashes: the Throttle "login" of the namespace "api" is
+// named "api/login" in the Registry.
+//
+//	registry := throttle.NewRegistry(throttle.WithMaxWait(time.Second))
+//	defer registry.Close()
+//	registry.Add("login", 10*time.Second, throttle.WithBurst(3))
+//	registry.Namespace("api").Add("search", 100*time.Millisecond)
+//
+//	if err := registry.Get("login").Wait(client); err != nil {
+//		// the request has been throttled
+//	}
+//
+// A Registry is safe for concurrent use.
+type Registry struct {
+	root   *registry
+	prefix string
+}
+
+// registry holds the Throttles shared by a Registry and its namespaces.
+type registry struct {
+	opts      []Option
+	mutex     sync.RWMutex
+	throttles map[string]*Throttle
+}
+
+// NewRegistry creates an empty Registry, which applies the given options to
+// all the Throttles it creates, before the options given to Add.
+func NewRegistry(opts ...Option) *Registry {
+	return &Registry{root: &registry{opts: opts, throttles: make(map[string]*Throttle)}}
+}
+
+// Namespace returns the Registry of the given namespace, which shares the
+// Throttles and options of r.
+func (r *Registry) Namespace(name string) *Registry {
+	return &Registry{root: r.root, prefix: r.prefix + name + "/"}
+}
+
+// Add creates a Throttle of the given name using New, or returns an error if
+// there is one of that name already.
+func (r *Registry) Add(name string, requestRate time.Duration, opts ...Option) (*Throttle, error) {
+	opts = append(slices.Clip(r.root.opts), opts...)
+	t := New(requestRate, opts...)
+	if err := r.Register(name, t); err != nil {
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// Register adds a Throttle created otherwise, e.g. using NewSlidingWindow,
+// under the given name, or returns an error if there is one of that name
+// already. The Throttle is closed together with the Registry.
+func (r *Registry) Register(name string, t *Throttle) error {
+	r.root.mutex.Lock()
+	defer r.root.mutex.Unlock()
+	if _, ok := r.root.throttles[r.prefix+name]; ok {
+		return fmt.Errorf("throttle %q already registered", r.prefix+name)
+	}
+	r.root.throttles[r.prefix+name] = t
+	return nil
+}
+
+// Get returns the Throttle of the given name, or nil if there is none.
+func (r *Registry) Get(name string) *Throttle {
+	r.root.mutex.RLock()
+	defer r.root.mutex.RUnlock()
+	return r.root.throttles[r.prefix+name]
+}
+
+// Remove closes the Throttle of the given name and removes it.
+func (r *Registry) Remove(name string) {
+	r.root.mutex.Lock()
+	t, ok := r.root.throttles[r.prefix+name]
+	delete(r.root.throttles, r.prefix+name)
+	r.root.mutex.Unlock()
+	if ok {
+		t.Close()
+	}
+}
+
+// Names returns the sorted names of all Throttles of the namespace and the
+// namespaces within it, relative to the namespace.
+func (r *Registry) Names() []string {
+	r.root.mutex.RLock()
+	defer r.root.mutex.RUnlock()
+	var names []string
+	for name := range r.root.throttles {
+		if rest, ok := strings.CutPrefix(name, r.prefix); ok {
+			names = append(names, rest)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Metrics returns the Metrics of all Throttles of the namespace and the
+// namespaces within it by their names relative to the namespace.
+func (r *Registry) Metrics() map[string]Metrics {
+	metrics := make(map[string]Metrics)
+	for _, name := range r.Names() {
+		if t := r.Get(name); t != nil {
+			metrics[name] = t.Metrics()
+		}
+	}
+	return metrics
+}
+
+// Close closes and removes all Throttles of the namespace and the namespaces
+// within it.
+func (r *Registry) Close() error {
+	r.root.mutex.Lock()
+	var closing []*Throttle
+	for name, t := range r.root.throttles {
+		if strings.HasPrefix(name, r.prefix) {
+			closing = append(closing, t)
+			delete(r.root.throttles, name)
+		}
+	}
+	r.root.mutex.Unlock()
+	for _, t := range closing {
+		t.Close()
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package throttle
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry(WithMaxWait(time.Millisecond))
+	defer registry.Close()
+	login, err := registry.Add("login", time.Hour, WithBurst(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := registry.Namespace("api")
+	search, err := api.Add("search", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.Add("search", time.Hour); err == nil {
+		t.Error("second Throttle of the same name added")
+	}
+
+	if got := registry.Get("login"); got != login {
+		t.Errorf("Get(login) returned %p, want %p", got, login)
+	}
+	if got := registry.Get("api/search"); got != search {
+		t.Errorf("Get(api/search) returned %p, want %p", got, search)
+	}
+	if got := api.Get("search"); got != search {
+		t.Errorf("api Get(search) returned %p, want %p", got, search)
+	}
+	if got := registry.Get("search"); got != nil {
+		t.Errorf("Get(search) returned %p outside of its namespace", got)
+	}
+	if names := fmt.Sprint(registry.Names()); names != "[api/search login]" {
+		t.Errorf("names %s", names)
+	}
+	if names := fmt.Sprint(api.Names()); names != "[search]" {
+		t.Errorf("api names %s", names)
+	}
+
+	// the shared options apply, the Throttle's own options come later
+	login.Allow("alice")
+	login.Allow("alice")
+	if login.Allow("alice") {
+		t.Error("third request allowed with a burst of 2")
+	}
+	search.Allow("alice")
+	if metrics := registry.Metrics(); metrics["login"].Allowed != 2 || metrics["login"].Rejected != 1 || metrics["api/search"].Allowed != 1 {
+		t.Errorf("metrics %+v", metrics)
+	}
+
+	api.Close()
+	if err := search.Wait("bob"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Throttle of closed namespace returned %v, want ErrClosed", err)
+	}
+	if registry.Get("api/search") != nil || registry.Get("login") != login {
+		t.Error("closing the namespace removed the wrong Throttles")
+	}
+	registry.Remove("login")
+	if len(registry.Names()) != 0 {
+		t.Errorf("names %v after removing all Throttles", registry.Names())
+	}
+}
@@ -0,0 +1,128 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Reservation is a token reserved for a client by Reserve. Instead of waiting
+// inside the Throttle, the caller is expected to wait for Delay before making
+// its request, or to Cancel the reservation if it is not going to make it.
+type Reservation struct {
+	throttle  *Throttle
+	client    string
+	ok        bool
+	err       error
+	timeToAct time.Time
+	rate      time.Duration
+
+	mutex     sync.Mutex
+	cancelled bool
+}
+
+// Reserve reserves a token for the client, provided that one becomes available
+// within the Throttle's maximum waiting time, and returns right away. Unlike
+// Wait, Reserve does not block; the caller has to wait for the Reservation's
+// Delay itself. The exception is WithFairQueueing while requests are queued
+// for global tokens, for a reservation cannot take its global token before the
+// requests ahead of it: Reserve then waits in the queue like Wait does. The
+// request counts as allowed or rejected when it is reserved.
+func (t *Throttle) Reserve(client string) *Reservation {
+	client = t.key(client)
+	r := Reservation{throttle: t, client: client}
+	if t.closed() {
+		r.err = ErrClosed
+		return &r
+	}
+	wait, err := t.consume(context.Background(), client, 1, t.timeout(client, 1))
+	now := t.clock.Now()
+	var throttledErr *ThrottledError
+	switch {
+	case errors.As(err, &throttledErr):
+		t.rejected(client, now)
+		r.err = err
+		r.timeToAct = now.Add(throttledErr.RetryAfter)
+		if t.shadows(client) {
+			r.ok, r.err, r.timeToAct = true, nil, now
+		}
+	case err != nil:
+		r.err = err
+	default:
+		t.allowed(client, now, wait)
+		r.ok = true
+		r.timeToAct = now.Add(wait)
+		r.rate = t.rate(client)
+		if t.shadows(client) {
+			// the caller does not wait, so there is nothing to cancel
+			r.timeToAct = now
+		}
+	}
+	return &r
+}
+
+// OK reports whether a token has been reserved.
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// Err returns why no token has been reserved: a *ThrottledError, ErrClosed, or
+// the error of the Store. It returns nil if the Reservation is OK.
+func (r *Reservation) Err() error {
+	return r.err
+}
+
+// Delay returns how long the caller has to wait until it may make its request.
+// If no token has been reserved, Delay returns the estimated time until the
+// next token is spawned.
+func (r *Reservation) Delay() time.Duration {
+	if !r.ok {
+		if err, ok := r.err.(*ThrottledError); ok {
+			return err.RetryAfter
+		}
+		return 0
+	}
+	if delay := r.timeToAct.Sub(r.throttle.clock.Now()); delay > 0 {
+		return delay
+	}
+	return 0
+}
+
+// ETA returns the estimated time at which the caller may make its request,
+// e.g. to tell an interactive user how long they have to wait. If no token has
+// been reserved, ETA returns the estimated time at which the next token is
+// spawned, or the zero time if there is no telling.
+func (r *Reservation) ETA() time.Time {
+	return r.timeToAct
+}
+
+// Position returns the estimated position of the Reservation among the
+// client's requests waiting for their tokens: 1 if its token is the next to
+// be spawned, 2 if another request's token is spawned before it, and so on.
+// It returns 0 once the caller may make its request, and if no token has been
+// reserved. The position is estimated from the client's request rate, and
+// goes down as the tokens ahead are spawned, so it can be polled.
+func (r *Reservation) Position() int {
+	delay := r.Delay()
+	if !r.ok || delay <= 0 || r.rate <= 0 {
+		return 0
+	}
+	return int((delay + r.rate - 1) / r.rate)
+}
+
+// Cancel returns the reserved token to the Throttle, so that other requests of
+// the client can use it. Reservations which are not OK, whose Delay has passed,
+// or which have been cancelled before are not affected.
+func (r *Reservation) Cancel() {
+	if !r.ok {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.cancelled || !r.throttle.clock.Now().Before(r.timeToAct) {
+		return
+	}
+	r.cancelled = true
+	r.throttle.refund(r.client, 1)
+}
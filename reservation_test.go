@@ -0,0 +1,101 @@
+package throttle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReserve(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithClock(clock))
+
+	r := throttle.Reserve("alice")
+	if !r.OK() || r.Delay() != 0 {
+		t.Errorf("first reservation: expected no delay, got %v (%v)", r.Delay(), r.Err())
+	}
+	r = throttle.Reserve("alice")
+	if !r.OK() || r.Delay() != 100*time.Millisecond {
+		t.Errorf("second reservation: expected delay of %v, got %v (%v)", 100*time.Millisecond, r.Delay(), r.Err())
+	}
+	clock.Advance(40 * time.Millisecond)
+	if r.Delay() != 60*time.Millisecond {
+		t.Errorf("later: expected delay of %v, got %v", 60*time.Millisecond, r.Delay())
+	}
+
+	// the next token is already reserved
+	rejected := throttle.Reserve("alice")
+	if rejected.OK() || !errors.Is(rejected.Err(), ErrThrottled) {
+		t.Errorf("third reservation: expected %v, got %v", ErrThrottled, rejected.Err())
+	}
+	if rejected.Delay() != 160*time.Millisecond {
+		t.Errorf("third reservation: expected retry after %v, got %v", 160*time.Millisecond, rejected.Delay())
+	}
+}
+
+func TestReservationCancel(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithClock(clock))
+	throttle.Reserve("alice")
+
+	r := throttle.Reserve("alice")
+	r.Cancel()
+	r.Cancel()
+	if !throttle.Reserve("alice").OK() {
+		t.Errorf("after cancel: expected the token to be available again")
+	}
+
+	// tokens cannot be returned once they are due
+	clock.Advance(100 * time.Millisecond)
+	r = throttle.Reserve("alice")
+	clock.Advance(100 * time.Millisecond)
+	r.Cancel()
+	if throttle.Allow("alice") {
+		t.Errorf("after late cancel: expected no token to be available")
+	}
+}
+
+func TestReserveClosed(t *testing.T) {
+	throttle := New(100 * time.Millisecond)
+	throttle.Close()
+	if r := throttle.Reserve("alice"); r.OK() || r.Err() != ErrClosed {
+		t.Errorf("expected %v, got %v", ErrClosed, r.Err())
+	}
+}
+
+func TestReservationPosition(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithBurst(2), WithMaxWait(1*time.Second), WithClock(clock))
+	start := clock.Now()
+
+	var reservations []*Reservation
+	for i := 0; i < 5; i++ {
+		reservations = append(reservations, throttle.Reserve("alice"))
+	}
+	expected := []int{0, 0, 1, 2, 3}
+	for i, r := range reservations {
+		if r.Position() != expected[i] {
+			t.Errorf("reservation %d: expected position %d, got %d", i, expected[i], r.Position())
+		}
+		eta := start.Add(time.Duration(expected[i]) * 100 * time.Millisecond)
+		if !r.ETA().Equal(eta) {
+			t.Errorf("reservation %d: expected ETA %v, got %v", i, eta.Sub(start), r.ETA().Sub(start))
+		}
+	}
+
+	clock.Advance(150 * time.Millisecond)
+	expected = []int{0, 0, 0, 1, 2}
+	for i, r := range reservations {
+		if r.Position() != expected[i] {
+			t.Errorf("later, reservation %d: expected position %d, got %d", i, expected[i], r.Position())
+		}
+	}
+
+	rejected := New(1*time.Second, WithMaxWait(1*time.Millisecond), WithClock(clock))
+	rejected.Allow("bob")
+	r := rejected.Reserve("bob")
+	if r.OK() || r.Position() != 0 || !r.ETA().Equal(clock.Now().Add(r.Delay())) {
+		t.Errorf("rejected reservation: expected position 0 and ETA in %v, got %d and %v",
+			r.Delay(), r.Position(), r.ETA().Sub(clock.Now()))
+	}
+}
@@ -0,0 +1,67 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// maxBackoff caps the exponential backoff of DoWithRetry.
+const maxBackoff = 1 << 10
+
+// DoWithRetry waits for a token of the client and calls fn, making up to the
+// given number of attempts as long as either fails with an error matching
+// ErrThrottled, e.g. a *ThrottledError of a downstream Transport. Before
+// every retry, DoWithRetry waits for the RetryAfter of the *ThrottledError,
+// if any, plus a random backoff of up to the client's request rate, doubled
+// with every attempt, so that throttled callers do not retry in lockstep. The
+// error of the last attempt is returned, or the context's error if ctx is
+// done while waiting to retry. Other errors are returned right away.
+func (t *Throttle) DoWithRetry(ctx context.Context, client string, attempts int, fn func() error) error {
+	client = t.key(client)
+	var err error
+	for attempt := 0; attempt < max(attempts, 1); attempt++ {
+		if attempt > 0 {
+			after, timer := t.after(t.backoff(client, attempt, err))
+			select {
+			case <-after:
+				stopTimer(timer)
+			case <-ctx.Done():
+				stopTimer(timer)
+				return ctx.Err()
+			case <-t.done:
+				stopTimer(timer)
+				return ErrClosed
+			}
+		}
+		if err = t.waitN(ctx, client, 1); err == nil {
+			err = fn()
+		}
+		if !errors.Is(err, ErrThrottled) {
+			return err
+		}
+	}
+	return err
+}
+
+// backoff returns the time to wait before the given attempt to retry the
+// request of the client that failed with err.
+func (t *Throttle) backoff(client string, attempt int, err error) time.Duration {
+	var wait time.Duration
+	var throttledErr *ThrottledError
+	if errors.As(err, &throttledErr) {
+		wait = throttledErr.RetryAfter
+	}
+	factor := int64(1)
+	for i := 1; i < attempt && factor < maxBackoff; i++ {
+		factor *= 2
+	}
+	// the backoff of rates so slow that it would overflow is capped
+	jitter := int64(math.MaxInt64 - 1)
+	if rate := max(int64(t.rate(client)), 0); rate < jitter/factor {
+		jitter = rate * factor
+	}
+	return wait + time.Duration(rand.Int63n(jitter+1))
+}
@@ -0,0 +1,90 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDoWithRetry(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Second, WithMaxWait(10*time.Millisecond), WithClock(clock))
+	calls := 0
+	fn := func() error {
+		if calls++; calls == 1 {
+			return &ThrottledError{Client: "downstream", Rate: 1 * time.Second, RetryAfter: 2 * time.Second}
+		}
+		return nil
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- throttle.DoWithRetry(context.Background(), "alice", 3, fn)
+	}()
+	for clock.Waiters() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	// RetryAfter plus a backoff of up to one rate
+	clock.Advance(3 * time.Second)
+	if err := <-done; err != nil {
+		t.Errorf("expected the retry to succeed, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+}
+
+func TestDoWithRetryGivesUp(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Second, WithMaxWait(10*time.Millisecond), WithClock(clock))
+	unavailable := errors.New("unavailable")
+	calls := 0
+	err := throttle.DoWithRetry(context.Background(), "alice", 3, func() error {
+		calls++
+		return unavailable
+	})
+	if !errors.Is(err, unavailable) || calls != 1 {
+		t.Errorf("other errors: expected to be returned right away, got %v after %d calls", err, calls)
+	}
+
+	clock.Advance(1 * time.Second)
+	calls = 0
+	done := make(chan error)
+	go func() {
+		done <- throttle.DoWithRetry(context.Background(), "alice", 2, func() error {
+			calls++
+			return &ThrottledError{Client: "downstream", Rate: 1 * time.Second}
+		})
+	}()
+	for clock.Waiters() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(1 * time.Second)
+	if err := <-done; !errors.Is(err, ErrThrottled) || calls != 2 {
+		t.Errorf("attempts used up: expected %v after 2 calls, got %v after %d calls", ErrThrottled, err, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		done <- throttle.DoWithRetry(ctx, "alice", 2, func() error { return nil })
+	}()
+	for clock.Waiters() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled: expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestBackoffOverflow(t *testing.T) {
+	throttle := New(math.MaxInt64)
+	defer throttle.Close()
+	for _, attempt := range []int{1, 2, 11, 100} {
+		if wait := throttle.backoff("alice", attempt, nil); wait < 0 {
+			t.Errorf("attempt %d: expected a non-negative backoff, got %v", attempt, wait)
+		}
+	}
+}
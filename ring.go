@@ -0,0 +1,127 @@
+package throttle
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// defaultReplicas is the number of points a shard has on a Ring by default.
+const defaultReplicas = 128
+
+// Ring maps clients onto shards using consistent hashing, so that a large
+// number of clients can be partitioned across Throttles, e.g. in different
+// processes: every client is always handled by the same shard, and adding or
+// removing a shard only moves the clients of about one shard's share to
+// other shards. The shards are named; shards of the same names added to the
+// Rings of different processes map the clients alike.
+//
+//	ring := throttle.NewRing[string](0)
+//	ring.Add("a", "http://10.0.0.1:8080")
+//	ring.Add("b", "http://10.0.0.2:8080")
+//	_, peer, _ := ring.Get(client)
+//
+// A Ring is safe for concurrent use.
+type Ring[S any] struct {
+	replicas int
+	mutex    sync.RWMutex
+	points   []ringPoint
+	shards   map[string]S
+}
+
+// ringPoint is a point on the Ring owned by the named shard.
+type ringPoint struct {
+	hash uint64
+	name string
+}
+
+// NewRing creates an empty Ring, putting every shard onto the given number of
+// points on it; the more points, the more even the clients are distributed.
+// If replicas is less than 1, every shard gets 128 points.
+func NewRing[S any](replicas int) *Ring[S] {
+	if replicas < 1 {
+		replicas = defaultReplicas
+	}
+	return &Ring[S]{replicas: replicas, shards: make(map[string]S)}
+}
+
+// Add adds the named shard to the Ring, taking over a share of the clients
+// of the other shards, or replaces the shard of that name.
+func (r *Ring[S]) Add(name string, shard S) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.shards[name]; !ok {
+		for i := 0; i < r.replicas; i++ {
+			r.points = append(r.points, ringPoint{hash: ringHash(strconv.Itoa(i) + "\x00" + name), name: name})
+		}
+		slices.SortFunc(r.points, func(a, b ringPoint) int {
+			return cmp.Compare(a.hash, b.hash)
+		})
+	}
+	r.shards[name] = shard
+}
+
+// Remove removes the named shard from the Ring, handing its clients over to
+// the other shards.
+func (r *Ring[S]) Remove(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.shards[name]; !ok {
+		return
+	}
+	delete(r.shards, name)
+	r.points = slices.DeleteFunc(r.points, func(p ringPoint) bool {
+		return p.name == name
+	})
+}
+
+// Get returns the name of the shard handling the client, and the shard. It
+// returns false if the Ring has no shards.
+func (r *Ring[S]) Get(client string) (string, S, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if len(r.points) == 0 {
+		var zero S
+		return "", zero, false
+	}
+	hash := ringHash(client)
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= hash
+	})
+	if i == len(r.points) {
+		// past the last point, the ring wraps around
+		i = 0
+	}
+	name := r.points[i].name
+	return name, r.shards[name], true
+}
+
+// Names returns the names of all shards.
+func (r *Ring[S]) Names() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	names := make([]string, 0, len(r.shards))
+	for name := range r.shards {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ringHash hashes a key onto the Ring: FNV-1a spread by the finalizer of
+// SplitMix64, which is stable across processes unlike hash/maphash.
+func ringHash(key string) uint64 {
+	hash := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint64(key[i])
+		hash *= 1099511628211
+	}
+	hash ^= hash >> 30
+	hash *= 0xbf58476d1ce4e5b9
+	hash ^= hash >> 27
+	hash *= 0x94d049bb133111eb
+	hash ^= hash >> 31
+	return hash
+}
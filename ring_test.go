@@ -0,0 +1,67 @@
+package throttle
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRingEmpty(t *testing.T) {
+	ring := NewRing[*Throttle](0)
+	if _, shard, ok := ring.Get("alice"); ok || shard != nil {
+		t.Errorf("empty ring returned shard %v", shard)
+	}
+}
+
+func TestRingRebalance(t *testing.T) {
+	const clients = 10000
+	ring := NewRing[int](0)
+	for i := 0; i < 4; i++ {
+		ring.Add(fmt.Sprintf("shard-%d", i), i)
+	}
+	before := make(map[string]string, clients)
+	counts := make(map[string]int)
+	for i := 0; i < clients; i++ {
+		client := fmt.Sprintf("client-%d", i)
+		name, shard, ok := ring.Get(client)
+		if !ok || name != fmt.Sprintf("shard-%d", shard) {
+			t.Fatalf("client %s mapped to shard %q (%d), ok %v", client, name, shard, ok)
+		}
+		before[client] = name
+		counts[name]++
+	}
+	for name, n := range counts {
+		if n < clients/4/2 || n > clients/4*2 {
+			t.Errorf("shard %s got %d of %d clients", name, n, clients)
+		}
+	}
+
+	ring.Add("shard-4", 4)
+	moved := 0
+	for client, old := range before {
+		name, _, _ := ring.Get(client)
+		if name != old {
+			if name != "shard-4" {
+				t.Fatalf("client %s moved from %s to %s, not the added shard", client, old, name)
+			}
+			moved++
+		}
+	}
+	if moved < clients/5/2 || moved > clients/5*2 {
+		t.Errorf("%d of %d clients moved to the added shard", moved, clients)
+	}
+
+	ring.Remove("shard-4")
+	ring.Remove("shard-0")
+	for client, old := range before {
+		name, _, _ := ring.Get(client)
+		if old != "shard-0" && name != old {
+			t.Errorf("client %s moved from %s to %s, though its shard was kept", client, old, name)
+		}
+		if name == "shard-0" {
+			t.Errorf("client %s mapped to removed shard", client)
+		}
+	}
+	if names := fmt.Sprint(ring.Names()); names != "[shard-1 shard-2 shard-3]" {
+		t.Errorf("names %s", names)
+	}
+}
@@ -0,0 +1,87 @@
+package throttle
+
+import "net/http"
+
+// Routes applies different Throttles to the requests matching different
+// patterns, which are written like the patterns of http.ServeMux, e.g.
+// "/login" or "GET /search/{query}":
+//
+//	routes := throttle.NewRoutes(nil)
+//	routes.Handle("POST /login", throttle.New(1*time.Minute))
+//	routes.Handle("/search/", throttle.New(100*time.Millisecond, throttle.WithBurst(10)))
+//	http.ListenAndServe(":8080", routes.Middleware(mux))
+//
+// Like with http.ServeMux, the most specific pattern matching a request wins.
+// Requests matching no pattern are not throttled.
+type Routes struct {
+	mux    *http.ServeMux
+	keyFn  func(*http.Request) string
+	routes []*route
+}
+
+// route is the handler registered for a pattern of Routes, which refers to
+// the pattern's Throttle.
+type route struct {
+	throttle *Throttle
+}
+
+func (*route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// never called, for routes are only looked up
+}
+
+// NewRoutes creates an empty set of Routes deriving the clients from requests
+// using keyFn, which works like the keyFn of Middleware.
+func NewRoutes(keyFn func(*http.Request) string) *Routes {
+	return &Routes{mux: http.NewServeMux(), keyFn: keyFn}
+}
+
+// Handle throttles the requests matching the pattern using the given
+// Throttle. Like http.ServeMux.Handle, it panics if the pattern is invalid or
+// conflicts with a pattern already registered. Handle must not be called
+// after Middleware.
+func (rs *Routes) Handle(pattern string, t *Throttle) {
+	r := &route{throttle: t}
+	rs.mux.Handle(pattern, r)
+	rs.routes = append(rs.routes, r)
+}
+
+// HandleMethods throttles the requests matching the pattern, which must not
+// contain a method, using the Throttle of their method, e.g. to give
+// expensive writes a stricter limit than reads:
+//
+//	routes.HandleMethods("/items/", map[string]*throttle.Throttle{
+//		http.MethodGet:    reads,
+//		http.MethodPost:   writes,
+//		http.MethodDelete: writes,
+//	})
+//
+// The Throttle for GET also applies to HEAD, unless there is one for HEAD,
+// and the Throttle for "" applies to all methods without their own. A
+// Throttle used for multiple methods keeps a single bucket per client for all
+// of them.
+func (rs *Routes) HandleMethods(pattern string, throttles map[string]*Throttle) {
+	for method, t := range throttles {
+		if method == "" {
+			rs.Handle(pattern, t)
+		} else {
+			rs.Handle(method+" "+pattern, t)
+		}
+	}
+}
+
+// Middleware returns a handler passing on requests to next through the
+// Middleware of the Throttle of the pattern that matches them.
+func (rs *Routes) Middleware(next http.Handler) http.Handler {
+	handlers := make(map[*route]http.Handler, len(rs.routes))
+	for _, r := range rs.routes {
+		handlers[r] = r.throttle.Middleware(next, rs.keyFn)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, _ := rs.mux.Handler(r)
+		if route, ok := h.(*route); ok {
+			handlers[route].ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,69 @@
+package throttle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRoutes(t *testing.T) {
+	routes := NewRoutes(nil)
+	routes.Handle("/login", New(1*time.Minute, WithMaxWait(1*time.Millisecond)))
+	routes.Handle("GET /search/", New(1*time.Minute, WithBurst(2), WithMaxWait(1*time.Millisecond)))
+	handler := routes.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		method   string
+		path     string
+		expected []int
+	}{
+		{http.MethodPost, "/login", []int{http.StatusOK, http.StatusTooManyRequests}},
+		{http.MethodGet, "/search/foo", []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}},
+		{http.MethodPost, "/search/foo", []int{http.StatusOK, http.StatusOK, http.StatusOK}},
+		{http.MethodGet, "/other", []int{http.StatusOK, http.StatusOK, http.StatusOK}},
+	}
+	for _, test := range tests {
+		for i, status := range test.expected {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(test.method, test.path, nil))
+			if rec.Code != status {
+				t.Errorf("%s %s, request %d: expected status %d, got %d", test.method, test.path, i, status, rec.Code)
+			}
+		}
+	}
+}
+
+func TestRoutesHandleMethods(t *testing.T) {
+	routes := NewRoutes(nil)
+	writes := New(1*time.Minute, WithMaxWait(1*time.Millisecond))
+	routes.HandleMethods("/items/", map[string]*Throttle{
+		http.MethodGet:    New(1*time.Minute, WithBurst(3), WithMaxWait(1*time.Millisecond)),
+		http.MethodPost:   writes,
+		http.MethodDelete: writes,
+		"":                New(1*time.Minute, WithBurst(2), WithMaxWait(1*time.Millisecond)),
+	})
+	handler := routes.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		method   string
+		expected int
+	}{
+		{http.MethodGet, http.StatusOK},
+		{http.MethodHead, http.StatusOK},
+		{http.MethodGet, http.StatusOK},
+		{http.MethodGet, http.StatusTooManyRequests},
+		{http.MethodPost, http.StatusOK},
+		{http.MethodDelete, http.StatusTooManyRequests},
+		{http.MethodPut, http.StatusOK},
+		{http.MethodPatch, http.StatusOK},
+		{http.MethodPut, http.StatusTooManyRequests},
+	}
+	for i, test := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(test.method, "/items/1", nil))
+		if rec.Code != test.expected {
+			t.Errorf("request %d (%s): expected status %d, got %d", i, test.method, test.expected, rec.Code)
+		}
+	}
+}
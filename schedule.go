@@ -0,0 +1,98 @@
+package throttle
+
+import "time"
+
+// ScheduledRate is a request rate in effect at certain times of the week, see
+// WithSchedule.
+type ScheduledRate struct {
+	// Weekdays are the days the rate is in effect on. If empty, the rate is
+	// in effect every day.
+	Weekdays []time.Weekday
+
+	// From and To are the wall-clock times of day, e.g. 8*time.Hour for 8:00,
+	// between which the rate is in effect, including From and excluding To.
+	// If To is before From, the rate is in effect over midnight, until To of
+	// the next day; the Weekdays refer to the day it starts on.
+	From, To time.Duration
+
+	// Rate is the request rate in effect.
+	Rate time.Duration
+}
+
+// schedule holds the rates set by WithSchedule.
+type schedule struct {
+	location *time.Location
+	rates    []ScheduledRate
+}
+
+// WithSchedule changes the Throttle's request rate by the time of day and
+// week, e.g. to be stricter during business hours and relaxed during a batch
+// window overnight. The first of the rates in effect at the time of a request
+// applies; outside of all of them, the rate given to New or SetRate applies.
+// Times are in the given location, or in UTC if it is nil. The rates take
+// effect when they are due, without any background work; rates set for single
+// clients using SetClientRate or WithTierFunc take precedence.
+//
+//	throttle.WithSchedule(zurich,
+//		throttle.ScheduledRate{
+//			Weekdays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+//			From:     8 * time.Hour,
+//			To:       18 * time.Hour,
+//			Rate:     1 * time.Second,
+//		},
+//		throttle.ScheduledRate{From: 22 * time.Hour, To: 6 * time.Hour, Rate: 10 * time.Millisecond},
+//	)
+func WithSchedule(location *time.Location, rates ...ScheduledRate) Option {
+	return func(t *Throttle) {
+		if location == nil {
+			location = time.UTC
+		}
+		t.schedule = &schedule{location: location, rates: rates}
+	}
+}
+
+// rate returns the rate scheduled at the given time, and whether there is one.
+func (s *schedule) rate(now time.Time) (time.Duration, bool) {
+	now = now.In(s.location)
+	// the time shown by the clock, which on days daylight saving time
+	// starts or ends is not the time elapsed since midnight
+	timeOfDay := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute +
+		time.Duration(now.Second())*time.Second + time.Duration(now.Nanosecond())
+	today, yesterday := now.Weekday(), (now.Weekday()+6)%7
+	for _, r := range s.rates {
+		switch {
+		case r.From <= r.To:
+			if timeOfDay >= r.From && timeOfDay < r.To && r.on(today) {
+				return r.Rate, true
+			}
+		case timeOfDay >= r.From && r.on(today), timeOfDay < r.To && r.on(yesterday):
+			// over midnight
+			return r.Rate, true
+		}
+	}
+	return 0, false
+}
+
+// on reports whether the rate is in effect on the given day.
+func (r ScheduledRate) on(day time.Weekday) bool {
+	if len(r.Weekdays) == 0 {
+		return true
+	}
+	for _, d := range r.Weekdays {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduled returns the rate scheduled right now, or base if there is none.
+func (t *Throttle) scheduled(base time.Duration) time.Duration {
+	if t.schedule == nil {
+		return base
+	}
+	if rate, ok := t.schedule.rate(t.clock.Now()); ok {
+		return rate
+	}
+	return base
+}
@@ -0,0 +1,74 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	clock := newFakeClock()
+	// Monday, 2026-10-12, at midnight
+	clock.Advance(time.Date(2026, time.October, 12, 0, 0, 0, 0, time.UTC).Sub(clock.Now()))
+	weekdays := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	throttle := New(100*time.Millisecond, WithClock(clock), WithSchedule(nil,
+		ScheduledRate{Weekdays: weekdays, From: 8 * time.Hour, To: 18 * time.Hour, Rate: 1 * time.Second},
+		ScheduledRate{Weekdays: []time.Weekday{time.Friday}, From: 22 * time.Hour, To: 6 * time.Hour, Rate: 10 * time.Millisecond},
+	))
+	defer throttle.Close()
+	throttle.SetClientRate("vip", time.Millisecond)
+
+	testCases := []struct {
+		since time.Duration
+		rate  time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{8 * time.Hour, 1 * time.Second},
+		{18*time.Hour - time.Nanosecond, 1 * time.Second},
+		{18 * time.Hour, 100 * time.Millisecond},
+		{4*24*time.Hour + 23*time.Hour, 10 * time.Millisecond}, // Friday night
+		{5*24*time.Hour + 5*time.Hour, 10 * time.Millisecond},  // Saturday morning
+		{5*24*time.Hour + 6*time.Hour, 100 * time.Millisecond},
+		{5*24*time.Hour + 9*time.Hour, 100 * time.Millisecond}, // weekend
+		{6*24*time.Hour + 23*time.Hour, 100 * time.Millisecond},
+	}
+	start := clock.Now()
+	for _, testCase := range testCases {
+		clock.Advance(start.Add(testCase.since).Sub(clock.Now()))
+		if got := throttle.Rate(); got != testCase.rate {
+			t.Errorf("%v after Monday midnight: expected rate %v, got %v", testCase.since, testCase.rate, got)
+		}
+		if got := throttle.rate("alice"); got != testCase.rate {
+			t.Errorf("%v after Monday midnight: expected client rate %v, got %v", testCase.since, testCase.rate, got)
+		}
+		if got := throttle.rate("vip"); got != time.Millisecond {
+			t.Errorf("%v after Monday midnight: expected own rate of client, got %v", testCase.since, got)
+		}
+	}
+}
+
+func TestScheduleDST(t *testing.T) {
+	zurich, err := time.LoadLocation("Europe/Zurich")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	s := &schedule{location: zurich, rates: []ScheduledRate{{From: 8 * time.Hour, To: 18 * time.Hour, Rate: 1 * time.Second}}}
+
+	// the clocks are put forward from 2:00 to 3:00 on 2026-03-29, and back
+	// from 3:00 to 2:00 on 2026-10-25
+	for _, day := range []time.Time{
+		time.Date(2026, time.March, 29, 0, 0, 0, 0, zurich),
+		time.Date(2026, time.October, 25, 0, 0, 0, 0, zurich),
+	} {
+		y, m, d := day.Date()
+		testCases := []struct {
+			hour int
+			ok   bool
+		}{{7, false}, {8, true}, {17, true}, {18, false}}
+		for _, tc := range testCases {
+			now := time.Date(y, m, d, tc.hour, 30, 0, 0, zurich)
+			if _, ok := s.rate(now); ok != tc.ok {
+				t.Errorf("%v: expected the rate to be in effect %v, got %v", now, tc.ok, ok)
+			}
+		}
+	}
+}
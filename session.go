@@ -0,0 +1,99 @@
+package throttle
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// sessionPrefix starts the keys of Sessions, which cannot collide with the
+// keys of clients.
+const sessionPrefix = "\x00session\x00"
+
+// sessions numbers the Sessions of all Throttles.
+var sessions atomic.Uint64
+
+// Session throttles the messages of a single short-lived connection, e.g. a
+// WebSocket of a chat or game server, using a key of its own, which is
+// forgotten once the Session is closed:
+//
+//	session := t.NewSession()
+//	defer session.Close()
+//	for {
+//		msg, err := conn.Read(ctx)
+//		if err != nil {
+//			return err
+//		}
+//		if err := session.WaitContext(ctx); err != nil {
+//			return conn.Close(websocket.StatusPolicyViolation, "too many messages")
+//		}
+//		handle(msg)
+//	}
+//
+// A Session is safe for concurrent use.
+type Session struct {
+	throttle *Throttle
+	key      string
+	once     sync.Once
+}
+
+// NewSession starts a Session with a full bucket.
+func (t *Throttle) NewSession() *Session {
+	return &Session{throttle: t, key: sessionPrefix + strconv.FormatUint(sessions.Add(1), 10)}
+}
+
+// Key returns the Session's key, under which its stats are kept.
+func (s *Session) Key() string {
+	return s.key
+}
+
+// Wait waits for a token of the Session, like Throttle.Wait does for a client.
+func (s *Session) Wait() error {
+	return s.WaitContext(context.Background())
+}
+
+// WaitContext works like Wait, but gives up as soon as ctx is cancelled or its
+// deadline expires.
+func (s *Session) WaitContext(ctx context.Context) error {
+	return s.throttle.waitN(ctx, s.key, 1)
+}
+
+// Allow reports whether a token of the Session is available right now, like
+// Throttle.Allow does for a client.
+func (s *Session) Allow() bool {
+	return s.throttle.allowN(s.key, 1)
+}
+
+// Close makes the Throttle forget about the Session's bucket and stats.
+// Afterwards, the Session must not be used anymore.
+func (s *Session) Close() error {
+	s.once.Do(func() {
+		if s.throttle.lru != nil {
+			s.throttle.lru.remove(s.key)
+		}
+		s.throttle.forget(s.key)
+	})
+	return nil
+}
+
+// ServeMessages reads messages using read and passes them to handle one by
+// one, waiting for a token of a new Session before handling a message. It
+// returns the first error of read or handle, or the Throttle's error for the
+// first message that has been throttled, and closes the Session.
+func ServeMessages[M any](ctx context.Context, t *Throttle, read func(context.Context) (M, error), handle func(M) error) error {
+	session := t.NewSession()
+	defer session.Close()
+	for {
+		msg, err := read(ctx)
+		if err != nil {
+			return err
+		}
+		if err := session.WaitContext(ctx); err != nil {
+			return err
+		}
+		if err := handle(msg); err != nil {
+			return err
+		}
+	}
+}
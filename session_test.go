@@ -0,0 +1,73 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestSession(t *testing.T) {
+	throttle := New(1*time.Hour, WithBurst(2), WithMaxWait(time.Millisecond), WithKeyNormalizer(Hashed))
+	defer throttle.Close()
+
+	alice, bob := throttle.NewSession(), throttle.NewSession()
+	if alice.Key() == bob.Key() {
+		t.Fatalf("sessions share key %q", alice.Key())
+	}
+	if !alice.Allow() || alice.WaitContext(context.Background()) != nil {
+		t.Error("messages within the burst throttled")
+	}
+	if alice.Allow() {
+		t.Error("message beyond the burst allowed")
+	}
+	if !bob.Allow() {
+		t.Error("message of other session throttled")
+	}
+
+	alice.Close()
+	alice.Close()
+	if size := throttle.Size(); size.Clients != 1 || size.Stats != 1 {
+		t.Errorf("closed session not forgotten: %+v", size)
+	}
+}
+
+func TestServeMessages(t *testing.T) {
+	throttle := New(1*time.Hour, WithBurst(3), WithMaxWait(time.Millisecond))
+	defer throttle.Close()
+
+	messages := []string{"a", "b"}
+	read := func(ctx context.Context) (string, error) {
+		if len(messages) == 0 {
+			return "", io.EOF
+		}
+		msg := messages[0]
+		messages = messages[1:]
+		return msg, nil
+	}
+	var handled []string
+	handle := func(msg string) error {
+		handled = append(handled, msg)
+		return nil
+	}
+	if err := ServeMessages(context.Background(), throttle, read, handle); err != io.EOF {
+		t.Errorf("expected %v, got %v", io.EOF, err)
+	}
+	if len(handled) != 2 {
+		t.Errorf("expected 2 messages handled, got %v", handled)
+	}
+
+	// every connection gets its own bucket
+	messages = []string{"c", "d", "e", "f"}
+	handled = nil
+	if err := ServeMessages(context.Background(), throttle, read, handle); !errors.Is(err, ErrThrottled) {
+		t.Errorf("expected %v, got %v", ErrThrottled, err)
+	}
+	if len(handled) != 3 {
+		t.Errorf("expected 3 messages handled, got %v", handled)
+	}
+	if size := throttle.Size(); size.Clients != 0 || size.Stats != 0 {
+		t.Errorf("sessions not forgotten: %+v", size)
+	}
+}
@@ -0,0 +1,58 @@
+package throttle
+
+import "math"
+
+// WithShadowMode makes the Throttle observe what its limits would do without
+// enforcing them, e.g. to try a new limit in production: requests take their
+// tokens and are counted as allowed or rejected as usual, firing the hooks of
+// WithOnAllow and WithOnReject, but requests that would be rejected with a
+// *ThrottledError are let pass, and requests that would wait for their token
+// do not wait, while their waiting time is recorded as if they did. Requests
+// do not line up in the queues of WithPriorities and WithFairQueueing in
+// shadow mode. Other errors, such as ErrClosed or ErrBurstExceeded, are still
+// returned.
+func WithShadowMode(on bool) Option {
+	return func(t *Throttle) {
+		t.shadow = on
+	}
+}
+
+// WithEnforcementFraction enforces the Throttle's limits on the given
+// fraction of the clients only, e.g. 0.1 to roll out a new limit gradually,
+// while the requests of all other clients are treated as in shadow mode (see
+// WithShadowMode). Whether a client is enforced is decided by a hash of its
+// key, so that it stays the same across requests and instances, and a client
+// enforced at a fraction is enforced at every higher fraction as well. By
+// default, all clients are enforced.
+func WithEnforcementFraction(fraction float64) Option {
+	return func(t *Throttle) {
+		t.unenforced = 1 - math.Min(math.Max(fraction, 0), 1)
+	}
+}
+
+// Enforced reports whether the Throttle's limits are enforced on the client,
+// rather than being observed only, e.g. to compare the clients enforced using
+// WithEnforcementFraction with the others.
+func (t *Throttle) Enforced(client string) bool {
+	return t.enforced(t.key(client))
+}
+
+func (t *Throttle) enforced(client string) bool {
+	return !t.shadows(client)
+}
+
+// shadows reports whether the limits are only observed for the client.
+func (t *Throttle) shadows(client string) bool {
+	if t.shadow {
+		return true
+	}
+	// the upper 53 bits of the hash as a fraction in [0, 1)
+	return t.unenforced > 0 && float64(ringHash(client)>>11)/(1<<53) < t.unenforced
+}
+
+// shadowed reports whether err is a *ThrottledError for a client whose limits
+// are only observed.
+func (t *Throttle) shadowed(err error) bool {
+	throttledErr, ok := asThrottled(err)
+	return ok && t.shadows(throttledErr.Client)
+}
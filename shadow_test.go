@@ -0,0 +1,98 @@
+package throttle
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestShadowMode(t *testing.T) {
+	clock := newFakeClock()
+	rejected := 0
+	throttle := New(1*time.Second, WithBurst(2), WithMaxWait(1500*time.Millisecond), WithShadowMode(true), WithClock(clock),
+		WithOnReject(func(client string) { rejected++ }))
+
+	// the second waiting request would wait, the third would be rejected
+	for i := 0; i < 5; i++ {
+		if err := throttle.Wait("alice"); err != nil {
+			t.Errorf("request %d: expected no error in shadow mode, got %v", i, err)
+		}
+	}
+	if !throttle.Allow("alice") {
+		t.Error("Allow: expected true in shadow mode")
+	}
+	if r := throttle.Reserve("alice"); !r.OK() || r.Delay() != 0 {
+		t.Errorf("Reserve: expected OK without delay, got %v and %v", r.OK(), r.Delay())
+	}
+	if err := throttle.WaitAll("alice", "bob"); err != nil {
+		t.Errorf("WaitAll: expected no error in shadow mode, got %v", err)
+	}
+
+	stats := throttle.Stats("alice")
+	if stats.Allowed != 3 || stats.Rejected != 5 || rejected != 5 {
+		t.Errorf("expected 3 allowed and 5 rejected, got %+v and %d hook calls", stats, rejected)
+	}
+	if wait := throttle.Metrics().Wait; wait.Sum != 1*time.Second {
+		t.Errorf("expected the waiting time to be recorded, got %v", wait.Sum)
+	}
+}
+
+func TestShadowModeMiddleware(t *testing.T) {
+	throttle := New(1*time.Second, WithMaxWait(10*time.Millisecond), WithShadowMode(true))
+	handler := throttle.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), nil)
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("request %d: expected %d in shadow mode, got %d", i, http.StatusOK, rec.Code)
+		}
+	}
+	if stats := throttle.Stats("192.0.2.1"); stats.Rejected != 2 {
+		t.Errorf("expected 2 rejections to be recorded, got %d", stats.Rejected)
+	}
+}
+
+func TestEnforcementFraction(t *testing.T) {
+	some := New(1*time.Second, WithMaxWait(10*time.Millisecond), WithEnforcementFraction(0.3))
+	more := New(1*time.Second, WithEnforcementFraction(0.6))
+	enforced := 0
+	for i := 0; i < 1000; i++ {
+		client := fmt.Sprintf("client-%d", i)
+		if !some.Enforced(client) {
+			continue
+		}
+		enforced++
+		if !more.Enforced(client) {
+			t.Errorf("%s: expected to stay enforced at a higher fraction", client)
+		}
+	}
+	if enforced < 250 || enforced > 350 {
+		t.Errorf("expected about 300 of 1000 clients to be enforced, got %d", enforced)
+	}
+
+	var yes, no string
+	for i := 0; yes == "" || no == ""; i++ {
+		client := fmt.Sprintf("client-%d", i)
+		if some.Enforced(client) {
+			yes = client
+		} else {
+			no = client
+		}
+	}
+	for i := 0; i < 2; i++ {
+		some.Allow(yes)
+		some.Allow(no)
+	}
+	if err := some.Wait(yes); !errors.Is(err, ErrThrottled) {
+		t.Errorf("enforced client: expected %v, got %v", ErrThrottled, err)
+	}
+	if err := some.Wait(no); err != nil {
+		t.Errorf("client not enforced: expected no error, got %v", err)
+	}
+	if stats := some.Stats(no); stats.Rejected != 2 {
+		t.Errorf("client not enforced: expected 2 rejections to be recorded, got %d", stats.Rejected)
+	}
+}
@@ -0,0 +1,101 @@
+package throttle
+
+import "sync"
+
+// numShards is the number of shards a shardedMap is split into. It must be a
+// power of two.
+const numShards = 64
+
+// shardedMap is a map of clients split into shards with a mutex each, so that
+// requests of different clients rarely contend for the same lock.
+type shardedMap[V any] struct {
+	shards [numShards]shard[V]
+}
+
+// shard is a part of a shardedMap. Its entries must only be accessed while
+// holding its mutex.
+type shard[V any] struct {
+	mutex   sync.Mutex
+	entries map[string]V
+}
+
+func newShardedMap[V any]() *shardedMap[V] {
+	m := &shardedMap[V]{}
+	for i := range m.shards {
+		m.shards[i].entries = make(map[string]V)
+	}
+	return m
+}
+
+// shard returns the shard holding the client's entry.
+func (m *shardedMap[V]) shard(client string) *shard[V] {
+	return &m.shards[m.index(client)]
+}
+
+// index returns the index of the shard holding the client's entry.
+func (m *shardedMap[V]) index(client string) uint32 {
+	return shardHash(client) & (numShards - 1)
+}
+
+// shardHash hashes the client to pick its shard.
+func shardHash(client string) uint32 {
+	// FNV-1a, inlined to hash the string without allocating
+	hash := uint32(2166136261)
+	for i := 0; i < len(client); i++ {
+		hash ^= uint32(client[i])
+		hash *= 16777619
+	}
+	return hash
+}
+
+// Len returns the number of entries in all shards.
+func (m *shardedMap[V]) Len() int {
+	n := 0
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mutex.Lock()
+		n += len(s.entries)
+		s.mutex.Unlock()
+	}
+	return n
+}
+
+// each calls f for every entry, holding the mutex of the entry's shard. If f
+// returns false, the entry is deleted.
+func (m *shardedMap[V]) each(f func(client string, v V) bool) {
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mutex.Lock()
+		for client, v := range s.entries {
+			if !f(client, v) {
+				delete(s.entries, client)
+			}
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// delete removes the client's entry.
+func (m *shardedMap[V]) delete(client string) {
+	s := m.shard(client)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.entries, client)
+}
+
+// replace replaces all entries with the given ones.
+func (m *shardedMap[V]) replace(entries map[string]V) {
+	var shards [numShards]map[string]V
+	for i := range shards {
+		shards[i] = make(map[string]V)
+	}
+	for client, v := range entries {
+		shards[m.index(client)][client] = v
+	}
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mutex.Lock()
+		s.entries = shards[i]
+		s.mutex.Unlock()
+	}
+}
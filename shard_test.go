@@ -0,0 +1,40 @@
+package throttle
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestShardedMap(t *testing.T) {
+	m := newShardedMap[int]()
+	entries := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		entries[fmt.Sprintf("client-%d", i)] = i
+	}
+	m.replace(entries)
+	if n := m.Len(); n != len(entries) {
+		t.Fatalf("expected %d entries, got %d", len(entries), n)
+	}
+	for client, i := range entries {
+		if v := m.shard(client).entries[client]; v != i {
+			t.Errorf("%s: expected %d, got %d", client, i, v)
+		}
+	}
+
+	used := 0
+	for i := range m.shards {
+		if len(m.shards[i].entries) > 0 {
+			used++
+		}
+	}
+	if used != numShards {
+		t.Errorf("expected entries in all %d shards, got %d", numShards, used)
+	}
+
+	m.each(func(client string, v int) bool {
+		return v%2 == 0
+	})
+	if n := m.Len(); n != len(entries)/2 {
+		t.Errorf("expected %d entries after deleting odd ones, got %d", len(entries)/2, n)
+	}
+}
@@ -0,0 +1,88 @@
+package throttle
+
+import "time"
+
+const (
+	// bucketBytes is the estimated memory used per bucket kept in memory,
+	// including its map entry and a short key.
+	bucketBytes = 128
+
+	// statsBytes is the estimated memory used per client's stats.
+	statsBytes = 144
+)
+
+// Footprint describes the resources used by a Throttle, see Size.
+type Footprint struct {
+	// Clients is the number of buckets tracked by the Store, or -1 if the
+	// Store cannot tell.
+	Clients int
+
+	// Stats is the number of clients whose stats are kept.
+	Stats int
+
+	// Waiters is the number of requests waiting for their tokens. The
+	// goroutines of the callers are blocked while waiting.
+	Waiters int
+
+	// Goroutines is the number of goroutines started by the Throttle, e.g.
+	// for WithIdleTimeout, WithPriorities, or WaitChan.
+	Goroutines int
+
+	// Bytes is a rough estimate of the memory used for the buckets kept in
+	// memory and the stats of the clients.
+	Bytes int
+}
+
+// Size returns the resources currently used by the Throttle, so that its
+// growth can be monitored. It visits the stats of all clients, so it should
+// not be called for every request.
+func (t *Throttle) Size() Footprint {
+	f := Footprint{
+		Clients:    t.clients(),
+		Stats:      t.metrics.clients.Len(),
+		Goroutines: int(t.goroutines.Load()),
+	}
+	t.metrics.clients.each(func(client string, stats *ClientStats) bool {
+		f.Waiters += stats.Waiters
+		return true
+	})
+	if _, ok := t.store.(idleEvicter); ok && f.Clients > 0 {
+		f.Bytes += f.Clients * bucketBytes
+	}
+	f.Bytes += f.Stats * statsBytes
+	return f
+}
+
+// WithSizeReport calls report with the Throttle's Size once per interval,
+// until the Throttle is closed, e.g. to alert on a Throttle tracking more
+// clients than expected.
+func WithSizeReport(interval time.Duration, report func(Footprint)) Option {
+	return func(t *Throttle) {
+		if interval <= 0 || report == nil {
+			return
+		}
+		t.sizeInterval, t.sizeReport = interval, report
+	}
+}
+
+// reportSize calls the size report once per interval until the Throttle is
+// closed.
+func (t *Throttle) reportSize() {
+	for {
+		select {
+		case <-t.clock.After(t.sizeInterval):
+			t.sizeReport(t.Size())
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// spawn runs f in a goroutine counted by Size.
+func (t *Throttle) spawn(f func()) {
+	t.goroutines.Add(1)
+	go func() {
+		defer t.goroutines.Add(-1)
+		f()
+	}()
+}
@@ -0,0 +1,58 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSize(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Second, WithClock(clock), WithMaxWait(1*time.Minute))
+	defer throttle.Close()
+	if size := throttle.Size(); size != (Footprint{}) {
+		t.Errorf("new Throttle: expected no resources used, got %+v", size)
+	}
+
+	throttle.Allow("alice")
+	throttle.Allow("bob")
+	result := throttle.WaitChan("alice")
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	size := throttle.Size()
+	if size.Clients != 2 || size.Stats != 2 || size.Waiters != 1 || size.Goroutines != 1 {
+		t.Errorf("expected 2 clients, 2 stats, 1 waiter, and 1 goroutine, got %+v", size)
+	}
+	if size.Bytes < 2*bucketBytes {
+		t.Errorf("expected at least %d bytes, got %d", 2*bucketBytes, size.Bytes)
+	}
+
+	clock.Advance(1 * time.Second)
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	for throttle.Size().Goroutines > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if size := throttle.Size(); size.Waiters != 0 {
+		t.Errorf("expected no waiters, got %+v", size)
+	}
+}
+
+func TestSizeReport(t *testing.T) {
+	clock := newFakeClock()
+	reports := make(chan Footprint)
+	throttle := New(1*time.Second, WithClock(clock), WithSizeReport(1*time.Minute, func(f Footprint) {
+		reports <- f
+	}))
+	defer throttle.Close()
+	throttle.Allow("alice")
+
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(1 * time.Minute)
+	if f := <-reports; f.Clients != 1 || f.Goroutines != 1 {
+		t.Errorf("expected 1 client and the reporting goroutine, got %+v", f)
+	}
+}
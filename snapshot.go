@@ -0,0 +1,65 @@
+package throttle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrSnapshotUnsupported is returned by Snapshot and Restore if the Throttle's
+// Store does not keep its state in memory.
+var ErrSnapshotUnsupported = errors.New("store does not support snapshots")
+
+// snapshotter is implemented by the Stores keeping their state in memory.
+type snapshotter interface {
+	// kind names the kind of state, so that a snapshot is only restored into
+	// the same kind of Store.
+	kind() string
+
+	// snapshot returns the state of all clients.
+	snapshot() interface{}
+
+	// restore replaces the state of all clients by the one in data.
+	restore(data json.RawMessage) error
+}
+
+// snapshot is the serialized state of a Store.
+type snapshot struct {
+	Store   string          `json:"store"`
+	Clients json.RawMessage `json:"clients"`
+}
+
+// Snapshot serializes the state of all clients, so that it can be restored
+// using Restore, e.g. after restarting the process. Otherwise, every client
+// would start with a full bucket upon restart. State that is not kept by the
+// Store, such as waiting requests and metrics, is not included.
+func (t *Throttle) Snapshot() ([]byte, error) {
+	s, ok := t.store.(snapshotter)
+	if !ok {
+		return nil, ErrSnapshotUnsupported
+	}
+	clients, err := json.Marshal(s.snapshot())
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(snapshot{Store: s.kind(), Clients: clients})
+}
+
+// Restore replaces the state of all clients by the one serialized using
+// Snapshot. The snapshot must have been taken from a Throttle using the same
+// algorithm. Time keeps passing while the process is down, so the clients'
+// tokens are spawned as if the process had been running all along.
+func (t *Throttle) Restore(data []byte) error {
+	s, ok := t.store.(snapshotter)
+	if !ok {
+		return ErrSnapshotUnsupported
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	if snap.Store != s.kind() {
+		return fmt.Errorf("cannot restore snapshot of %s store into %s store", snap.Store, s.kind())
+	}
+	return s.restore(snap.Clients)
+}
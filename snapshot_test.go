@@ -0,0 +1,49 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	tests := []struct {
+		name string
+		new  func(Clock) *Throttle
+	}{
+		{"memory", func(c Clock) *Throttle { return New(1*time.Second, WithBurst(2), WithClock(c)) }},
+		{"gcra", func(c Clock) *Throttle { return New(1*time.Second, WithBurst(2), WithGCRA(), WithClock(c)) }},
+		{"sliding window", func(c Clock) *Throttle { return NewSlidingWindow(2, 2*time.Second, WithClock(c)) }},
+		{"fixed window", func(c Clock) *Throttle { return NewFixedWindow(2, 2*time.Second, WithClock(c)) }},
+	}
+	for _, test := range tests {
+		clock := newFakeClock()
+		before := test.new(clock)
+		before.Allow("alice")
+		before.Allow("alice")
+		data, err := before.Snapshot()
+		if err != nil {
+			t.Fatalf("%s: snapshot: expected no error, got %v", test.name, err)
+		}
+
+		after := test.new(clock)
+		if err := after.Restore(data); err != nil {
+			t.Fatalf("%s: restore: expected no error, got %v", test.name, err)
+		}
+		if after.Allow("alice") {
+			t.Errorf("%s: after restore: expected the client's tokens to be used up", test.name)
+		}
+		if !after.Allow("bob") {
+			t.Errorf("%s: after restore: expected other client to be allowed", test.name)
+		}
+	}
+}
+
+func TestRestoreMismatch(t *testing.T) {
+	data, _ := New(1 * time.Second).Snapshot()
+	if err := New(1*time.Second, WithGCRA()).Restore(data); err == nil {
+		t.Errorf("expected snapshot of other store to be refused")
+	}
+	if _, err := New(1*time.Second, WithStore(struct{ Store }{newMemoryStore(realClock{})})).Snapshot(); err != ErrSnapshotUnsupported {
+		t.Errorf("expected %v, got %v", ErrSnapshotUnsupported, err)
+	}
+}
@@ -0,0 +1,43 @@
+package throttle
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// WithSoftLimit warns clients that have used up the given fraction of their
+// burst, e.g. 0.8, before their requests are rejected, so that they can back
+// off voluntarily: Middleware adds a RateLimit-Warning header to the
+// responses of such clients, and f, unless nil, is called for every request
+// of such a client that acquired a token, with the client and the tokens it
+// has left. Since the tokens left are looked up after every request, f costs
+// another call of the Store's Peek per request. The fraction is capped to the
+// range (0, 1]; by default, there is no soft limit.
+func WithSoftLimit(fraction float64, f func(client string, remaining int)) Option {
+	return func(t *Throttle) {
+		if fraction <= 0 {
+			return
+		}
+		t.softLimit = math.Min(fraction, 1)
+		if f == nil {
+			return
+		}
+		t.onAllow = append(t.onAllow, func(client string, waited time.Duration) {
+			limit := t.limit(client)
+			result, err := t.store.Peek(context.Background(), t.bucketKey(client), limit)
+			if err == nil && t.beyondSoftLimit(limit, result.Remaining) {
+				f(client, result.Remaining)
+			}
+		})
+	}
+}
+
+// beyondSoftLimit reports whether a client with the given limit and tokens
+// left has used up the fraction of its burst set by WithSoftLimit.
+func (t *Throttle) beyondSoftLimit(limit Limit, remaining int) bool {
+	if t.softLimit == 0 {
+		return false
+	}
+	return float64(limit.Burst-remaining) >= t.softLimit*float64(limit.Burst)
+}
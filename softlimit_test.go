@@ -0,0 +1,37 @@
+package throttle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSoftLimit(t *testing.T) {
+	clock := newFakeClock()
+	var warned []int
+	throttle := New(1*time.Second, WithBurst(5), WithClock(clock), WithSoftLimit(0.6, func(client string, remaining int) {
+		warned = append(warned, remaining)
+	}))
+
+	for i := 0; i < 5; i++ {
+		throttle.Allow("alice")
+	}
+	if len(warned) != 3 || warned[0] != 2 || warned[2] != 0 {
+		t.Errorf("expected warnings with 2, 1, and 0 tokens left, got %v", warned)
+	}
+}
+
+func TestSoftLimitHeader(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Second, WithBurst(4), WithClock(clock), WithSoftLimit(0.5, nil))
+	handler := throttle.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), nil)
+
+	for i, expected := range []string{"", "2 of 4 requests left", "1 of 4 requests left"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if warning := rec.Header().Get("RateLimit-Warning"); warning != expected {
+			t.Errorf("request %d: expected warning %q, got %q", i, expected, warning)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// Package sqlthrottle throttles the queries and statements sent to a database
+// through database/sql, using a throttle.Throttle keyed by a logical client
+// like a tenant, so that heavy clients cannot monopolize the connection pool:
+//
+//	connector := sqlthrottle.NewConnector(pq.NewConnector(dsn), t, nil)
+//	db := sql.OpenDB(connector)
+//	rows, err := db.QueryContext(sqlthrottle.WithKey(ctx, tenant), query)
+//
+// Every query and exec, as well as every execution of a prepared statement,
+// takes a token; preparing statements, transactions, and pings do not. A
+// throttled query fails right away with the Throttle's error, matching
+// throttle.ErrThrottled, before it is sent to the database. It does not wait
+// for a token, for it holds a connection of the pool while doing so; to wait,
+// call the Throttle's WaitContext before the query instead.
+package sqlthrottle
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+
+	"github.com/patrickbucher/throttle"
+)
+
+// KeyFunc derives the client key of a query from its context.
+type KeyFunc func(ctx context.Context) string
+
+// keyContext is the type of the context key of the client set by WithKey.
+type keyContext struct{}
+
+// WithKey returns a copy of ctx carrying the client key of the queries made
+// using it.
+func WithKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, keyContext{}, key)
+}
+
+// ContextKey keys queries by the client set using WithKey. Queries made
+// without a key share the empty key.
+func ContextKey(ctx context.Context) string {
+	key, _ := ctx.Value(keyContext{}).(string)
+	return key
+}
+
+// connector is a driver.Connector handing out throttled connections.
+type connector struct {
+	connector driver.Connector
+	throttle  *throttle.Throttle
+	keyFn     KeyFunc
+}
+
+// NewConnector wraps c so that the queries of its connections take a token of
+// the client derived from their context using keyFn, e.g. a tenant.
+// If keyFn is nil, ContextKey is used. Drivers implementing
+// driver.DriverContext provide a driver.Connector using OpenConnector.
+func NewConnector(c driver.Connector, t *throttle.Throttle, keyFn KeyFunc) driver.Connector {
+	if keyFn == nil {
+		keyFn = ContextKey
+	}
+	return &connector{connector: c, throttle: t, keyFn: keyFn}
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &throttledConn{conn: conn, connector: c}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.connector.Driver()
+}
+
+// take takes a token of the client the query made using ctx belongs to. It
+// does not wait, so that throttled clients do not hold on to connections.
+func (c *connector) take(ctx context.Context) error {
+	return c.throttle.TryWaitFor(c.keyFn(ctx), 0)
+}
+
+// throttledConn is a connection whose queries are throttled. It implements
+// the optional interfaces of the driver package, falling back to what
+// database/sql does if the underlying connection does not implement them.
+type throttledConn struct {
+	conn      driver.Conn
+	connector *connector
+}
+
+func (c *throttledConn) Prepare(query string) (driver.Stmt, error) {
+	stmt, err := c.conn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &throttledStmt{stmt: stmt, conn: c.conn, connector: c.connector}, nil
+}
+
+func (c *throttledConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	preparer, ok := c.conn.(driver.ConnPrepareContext)
+	if !ok {
+		return c.Prepare(query)
+	}
+	stmt, err := preparer.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &throttledStmt{stmt: stmt, conn: c.conn, connector: c.connector}, nil
+}
+
+func (c *throttledConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *throttledConn) Begin() (driver.Tx, error) {
+	return c.conn.Begin()
+}
+
+func (c *throttledConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != driver.IsolationLevel(0) || opts.ReadOnly {
+		return nil, errors.New("sqlthrottle: driver does not support transaction options")
+	}
+	return c.Begin()
+}
+
+func (c *throttledConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.conn.(driver.QueryerContext)
+	if !ok {
+		// database/sql prepares a statement instead, which is throttled
+		return nil, driver.ErrSkip
+	}
+	if err := c.connector.take(ctx); err != nil {
+		return nil, err
+	}
+	return queryer.QueryContext(ctx, query, args)
+}
+
+func (c *throttledConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.conn.(driver.ExecerContext)
+	if !ok {
+		// database/sql prepares a statement instead, which is throttled
+		return nil, driver.ErrSkip
+	}
+	if err := c.connector.take(ctx); err != nil {
+		return nil, err
+	}
+	return execer.ExecContext(ctx, query, args)
+}
+
+func (c *throttledConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *throttledConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *throttledConn) IsValid() bool {
+	if validator, ok := c.conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *throttledConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// throttledStmt is a prepared statement whose executions are throttled.
+type throttledStmt struct {
+	stmt      driver.Stmt
+	conn      driver.Conn
+	connector *connector
+}
+
+func (s *throttledStmt) Close() error {
+	return s.stmt.Close()
+}
+
+func (s *throttledStmt) NumInput() int {
+	return s.stmt.NumInput()
+}
+
+func (s *throttledStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if err := s.connector.take(context.Background()); err != nil {
+		return nil, err
+	}
+	return s.stmt.Exec(args)
+}
+
+func (s *throttledStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if err := s.connector.take(context.Background()); err != nil {
+		return nil, err
+	}
+	return s.stmt.Query(args)
+}
+
+func (s *throttledStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if err := s.connector.take(ctx); err != nil {
+		return nil, err
+	}
+	if execer, ok := s.stmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+	values, err := values(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.stmt.Exec(values)
+}
+
+func (s *throttledStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if err := s.connector.take(ctx); err != nil {
+		return nil, err
+	}
+	if queryer, ok := s.stmt.(driver.StmtQueryContext); ok {
+		return queryer.QueryContext(ctx, args)
+	}
+	values, err := values(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.stmt.Query(values)
+}
+
+func (s *throttledStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	if checker, ok := s.conn.(driver.NamedValueChecker); ok {
+		// database/sql only asks the connection if the statement cannot tell
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// values converts the arguments for a driver not supporting named ones.
+func values(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("sqlthrottle: driver does not support named arguments")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
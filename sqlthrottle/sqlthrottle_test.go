@@ -0,0 +1,166 @@
+package sqlthrottle
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/patrickbucher/throttle"
+)
+
+// fakeDriver counts the statements reaching the database. Its connections
+// implement the context interfaces only if withContext is set, so that the
+// fallback to prepared statements is used otherwise.
+type fakeDriver struct {
+	withContext bool
+	statements  atomic.Int64
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	if d.withContext {
+		return &contextConn{fakeConn{d}}, nil
+	}
+	return &fakeConn{d}, nil
+}
+
+func (d *fakeDriver) Connect(ctx context.Context) (driver.Conn, error) {
+	return d.Open("")
+}
+
+func (d *fakeDriver) Driver() driver.Driver {
+	return d
+}
+
+type fakeConn struct {
+	driver *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{c.driver}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+type contextConn struct {
+	fakeConn
+}
+
+func (c *contextConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.driver.statements.Add(1)
+	return fakeRows{}, nil
+}
+
+func (c *contextConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.driver.statements.Add(1)
+	return driver.RowsAffected(1), nil
+}
+
+type fakeStmt struct {
+	driver *fakeDriver
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.driver.statements.Add(1)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.driver.statements.Add(1)
+	return fakeRows{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string              { return nil }
+func (fakeRows) Close() error                   { return nil }
+func (fakeRows) Next(dest []driver.Value) error { return io.EOF }
+
+func TestConnector(t *testing.T) {
+	for _, withContext := range []bool{true, false} {
+		drv := &fakeDriver{withContext: withContext}
+		limiter := throttle.New(1*time.Second, throttle.WithMaxWait(10*time.Millisecond))
+		db := sql.OpenDB(NewConnector(drv, limiter, nil))
+		defer db.Close()
+		alice := WithKey(context.Background(), "alice")
+
+		if _, err := db.ExecContext(alice, "UPDATE accounts SET balance = ?", 42); err != nil {
+			t.Errorf("context %v, first exec: expected no error, got %v", withContext, err)
+		}
+		if _, err := db.QueryContext(alice, "SELECT * FROM accounts"); !errors.Is(err, throttle.ErrThrottled) {
+			t.Errorf("context %v, second query: expected %v, got %v", withContext, throttle.ErrThrottled, err)
+		}
+		rows, err := db.QueryContext(WithKey(context.Background(), "bob"), "SELECT * FROM accounts")
+		if err != nil {
+			t.Errorf("context %v, other tenant: expected no error, got %v", withContext, err)
+		} else {
+			rows.Close()
+		}
+		if n := drv.statements.Load(); n != 2 {
+			t.Errorf("context %v: expected 2 statements to reach the database, got %d", withContext, n)
+		}
+	}
+}
+
+func TestConnectorPrepared(t *testing.T) {
+	drv := &fakeDriver{withContext: true}
+	limiter := throttle.New(1*time.Second, throttle.WithBurst(2), throttle.WithMaxWait(10*time.Millisecond))
+	db := sql.OpenDB(NewConnector(drv, limiter, func(ctx context.Context) string { return "shared" }))
+	defer db.Close()
+
+	stmt, err := db.Prepare("INSERT INTO events VALUES (?)")
+	if err != nil {
+		t.Fatalf("prepare: expected no error, got %v", err)
+	}
+	defer stmt.Close()
+	for i, expected := range []error{nil, nil, throttle.ErrThrottled} {
+		if _, err := stmt.Exec(i); !errors.Is(err, expected) {
+			t.Errorf("exec %d: expected %v, got %v", i, expected, err)
+		}
+	}
+	if err := db.Ping(); err != nil {
+		t.Errorf("ping: expected not to be throttled, got %v", err)
+	}
+}
+
+func TestConnectorNoWait(t *testing.T) {
+	drv := &fakeDriver{withContext: true}
+	limiter := throttle.New(1*time.Second, throttle.WithMaxWait(1*time.Minute))
+	db := sql.OpenDB(NewConnector(drv, limiter, nil))
+	defer db.Close()
+	alice := WithKey(context.Background(), "alice")
+
+	if _, err := db.ExecContext(alice, "UPDATE accounts SET balance = ?", 42); err != nil {
+		t.Errorf("first exec: expected no error, got %v", err)
+	}
+	start := time.Now()
+	if _, err := db.ExecContext(alice, "UPDATE accounts SET balance = ?", 43); !errors.Is(err, throttle.ErrThrottled) {
+		t.Errorf("second exec: expected %v, got %v", throttle.ErrThrottled, err)
+	}
+	if elapsed := time.Since(start); elapsed >= 500*time.Millisecond {
+		t.Errorf("second exec: expected to fail without waiting, took %v", elapsed)
+	}
+}
@@ -0,0 +1,268 @@
+package throttle
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// Limit describes the token bucket of a client.
+type Limit struct {
+	// Rate is the time it takes to spawn one token.
+	Rate time.Duration
+
+	// Burst is the number of tokens the bucket holds when it is full.
+	Burst int
+}
+
+// Result describes the state of a client's bucket after a Store operation.
+type Result struct {
+	// OK reports whether the tokens requested were taken.
+	OK bool
+
+	// Wait is the time until the tokens taken become available. If no tokens
+	// were taken, it is the time until the tokens requested become available.
+	Wait time.Duration
+
+	// Remaining is the number of tokens left in the bucket.
+	Remaining int
+
+	// Reset is the time until the bucket is full again, provided that no
+	// further tokens are taken in the meantime.
+	Reset time.Duration
+}
+
+// Store keeps the token buckets of a Throttle's clients. A client that is not
+// known to the Store starts with a full bucket. Implementations must be safe
+// for concurrent use.
+type Store interface {
+	// TryConsume takes n tokens from the client's bucket, provided that they
+	// become available within maxWait. Tokens that are not available yet are
+	// reserved for the caller, which has to wait for Result.Wait before using
+	// them. n never exceeds limit.Burst.
+	TryConsume(ctx context.Context, client string, limit Limit, n int, maxWait time.Duration) (Result, error)
+
+	// Refund puts n tokens back into the client's bucket; e.g. if the caller
+	// reserved tokens it is no longer going to use.
+	Refund(ctx context.Context, client string, limit Limit, n int) error
+
+	// Peek returns the state of the client's bucket without taking a token.
+	Peek(ctx context.Context, client string, limit Limit) (Result, error)
+}
+
+// BatchStore is implemented by Stores that can take tokens from the buckets
+// of several limits of a request at once, e.g. the buckets of a client and
+// its tiers, so that concurrent requests sharing a Store never see a state in
+// which some of the buckets have been taken from and others not. A Throttle
+// whose Store is a BatchStore takes the tokens of a request from all its
+// buckets using TryConsumeAll rather than one TryConsume per bucket.
+type BatchStore interface {
+	Store
+
+	// TryConsumeAll takes n tokens from each of the buckets, provided that
+	// all of them become available within maxWait; otherwise, no tokens are
+	// taken at all. It returns the results of the buckets in order, all of
+	// which are OK if the tokens were taken. n never exceeds the burst of a
+	// bucket.
+	TryConsumeAll(ctx context.Context, buckets []KeyedLimit, n int, maxWait time.Duration) ([]Result, error)
+}
+
+// KeyedLimit is the limit of a bucket taken from by a BatchStore, together
+// with the bucket's key.
+type KeyedLimit struct {
+	Key   string
+	Limit Limit
+}
+
+// WithStore makes the Throttle keep its token buckets in the given Store
+// instead of in memory, e.g. to share them between multiple processes.
+func WithStore(store Store) Option {
+	return func(t *Throttle) {
+		t.store = store
+	}
+}
+
+// WithIdleTimeout makes the Throttle forget about clients that have not
+// requested a token for the given duration, so that the memory used for
+// clients is bounded. A client that is forgotten starts with a full bucket and
+// fresh stats again; so the idle timeout should be at least as long as it
+// takes to fill a bucket. Buckets kept using WithStore are not affected.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(t *Throttle) {
+		t.idleTimeout = d
+	}
+}
+
+// TokenBucket is the state of a token bucket, with which Stores keeping the
+// buckets elsewhere, e.g. in a database, take and refund tokens the same way
+// as the Throttle's own Store does. Instead of spawning tokens periodically,
+// the tokens spawned since the last update are added whenever the bucket is
+// used, see Advance, so an idle client costs no more than its stored state.
+type TokenBucket struct {
+	// Tokens is the number of tokens in the bucket, which is negative if
+	// tokens have been reserved.
+	Tokens float64
+
+	// Last is the time of the last update.
+	Last time.Time
+}
+
+// NewTokenBucket returns the full bucket of a client unknown so far.
+func NewTokenBucket(limit Limit, now time.Time) TokenBucket {
+	// the first tokens are spawned immediately
+	return TokenBucket{Tokens: float64(limit.Burst), Last: now}
+}
+
+// Advance adds the tokens spawned since the last update.
+func (b *TokenBucket) Advance(limit Limit, now time.Time) {
+	if elapsed := now.Sub(b.Last); elapsed > 0 {
+		spawned := float64(elapsed) / float64(limit.Rate)
+		b.Tokens = math.Min(b.Tokens+spawned, float64(limit.Burst))
+		b.Last = now
+	}
+}
+
+// TryConsume takes n tokens, provided that they become available within
+// maxWait, like Store.TryConsume.
+func (b *TokenBucket) TryConsume(limit Limit, n int, maxWait time.Duration) Result {
+	wait := b.wait(limit, n)
+	if wait > maxWait {
+		return b.result(false, wait, limit)
+	}
+	b.Tokens -= float64(n)
+	return b.result(true, wait, limit)
+}
+
+// Refund puts n tokens back, like Store.Refund.
+func (b *TokenBucket) Refund(limit Limit, n int) {
+	b.Tokens = math.Min(b.Tokens+float64(n), float64(limit.Burst))
+}
+
+// Peek returns the state of the bucket without taking a token, like
+// Store.Peek.
+func (b *TokenBucket) Peek(limit Limit) Result {
+	return b.result(false, b.wait(limit, 1), limit)
+}
+
+// UntilFull returns the time until the bucket is full again, provided that no
+// further tokens are taken in the meantime.
+func (b *TokenBucket) UntilFull(limit Limit) time.Duration {
+	return time.Duration((float64(limit.Burst) - b.Tokens) * float64(limit.Rate))
+}
+
+// wait returns the time until n tokens are available.
+func (b *TokenBucket) wait(limit Limit, n int) time.Duration {
+	missing := float64(n) - b.Tokens
+	if missing <= 0 {
+		return 0
+	}
+	return time.Duration(missing * float64(limit.Rate))
+}
+
+func (b *TokenBucket) result(ok bool, wait time.Duration, limit Limit) Result {
+	return Result{
+		OK:        ok,
+		Wait:      wait,
+		Remaining: int(math.Max(math.Floor(b.Tokens), 0)),
+		Reset:     b.UntilFull(limit),
+	}
+}
+
+// memoryStore is the Store used by default, which keeps the buckets in a
+// sharded map.
+type memoryStore struct {
+	clock   Clock
+	buckets *shardedMap[*TokenBucket]
+}
+
+func newMemoryStore(clock Clock) *memoryStore {
+	return &memoryStore{
+		clock:   clock,
+		buckets: newShardedMap[*TokenBucket](),
+	}
+}
+
+func (s *memoryStore) TryConsume(ctx context.Context, client string, limit Limit, n int, maxWait time.Duration) (Result, error) {
+	shard := s.buckets.shard(client)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	return s.bucket(shard, client, limit, s.clock.Now()).TryConsume(limit, n, maxWait), nil
+}
+
+func (s *memoryStore) Refund(ctx context.Context, client string, limit Limit, n int) error {
+	shard := s.buckets.shard(client)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	s.bucket(shard, client, limit, s.clock.Now()).Refund(limit, n)
+	return nil
+}
+
+func (s *memoryStore) Peek(ctx context.Context, client string, limit Limit) (Result, error) {
+	shard := s.buckets.shard(client)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	return s.bucket(shard, client, limit, s.clock.Now()).Peek(limit), nil
+}
+
+// Len returns the number of clients with a bucket.
+func (s *memoryStore) Len() int {
+	return s.buckets.Len()
+}
+
+// evictIdle removes the buckets that have not been used since idleTimeout
+// before now.
+func (s *memoryStore) evictIdle(now time.Time, idleTimeout time.Duration) {
+	s.buckets.each(func(client string, b *TokenBucket) bool {
+		return now.Sub(b.Last) < idleTimeout
+	})
+}
+
+func (s *memoryStore) forget(client string) {
+	s.buckets.delete(client)
+}
+
+// bucket returns the client's bucket with the tokens spawned until now added.
+// It requires the caller to hold the mutex of the client's shard.
+func (s *memoryStore) bucket(shard *shard[*TokenBucket], client string, limit Limit, now time.Time) *TokenBucket {
+	b, ok := shard.entries[client]
+	if !ok {
+		bucket := NewTokenBucket(limit, now)
+		b = &bucket
+		shard.entries[client] = b
+	}
+	b.Advance(limit, now)
+	return b
+}
+
+// bucketState is the serialized state of a bucket.
+type bucketState struct {
+	Tokens float64   `json:"tokens"`
+	Last   time.Time `json:"last"`
+}
+
+func (s *memoryStore) kind() string {
+	return "memory"
+}
+
+func (s *memoryStore) snapshot() interface{} {
+	state := make(map[string]bucketState)
+	s.buckets.each(func(client string, b *TokenBucket) bool {
+		state[client] = bucketState{Tokens: b.Tokens, Last: b.Last}
+		return true
+	})
+	return state
+}
+
+func (s *memoryStore) restore(data json.RawMessage) error {
+	var state map[string]bucketState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	buckets := make(map[string]*TokenBucket, len(state))
+	for client, b := range state {
+		buckets[client] = &TokenBucket{Tokens: b.Tokens, Last: b.Last}
+	}
+	s.buckets.replace(buckets)
+	return nil
+}
@@ -0,0 +1,216 @@
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreReservation(t *testing.T) {
+	store := newMemoryStore(realClock{})
+	limit := Limit{Rate: 100 * time.Millisecond, Burst: 1}
+	ctx := context.Background()
+
+	result, _ := store.TryConsume(ctx, "alice", limit, 1, 0)
+	if !result.OK || result.Wait != 0 {
+		t.Fatalf("first token: expected to be available, got %+v", result)
+	}
+
+	// the next token is reserved for the first caller willing to wait for it
+	result, _ = store.TryConsume(ctx, "alice", limit, 1, 1*time.Second)
+	if !result.OK || result.Wait <= 0 || result.Wait > limit.Rate {
+		t.Errorf("reserved token: expected to be taken within %v, got %+v", limit.Rate, result)
+	}
+	result, _ = store.TryConsume(ctx, "alice", limit, 1, 150*time.Millisecond)
+	if result.OK || result.Wait <= limit.Rate {
+		t.Errorf("token after reserved one: expected to take longer than %v, got %+v", limit.Rate, result)
+	}
+}
+
+func TestMemoryStoreRefund(t *testing.T) {
+	store := newMemoryStore(realClock{})
+	limit := Limit{Rate: 1 * time.Second, Burst: 2}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		store.TryConsume(ctx, "alice", limit, 1, 0)
+	}
+	store.Refund(ctx, "alice", limit, 1)
+	result, _ := store.Peek(ctx, "alice", limit)
+	if result.Remaining != 1 {
+		t.Errorf("remaining after refund: expected %d, got %d", 1, result.Remaining)
+	}
+
+	// refunds never exceed the burst
+	for i := 0; i < 3; i++ {
+		store.Refund(ctx, "alice", limit, 1)
+	}
+	result, _ = store.Peek(ctx, "alice", limit)
+	if result.Remaining != limit.Burst || result.Reset != 0 {
+		t.Errorf("full bucket: expected %d remaining and no reset, got %+v", limit.Burst, result)
+	}
+}
+
+func TestStoresWeighted(t *testing.T) {
+	limit := Limit{Rate: 100 * time.Millisecond, Burst: 4}
+	ctx := context.Background()
+	tests := []struct {
+		name  string
+		store func(Clock) Store
+		wait  time.Duration
+	}{
+		{"memory", func(c Clock) Store { return newMemoryStore(c) }, 200 * time.Millisecond},
+		{"gcra", func(c Clock) Store { return newGCRAStore(c) }, 200 * time.Millisecond},
+		{"sliding window", func(c Clock) Store { return newSlidingWindowStore(c) }, 400 * time.Millisecond},
+		{"fixed window", func(c Clock) Store { return newFixedWindowStore(c) }, 400 * time.Millisecond},
+	}
+	for _, test := range tests {
+		store := test.store(newFakeClock())
+		result, _ := store.TryConsume(ctx, "alice", limit, 3, 0)
+		if !result.OK || result.Wait != 0 || result.Remaining != 1 {
+			t.Errorf("%s: three tokens: expected to be taken right away, got %+v", test.name, result)
+		}
+		result, _ = store.TryConsume(ctx, "alice", limit, 3, 0)
+		if result.OK || result.Wait != test.wait {
+			t.Errorf("%s: three more tokens: expected to wait %v, got %+v", test.name, test.wait, result)
+		}
+		result, _ = store.TryConsume(ctx, "alice", limit, 3, 1*time.Second)
+		if !result.OK || result.Wait != test.wait {
+			t.Errorf("%s: reserved tokens: expected to wait %v, got %+v", test.name, test.wait, result)
+		}
+		store.Refund(ctx, "alice", limit, 3)
+		result, _ = store.TryConsume(ctx, "alice", limit, 1, 0)
+		if !result.OK {
+			t.Errorf("%s: token after refund: expected to be taken, got %+v", test.name, result)
+		}
+	}
+}
+
+func TestClientsWithoutGoroutines(t *testing.T) {
+	throttle := New(1 * time.Second)
+	defer throttle.Close()
+	before := runtime.NumGoroutine()
+	for _, client := range benchmarkClients {
+		throttle.Allow(client)
+	}
+	if n := runtime.NumGoroutine() - before; n > 0 {
+		t.Errorf("expected no goroutines per client, got %d for %d clients", n, len(benchmarkClients))
+	}
+	if n := throttle.Metrics().Clients; n != len(benchmarkClients) {
+		t.Errorf("expected %d clients, got %d", len(benchmarkClients), n)
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	throttle := New(10*time.Millisecond, WithIdleTimeout(50*time.Millisecond))
+	store := throttle.store.(*memoryStore)
+	for _, client := range []string{"alice", "bob"} {
+		throttle.Allow(client)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if n := store.Len(); n != 0 {
+		t.Errorf("expected idle clients to be evicted, %d left", n)
+	}
+}
+
+func TestEvictIdle(t *testing.T) {
+	store := newMemoryStore(realClock{})
+	limit := Limit{Rate: 10 * time.Millisecond, Burst: 1}
+	now := time.Now()
+	for client, at := range map[string]time.Time{"alice": now.Add(-2 * time.Second), "bob": now} {
+		store.bucket(store.buckets.shard(client), client, limit, at)
+	}
+
+	store.evictIdle(now, 1*time.Second)
+	if _, ok := store.buckets.shard("alice").entries["alice"]; ok {
+		t.Errorf("idle client: expected to be evicted")
+	}
+	if _, ok := store.buckets.shard("bob").entries["bob"]; !ok {
+		t.Errorf("active client: expected to be kept")
+	}
+}
+
+// benchmarkClients are the keys used by the parallel benchmarks, so that
+// formatting them is not measured.
+var benchmarkClients = func() []string {
+	clients := make([]string, 1<<14)
+	for i := range clients {
+		clients[i] = fmt.Sprintf("client-%d", i)
+	}
+	return clients
+}()
+
+func BenchmarkMemoryStoreParallel(b *testing.B) {
+	store := newMemoryStore(realClock{})
+	limit := Limit{Rate: 1 * time.Nanosecond, Burst: 1 << 30}
+	ctx := context.Background()
+	var next atomic.Uint64
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := next.Add(1) * 7919
+		for pb.Next() {
+			store.TryConsume(ctx, benchmarkClients[i%uint64(len(benchmarkClients))], limit, 1, 0)
+			i++
+		}
+	})
+}
+
+func BenchmarkAllowParallel(b *testing.B) {
+	throttle := New(1*time.Nanosecond, WithBurst(1<<30))
+	defer throttle.Close()
+	var next atomic.Uint64
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := next.Add(1) * 7919
+		for pb.Next() {
+			throttle.Allow(benchmarkClients[i%uint64(len(benchmarkClients))])
+			i++
+		}
+	})
+}
+
+// batchStore is a BatchStore counting the batches taken from its buckets.
+type batchStore struct {
+	*memoryStore
+	batches int
+}
+
+func (s *batchStore) TryConsumeAll(ctx context.Context, buckets []KeyedLimit, n int, maxWait time.Duration) ([]Result, error) {
+	s.batches++
+	results := make([]Result, len(buckets))
+	fits := true
+	for i, b := range buckets {
+		results[i], _ = s.Peek(ctx, b.Key, b.Limit)
+		results[i].Wait = time.Duration(max(float64(n-results[i].Remaining), 0) * float64(b.Limit.Rate))
+		fits = fits && results[i].Wait <= maxWait
+	}
+	if !fits {
+		return results, nil
+	}
+	for i, b := range buckets {
+		results[i], _ = s.TryConsume(ctx, b.Key, b.Limit, n, maxWait)
+	}
+	return results, nil
+}
+
+func TestBatchStore(t *testing.T) {
+	clock := newFakeClock()
+	store := &batchStore{memoryStore: newMemoryStore(clock)}
+	throttle := New(1*time.Second, WithBurst(3), WithTier("minute", 2, time.Minute), WithStore(store), WithClock(clock))
+
+	for i, expected := range []bool{true, true, false} {
+		if allowed := throttle.Allow("alice"); allowed != expected {
+			t.Errorf("request %d: expected allowed to be %v, got %v", i, expected, allowed)
+		}
+	}
+	if store.batches != 3 {
+		t.Errorf("expected 3 batches, got %d", store.batches)
+	}
+	if result, _ := store.Peek(context.Background(), "alice", Limit{Rate: 1 * time.Second, Burst: 3}); result.Remaining != 1 {
+		t.Errorf("expected the rejected request to leave the client's bucket untouched, got %d remaining", result.Remaining)
+	}
+}
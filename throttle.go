@@ -1,66 +1,691 @@
 package throttle
 
 import (
-	"fmt"
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Throttle allows the client to throttle the rate at which requests are
 // handled by clients.
 type Throttle struct {
-	requestRate     time.Duration
-	tokenChansMutex sync.Mutex
-	tokenChans      map[string]chan struct{}
+	rateMutex     sync.RWMutex
+	requestRate   time.Duration
+	clientRates   map[string]time.Duration
+	burst         int
+	global        *Limit
+	fair          *fairQueue
+	tiers         []tier
+	tierFn        func(client string) Tier
+	tierKeys      *shardedMap[map[string]string]
+	calendar      []calendarQuota
+	schedule      *schedule
+	inFlight      *inFlight
+	queues        *priorityQueues
+	aging         time.Duration
+	breaker       *breaker
+	anomalies     *anomalies
+	observer      *observer
+	maxWait       time.Duration
+	maxWaiters    int
+	dropPolicy    DropPolicy
+	jitterFactor  float64
+	warmUp        *warmUp
+	coldStart     *coldStart
+	aimd          *aimd
+	penalties     *penalties
+	paused        atomic.Bool
+	draining      atomic.Bool
+	accessMutex   sync.RWMutex
+	exempt        map[string]struct{}
+	bans          map[string]time.Time
+	groups        map[string]string
+	keyCaches     []keyCache
+	normalize     func(string) string
+	queued        bool
+	pacing        bool
+	queueCapacity int
+	idleTimeout   time.Duration
+	lru           *lru
+	sizeInterval  time.Duration
+	sizeReport    func(Footprint)
+	goroutines    atomic.Int64
+	logger        *slog.Logger
+	store         Store
+	newStore      func(Clock) Store
+	clock         Clock
+	metrics       *metrics
+	expvarName    string
+	gossip        *Gossip
+	costFn        func(*http.Request) int
+	rejectFn      func(http.ResponseWriter, *http.Request, *ThrottledError)
+	bypass        []func(*http.Request) bool
+	softLimit     float64
+	shadow        bool
+	unenforced    float64
+	unpooled      bool
+	waitHooks     []WaitHook
+	onAllow       []func(client string, waited time.Duration)
+	onReject      []func(client string)
+	closeOnce     sync.Once
+	done          chan struct{}
 }
 
-// New creates a new Throttle with the given request rate.
-func New(requestRate time.Duration) *Throttle {
+// Option configures a Throttle created by New.
+type Option func(*Throttle)
+
+// WithBurst allows a client to accumulate up to n tokens while being idle, so
+// that up to n requests can be served at once. A client that has been idle
+// for k request rates has min(k, n) tokens, so intermittent clients are not
+// punished for bursty but low-average usage. The default burst is 1; values
+// lower than 1 are ignored.
+func WithBurst(n int) Option {
+	return func(t *Throttle) {
+		if n >= 1 {
+			t.burst = n
+		}
+	}
+}
+
+// WithMaxWait sets how long a request may wait for a token before it is
+// rejected. By default, or if d is 0, a request waits for at most one request
+// rate.
+func WithMaxWait(d time.Duration) Option {
+	return func(t *Throttle) {
+		t.maxWait = d
+	}
+}
+
+// WithMaxWaiters limits the number of requests waiting for a token per client
+// to n. Once n requests of a client are waiting, further requests having to
+// wait are rejected right away with a *ThrottledError, unless WithDropPolicy
+// says otherwise. By default, or if n is 0, the number of waiting requests is
+// not limited.
+func WithMaxWaiters(n int) Option {
+	return func(t *Throttle) {
+		t.maxWaiters = n
+	}
+}
+
+// New creates a new Throttle with the given request rate and options.
+func New(requestRate time.Duration, opts ...Option) *Throttle {
 	throttle := Throttle{
 		requestRate: requestRate,
-		tokenChans:  make(map[string]chan struct{}),
+		clientRates: make(map[string]time.Duration),
+		burst:       1,
+		done:        make(chan struct{}),
+		clock:       realClock{},
+		metrics:     newMetrics(),
+		tierKeys:    newShardedMap[map[string]string](),
+		newStore: func(clock Clock) Store {
+			return newMemoryStore(clock)
+		},
+	}
+	for _, opt := range opts {
+		opt(&throttle)
+	}
+	if throttle.pacing {
+		throttle.burst = 1
+	}
+	if throttle.store == nil {
+		throttle.store = throttle.newStore(throttle.clock)
+	}
+	if throttle.idleTimeout > 0 {
+		throttle.spawn(throttle.janitor)
+	}
+	if throttle.gossip != nil {
+		throttle.spawn(throttle.gossip.run)
+	}
+	if throttle.sizeReport != nil {
+		throttle.spawn(throttle.reportSize)
+	}
+	if throttle.expvarName != "" {
+		throttle.publishExpvar(throttle.expvarName)
 	}
 	return &throttle
 }
 
 // Wait ensures that only one request per client is allowed within Throttle's
-// defined timeout. For every client, a token is produced once per timeout. The
-// token is given to one of the waiting requests, and a new token is produced
-// thereafter. A request either acquires a token within the given timeout, or
-// the request runs out of time, and an error is returned. The first token is
-// spawned immediately.
+// defined request rate. For every client, a token is produced once per request
+// rate. Waiting requests are served in the order they arrived: upon arrival,
+// a request reserves the next token not reserved by an earlier request, so
+// that no request can overtake another one of the same client. A request
+// either acquires a token within the maximum
+// waiting time (one request rate, unless configured using WithMaxWait), or the
+// request is rejected right away, and a *ThrottledError is returned. The first
+// token is spawned immediately; with WithBurst, a client starts with a full
+// bucket. Errors of the Store are returned as they are, and ErrClosed is
+// returned once the Throttle has been closed.
 func (t *Throttle) Wait(client string) error {
-	// every user has a channel that gets tokens
-	t.tokenChansMutex.Lock()
-	tokenChan, ok := t.tokenChans[client]
-	if !ok {
-		tokenChan = make(chan struct{})
-		go func() {
-			// the first token is spawned immediately
-			tokenChan <- struct{}{}
-		}()
-		t.tokenChans[client] = tokenChan
-	}
-	t.tokenChansMutex.Unlock()
-
-	// timeout after given time
-	timeoutChan := make(chan struct{})
-	go func() {
-		time.Sleep(t.requestRate)
-		timeoutChan <- struct{}{}
-	}()
-
-	// wait for timeout or token
+	return t.WaitContext(context.Background(), client)
+}
+
+// WaitContext works like Wait, but gives up as soon as ctx is cancelled or its
+// deadline expires, in which case the context's error is returned. A request
+// whose token would not be available before the deadline is rejected right
+// away instead of waiting in vain, with a *ThrottledError wrapping
+// context.DeadlineExceeded.
+func (t *Throttle) WaitContext(ctx context.Context, client string) error {
+	return t.WaitNContext(ctx, client, 1)
+}
+
+// WaitN works like Wait, but takes n tokens at once for a request that is n
+// times as expensive as a regular one. By default, such a request waits for
+// at most n request rates. ErrBurstExceeded is returned right away if n is
+// greater than the burst, for the request could never be served. A request
+// for less than one token is served immediately.
+func (t *Throttle) WaitN(client string, n int) error {
+	return t.WaitNContext(context.Background(), client, n)
+}
+
+// WaitNContext works like WaitN, but gives up as soon as ctx is cancelled or
+// its deadline expires, in which case the context's error is returned.
+func (t *Throttle) WaitNContext(ctx context.Context, client string, n int) error {
+	return t.waitN(ctx, t.key(client), n)
+}
+
+// waitN takes n tokens for the client within its maximum waiting time.
+func (t *Throttle) waitN(ctx context.Context, client string, n int) error {
+	_, err := t.wait(ctx, client, n, PriorityNormal, t.timeout(client, n))
+	return err
+}
+
+// WaitDur works like Wait, but also returns how long the request blocked
+// before it acquired its token or gave up, e.g. to log requests delayed beyond
+// a latency budget.
+func (t *Throttle) WaitDur(client string) (time.Duration, error) {
+	return t.WaitDurContext(context.Background(), client)
+}
+
+// WaitDurContext works like WaitDur, but gives up as soon as ctx is cancelled
+// or its deadline expires, in which case the context's error is returned.
+func (t *Throttle) WaitDurContext(ctx context.Context, client string) (time.Duration, error) {
+	client = t.key(client)
+	return t.wait(ctx, client, 1, PriorityNormal, t.timeout(client, 1))
+}
+
+// WaitChan works like Wait, but returns right away with a channel that
+// receives Wait's result once the request has acquired its token or has been
+// rejected, so that the caller can select on it together with other events.
+// The channel is buffered: a token acquired is taken even if its result is
+// never received. Use Reserve for a token that can be given back.
+func (t *Throttle) WaitChan(client string) <-chan error {
+	result := make(chan error, 1)
+	t.spawn(func() {
+		result <- t.Wait(client)
+	})
+	return result
+}
+
+// timeout returns the maximum waiting time of the client's requests for n
+// tokens.
+func (t *Throttle) timeout(client string, n int) time.Duration {
+	t.rateMutex.RLock()
+	maxWait, capacity := t.maxWait, t.queueCapacity
+	t.rateMutex.RUnlock()
+	switch {
+	case t.queued:
+		// a queued request waits for the requests queued before it
+		return t.rate(client) * time.Duration(capacity)
+	case maxWait == 0 && t.pacing:
+		return unlimitedWait
+	case maxWait == 0:
+		return t.rate(client) * time.Duration(n)
+	}
+	return maxWait
+}
+
+// TryWaitFor works like Wait, but waits for at most d instead of the
+// Throttle's maximum waiting time.
+func (t *Throttle) TryWaitFor(client string, d time.Duration) error {
+	_, err := t.wait(context.Background(), t.key(client), 1, PriorityNormal, d)
+	return err
+}
+
+// wait takes n tokens for the client within the timeout, and returns how long
+// it blocked.
+func (t *Throttle) wait(ctx context.Context, client string, n int, prio Priority, timeout time.Duration) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	draining := t.draining.Load()
+	if draining {
+		// no new request lines up, it is served right away or not at all
+		timeout = 0
+	}
+	patience := timeout
+	if deadline, ok := ctx.Deadline(); ok {
+		// a token arriving after the deadline is of no use to the caller
+		if d := deadline.Sub(t.clock.Now()); d < timeout {
+			timeout = max(d, 0)
+		}
+	}
+	var waited time.Duration
+	var err error
+	if t.queues != nil && !t.shadows(client) {
+		waited, err = t.acquireQueued(ctx, client, n, prio, timeout)
+	} else {
+		waited, err = t.acquire(ctx, client, n, timeout)
+	}
+	if throttledErr, ok := asThrottled(err); ok && draining {
+		err = ErrDraining
+	} else if ok && timeout < patience && throttledErr.RetryAfter <= patience {
+		// the request would have waited, but not beyond the deadline
+		throttledErr.Err = errDeadline
+	}
+	for _, hook := range t.waitHooks {
+		hook(ctx, client, waited, err)
+	}
+	return waited, err
+}
+
+// acquire takes n tokens for the client within the timeout. It returns how
+// long it blocked waiting for the tokens.
+func (t *Throttle) acquire(ctx context.Context, client string, n int, timeout time.Duration) (time.Duration, error) {
+	if t.closed() {
+		return 0, ErrClosed
+	}
+	if n < 1 {
+		return 0, nil
+	}
+	wait, err := t.consume(ctx, client, n, timeout)
+	if _, ok := asThrottled(err); ok {
+		// timeout: the next token comes too late, do not serve the request
+		t.rejected(client, t.clock.Now())
+	}
+	if t.shadowed(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	start := t.clock.Now()
+	if wait == 0 || t.shadows(client) {
+		t.allowed(client, start, wait)
+		return 0, nil
+	}
+
+	if !t.metrics.startWaiting(client, t.maxWaiters) {
+		// too many requests waiting already, do not pile up another one
+		t.refund(client, n)
+		t.rejected(client, start)
+		return 0, &ThrottledError{
+			Client:     client,
+			Rate:       t.rate(client),
+			RetryAfter: wait,
+		}
+	}
+
+	// wait for the reserved token or cancellation
+	defer t.metrics.waiting(client, -1)
+	after, timer := t.after(wait)
+	defer stopTimer(timer)
+	select {
+	case <-after:
+		waited := t.clock.Now().Sub(start)
+		t.allowed(client, start, waited)
+		return waited, nil
+	case <-t.done:
+		return t.clock.Now().Sub(start), ErrClosed
+	case <-ctx.Done():
+		// cancelled: the caller is no longer interested in the tokens
+		t.refund(client, n)
+		return t.clock.Now().Sub(start), ctx.Err()
+	}
+}
+
+// maxLevels is the number of levels a request collects without allocating,
+// enough for a client with a global limit and a couple of tiers.
+const maxLevels = 4
+
+// level is one of the buckets a request of a client takes its tokens from.
+type level struct {
+	client string
+	key    string
+	tier   string
+	limit  Limit
+}
+
+// levels returns the buckets a request of the client takes its tokens from,
+// starting with the client's own bucket.
+func (t *Throttle) levels(client string) []level {
+	return t.appendLevels(nil, client)
+}
+
+// appendLevels appends the levels of the client to levels, so that requests
+// can collect them in a buffer on the stack.
+func (t *Throttle) appendLevels(levels []level, client string) []level {
+	key := t.bucketKey(client)
+	levels = append(levels, level{client: client, key: key, limit: t.limit(client)})
+	if t.global != nil {
+		levels = append(levels, level{client: client, key: globalKey, tier: GlobalTier, limit: *t.global})
+	}
+	for _, tier := range t.tiers {
+		levels = append(levels, level{client: client, key: t.tierKey(key, tier.name), tier: tier.name, limit: tier.limit})
+	}
+	if t.tierFn != nil {
+		for _, quota := range t.tierFn(client).Quotas {
+			q := quota.tier()
+			levels = append(levels, level{client: client, key: t.tierKey(client, q.name), tier: q.name, limit: q.limit})
+		}
+	}
+	return levels
+}
+
+// consume takes n tokens for the client from all its levels, provided that
+// they become available within maxWait, and returns the time until the last of
+// them becomes available. If a level cannot provide its tokens in time, the
+// tokens already taken are refunded, and a *ThrottledError is returned.
+func (t *Throttle) consume(ctx context.Context, client string, n int, maxWait time.Duration) (time.Duration, error) {
+	if exempt, err := t.check(client); exempt || err != nil {
+		return 0, err
+	}
+	t.track(client)
+	cold := t.cold(client)
+	if cold > maxWait {
+		return 0, &ThrottledError{Client: client, Rate: t.rate(client), RetryAfter: cold}
+	}
+	var buffer [maxLevels]level
+	levels := t.appendLevels(buffer[:0], client)
+	fair := t.fair != nil && t.global != nil && !t.shadows(client)
+	if fair {
+		// the global tokens are handed out by the fair queue
+		levels = slices.DeleteFunc(levels, func(l level) bool { return l.key == globalKey })
+	}
+	wait, err := t.take(ctx, levels, n, maxWait)
+	if err != nil {
+		return 0, err
+	}
+	if fair {
+		blocked, err := t.takeGlobal(ctx, client, n, maxWait)
+		if err != nil {
+			t.refundLevels(levels, n)
+			return 0, err
+		}
+		wait = max(wait-blocked, 0)
+		levels = append(levels, level{client: client, key: globalKey, tier: GlobalTier, limit: *t.global})
+	}
+	if err := t.consumeQuotas(ctx, client, n); err != nil {
+		t.refundLevels(levels, n)
+		return 0, err
+	}
+	return max(wait, cold), nil
+}
+
+// check reports whether the client is exempt from throttling, or returns the
+// error for a client that must not be served at all.
+func (t *Throttle) check(client string) (exempt bool, err error) {
+	switch exempt, banned := t.access(client); {
+	case exempt:
+		return true, nil
+	case t.paused.Load():
+		return false, ErrPaused
+	case banned > 0:
+		return false, &ThrottledError{
+			Client:     client,
+			Tier:       BannedTier,
+			Rate:       t.rate(client),
+			RetryAfter: banned,
+		}
+	}
+	if t.breaker != nil {
+		if pass, wait := t.breaker.pass(client, t.clock.Now()); !pass {
+			return false, &ThrottledError{
+				Client:     client,
+				Tier:       BreakerTier,
+				Rate:       t.rate(client),
+				RetryAfter: wait,
+			}
+		}
+	}
+	return false, nil
+}
+
+// take takes n tokens from all levels, like consume.
+func (t *Throttle) take(ctx context.Context, levels []level, n int, maxWait time.Duration) (time.Duration, error) {
+	if store, ok := t.store.(BatchStore); ok && len(levels) > 1 {
+		return t.takeBatch(ctx, store, levels, n, maxWait)
+	}
+	var wait time.Duration
+	for i, l := range levels {
+		if n > l.limit.Burst {
+			t.refundLevels(levels[:i], n)
+			return 0, ErrBurstExceeded
+		}
+		result, err := t.store.TryConsume(ctx, l.key, l.limit, n, maxWait)
+		if err != nil {
+			t.refundLevels(levels[:i], n)
+			return 0, err
+		}
+		if !result.OK {
+			t.refundLevels(levels[:i], n)
+			return 0, &ThrottledError{
+				Client:     l.client,
+				Tier:       l.tier,
+				Rate:       l.limit.Rate,
+				RetryAfter: result.Wait,
+			}
+		}
+		if result.Wait > wait {
+			wait = result.Wait
+		}
+	}
+	return wait, nil
+}
+
+// takeBatch takes n tokens from all levels at once using the BatchStore.
+func (t *Throttle) takeBatch(ctx context.Context, store BatchStore, levels []level, n int, maxWait time.Duration) (time.Duration, error) {
+	buckets := make([]KeyedLimit, len(levels))
+	for i, l := range levels {
+		if n > l.limit.Burst {
+			return 0, ErrBurstExceeded
+		}
+		buckets[i] = KeyedLimit{Key: l.key, Limit: l.limit}
+	}
+	results, err := store.TryConsumeAll(ctx, buckets, n, maxWait)
+	if err != nil {
+		return 0, err
+	}
+	var wait time.Duration
+	for i, result := range results {
+		if !result.OK && result.Wait > maxWait {
+			l := levels[i]
+			return 0, &ThrottledError{
+				Client:     l.client,
+				Tier:       l.tier,
+				Rate:       l.limit.Rate,
+				RetryAfter: result.Wait,
+			}
+		}
+		wait = max(wait, result.Wait)
+	}
+	if len(results) > 0 && !results[0].OK {
+		return 0, &ThrottledError{Client: levels[0].client, Rate: levels[0].limit.Rate, RetryAfter: wait}
+	}
+	return wait, nil
+}
+
+// Refund puts a token acquired by a request of the client back, e.g. if the
+// operation protected by the Throttle failed right away without doing any
+// work, so that the client does not pay for it. The bucket never holds more
+// than its burst. The token is also put back into the limits of WithTier,
+// WithGlobalLimit, and WithCalendarQuota; the client's stats are not changed.
+func (t *Throttle) Refund(client string) {
+	t.refund(t.key(client), 1)
+}
+
+// refund puts n tokens back into all the levels of the client.
+func (t *Throttle) refund(client string, n int) {
+	t.refundLevels(t.levels(client), n)
+	t.refundQuotas(t.calendar, client, n, t.clock.Now())
+}
+
+func (t *Throttle) refundLevels(levels []level, n int) {
+	for _, l := range levels {
+		t.store.Refund(context.Background(), l.key, l.limit, n)
+	}
+}
+
+// Allow reports whether a token is available for the client right now. If so,
+// the token is consumed and true is returned; otherwise, false is returned
+// immediately without waiting for a token to be spawned. False is returned as
+// well if the Store fails or the Throttle has been closed.
+func (t *Throttle) Allow(client string) bool {
+	return t.AllowN(client, 1)
+}
+
+// AllowN works like Allow, but takes n tokens at once. It reports false if n
+// is greater than the burst, and true if n is less than one.
+func (t *Throttle) AllowN(client string, n int) bool {
+	return t.allowN(t.key(client), n)
+}
+
+func (t *Throttle) allowN(client string, n int) bool {
+	if t.closed() {
+		return false
+	}
+	if n < 1 {
+		return true
+	}
+	_, err := t.consume(context.Background(), client, n, 0)
+	if _, ok := asThrottled(err); ok {
+		t.rejected(client, t.clock.Now())
+	}
+	if t.shadowed(err) {
+		return true
+	}
+	if err != nil {
+		return false
+	}
+	t.allowed(client, t.clock.Now(), 0)
+	return true
+}
+
+// Close releases the resources of the Throttle. Requests waiting for a token
+// as well as all subsequent requests are rejected with ErrClosed. The Store is
+// not closed. Closing a Throttle more than once has no effect.
+func (t *Throttle) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.done)
+	})
+	return nil
+}
+
+// closed reports whether the Throttle has been closed.
+func (t *Throttle) closed() bool {
 	select {
-	case <-tokenChan:
-		// token acquired: request can be served, new token be spawned
-		go func() {
-			time.Sleep(t.requestRate)
-			tokenChan <- struct{}{}
-		}()
-		return nil
-	case <-timeoutChan:
-		// timeout: do not serve the request
-		return fmt.Errorf("one request per %v allowed", t.requestRate)
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// janitor periodically forgets about clients that have been idle for at least
+// the idle timeout, until the Throttle is closed.
+func (t *Throttle) janitor() {
+	for {
+		select {
+		case now := <-t.clock.After(t.idleTimeout):
+			before := t.clients()
+			if store, ok := t.store.(idleEvicter); ok {
+				store.evictIdle(now, t.idleTimeout)
+			}
+			t.metrics.evictIdle(now, t.idleTimeout)
+			t.tierKeys.each(func(string, map[string]string) bool { return false })
+			t.clearKeyCaches()
+			if t.anomalies != nil {
+				t.anomalies.evictIdle(now, t.idleTimeout)
+			}
+			if t.observer != nil {
+				t.observer.evictIdle(now, t.idleTimeout)
+			}
+			if after := t.clients(); after < before {
+				t.debug("idle clients evicted", slog.Int("evicted", before-after), slog.Int("clients", after))
+			}
+		case <-t.done:
+			return
+		}
 	}
 }
+
+// idleEvicter is implemented by the Stores keeping their state in memory.
+type idleEvicter interface {
+	// evictIdle forgets about clients idle since idleTimeout before now.
+	evictIdle(now time.Time, idleTimeout time.Duration)
+}
+
+// Rate returns the request rate currently in effect for clients without their
+// own rate, which is the one scheduled using WithSchedule, if any.
+func (t *Throttle) Rate() time.Duration {
+	t.rateMutex.RLock()
+	base := t.requestRate
+	t.rateMutex.RUnlock()
+	return t.scheduled(base)
+}
+
+// SetRate changes the request rate for all clients without their own rate set
+// using SetClientRate, outside of the rates scheduled using WithSchedule. From
+// now on, tokens are spawned according to the new rate.
+func (t *Throttle) SetRate(requestRate time.Duration) {
+	t.rateMutex.Lock()
+	t.requestRate = requestRate
+	t.rateMutex.Unlock()
+	t.debug("rate changed", slog.Duration("rate", requestRate))
+}
+
+// SetClientRate changes the request rate for the given client only, which
+// affects both the spawning of its tokens and the time its requests wait for
+// them. A rate of 0 resets the client to the Throttle's request rate.
+func (t *Throttle) SetClientRate(client string, requestRate time.Duration) {
+	t.setClientRate(t.key(client), requestRate)
+}
+
+func (t *Throttle) setClientRate(client string, requestRate time.Duration) {
+	t.rateMutex.Lock()
+	if requestRate == 0 {
+		delete(t.clientRates, client)
+	} else {
+		t.clientRates[client] = requestRate
+	}
+	t.rateMutex.Unlock()
+	t.debug("client rate changed", slog.String("client", client), slog.Duration("rate", requestRate))
+}
+
+// rate returns the request rate in effect for the given client.
+func (t *Throttle) rate(client string) time.Duration {
+	t.rateMutex.RLock()
+	rate, ok := t.clientRates[client]
+	base := t.requestRate
+	t.rateMutex.RUnlock()
+	if ok {
+		return rate
+	}
+	if t.tierFn != nil {
+		if rate := t.tierFn(client).Rate; rate > 0 {
+			return rate
+		}
+	}
+	return t.scheduled(base)
+}
+
+// clientBurst returns the burst in effect for the given client.
+func (t *Throttle) clientBurst(client string) int {
+	if t.tierFn != nil {
+		if burst := t.tierFn(client).Burst; burst > 0 {
+			return burst
+		}
+	}
+	t.rateMutex.RLock()
+	defer t.rateMutex.RUnlock()
+	return t.burst
+}
+
+// limit returns the limit of the given client's bucket.
+func (t *Throttle) limit(client string) Limit {
+	return t.warm(client, Limit{Rate: t.jitter(t.penalize(client, t.rate(client))), Burst: t.clientBurst(client)})
+}
@@ -1,6 +1,9 @@
 package throttle
 
 import (
+	"context"
+	"errors"
+	"runtime"
 	"sync"
 	"testing"
 	"time"
@@ -85,3 +88,492 @@ func TestDoubleRequestTooFast(t *testing.T) {
 		}
 	}
 }
+
+func TestWaitContextCancelled(t *testing.T) {
+	throttle := New(1 * time.Second)
+	if err := throttle.Wait("alice"); err != nil {
+		t.Fatalf("first request: expected no error, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := throttle.WaitContext(ctx, "alice")
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed > 500*time.Millisecond {
+		t.Errorf("expected WaitContext to return after ~50ms, took %v", elapsed)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	throttle := New(100 * time.Millisecond)
+	if !throttle.Allow("alice") {
+		t.Errorf("first request: expected to be allowed")
+	}
+	if throttle.Allow("alice") {
+		t.Errorf("second request: expected to be rejected")
+	}
+	if !throttle.Allow("bob") {
+		t.Errorf("other client: expected to be allowed")
+	}
+	time.Sleep(150 * time.Millisecond)
+	if !throttle.Allow("alice") {
+		t.Errorf("request after rate: expected to be allowed")
+	}
+}
+
+func TestAllowN(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithBurst(5), WithClock(clock))
+	if !throttle.AllowN("alice", 3) {
+		t.Errorf("three tokens out of five: expected to be allowed")
+	}
+	if throttle.AllowN("alice", 3) {
+		t.Errorf("three tokens out of two: expected to be rejected")
+	}
+	if !throttle.AllowN("alice", 2) {
+		t.Errorf("remaining two tokens: expected to be allowed")
+	}
+	if throttle.AllowN("bob", 6) {
+		t.Errorf("tokens beyond burst: expected to be rejected")
+	}
+	clock.Advance(300 * time.Millisecond)
+	if !throttle.AllowN("alice", 3) {
+		t.Errorf("three tokens after three rates: expected to be allowed")
+	}
+}
+
+func TestWaitN(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithBurst(4), WithClock(clock))
+	if err := throttle.WaitN("alice", 3); err != nil {
+		t.Fatalf("first request: expected no error, got %v", err)
+	}
+
+	// two tokens are missing, which takes two rates
+	done := make(chan error)
+	go func() {
+		done <- throttle.WaitN("alice", 3)
+	}()
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(200 * time.Millisecond)
+	if err := <-done; err != nil {
+		t.Errorf("second request: expected no error, got %v", err)
+	}
+
+	if err := throttle.WaitN("alice", 5); !errors.Is(err, ErrBurstExceeded) {
+		t.Errorf("request beyond burst: expected %v, got %v", ErrBurstExceeded, err)
+	}
+	if err := throttle.WaitN("alice", 0); err != nil {
+		t.Errorf("request for no tokens: expected no error, got %v", err)
+	}
+}
+
+func TestBurstAccumulatesWhileIdle(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithBurst(5), WithClock(clock))
+	if !throttle.AllowN("alice", 5) {
+		t.Fatalf("full bucket: expected to be allowed")
+	}
+	clock.Advance(300 * time.Millisecond)
+	if got := throttle.Remaining("alice"); got != 3 {
+		t.Errorf("idle for three rates: expected 3 tokens, got %d", got)
+	}
+	clock.Advance(1 * time.Second)
+	if got := throttle.Remaining("alice"); got != 5 {
+		t.Errorf("idle for long: expected tokens capped at 5, got %d", got)
+	}
+	if !throttle.AllowN("alice", 5) || throttle.Allow("alice") {
+		t.Errorf("after idling: expected exactly the burst to be allowed")
+	}
+}
+
+func TestBurst(t *testing.T) {
+	throttle := New(100*time.Millisecond, WithBurst(3))
+	for i := 0; i < 3; i++ {
+		if !throttle.Allow("alice") {
+			t.Errorf("request %d: expected to be allowed within burst", i)
+		}
+	}
+	if throttle.Allow("alice") {
+		t.Errorf("request beyond burst: expected to be rejected")
+	}
+
+	// tokens are spawned one per rate, not all at once
+	time.Sleep(150 * time.Millisecond)
+	if !throttle.Allow("alice") {
+		t.Errorf("request after one rate: expected to be allowed")
+	}
+	if throttle.Allow("alice") {
+		t.Errorf("second request after one rate: expected to be rejected")
+	}
+}
+
+func TestMaxWait(t *testing.T) {
+	throttle := New(100*time.Millisecond, WithMaxWait(300*time.Millisecond))
+	for i := 0; i < 3; i++ {
+		if err := throttle.Wait("alice"); err != nil {
+			t.Errorf("request %d: expected to be served within max wait, got %v", i, err)
+		}
+	}
+}
+
+func TestWaitFIFO(t *testing.T) {
+	const n = 10
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithMaxWait(n*100*time.Millisecond), WithClock(clock))
+	throttle.Wait("alice")
+
+	served := make(chan int, n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			throttle.Wait("alice")
+			served <- i
+		}(i)
+		for clock.Waiters() < i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for i := 0; i < n; i++ {
+		clock.Advance(100 * time.Millisecond)
+		if got := <-served; got != i {
+			t.Errorf("token %d: expected to be given to request %d, got %d", i, i, got)
+		}
+	}
+}
+
+func TestMaxWaiters(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithMaxWait(1*time.Second), WithMaxWaiters(2), WithClock(clock))
+	throttle.Wait("alice")
+
+	done := make(chan error)
+	for i := 0; i < 2; i++ {
+		go func() {
+			done <- throttle.Wait("alice")
+		}()
+	}
+	for clock.Waiters() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := throttle.Wait("alice"); !errors.Is(err, ErrThrottled) {
+		t.Errorf("third waiter: expected %v, got %v", ErrThrottled, err)
+	}
+
+	// the rejected waiter did not keep its token
+	clock.Advance(200 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("waiter %d: expected no error, got %v", i, err)
+		}
+	}
+	clock.Advance(100 * time.Millisecond)
+	if !throttle.Allow("alice") {
+		t.Errorf("request after waiters: expected to be allowed")
+	}
+}
+
+func TestTryWaitFor(t *testing.T) {
+	throttle := New(100 * time.Millisecond)
+	if err := throttle.TryWaitFor("alice", 0); err != nil {
+		t.Fatalf("first request: expected no error, got %v", err)
+	}
+	if err := throttle.TryWaitFor("alice", 10*time.Millisecond); err == nil {
+		t.Errorf("short wait: expected to time out before next token")
+	}
+	if err := throttle.TryWaitFor("alice", 200*time.Millisecond); err != nil {
+		t.Errorf("long wait: expected token within wait, got %v", err)
+	}
+}
+
+func TestWaitDur(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithClock(clock))
+	if waited, err := throttle.WaitDur("alice"); err != nil || waited != 0 {
+		t.Fatalf("first request: expected not to wait, waited %v with %v", waited, err)
+	}
+
+	type result struct {
+		waited time.Duration
+		err    error
+	}
+	done := make(chan result)
+	go func() {
+		waited, err := throttle.WaitDur("alice")
+		done <- result{waited, err}
+	}()
+	for clock.Waiters() == 0 {
+		time.Sleep(1 * time.Millisecond)
+	}
+	clock.Advance(100 * time.Millisecond)
+	if r := <-done; r.err != nil || r.waited != 100*time.Millisecond {
+		t.Errorf("second request: expected to wait %v, waited %v with %v", 100*time.Millisecond, r.waited, r.err)
+	}
+}
+
+func TestWaitChan(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithClock(clock))
+	if err := <-throttle.WaitChan("alice"); err != nil {
+		t.Fatalf("first request: expected no error, got %v", err)
+	}
+
+	result := throttle.WaitChan("alice")
+	for clock.Waiters() == 0 {
+		time.Sleep(1 * time.Millisecond)
+	}
+	select {
+	case err := <-result:
+		t.Fatalf("second request: expected to wait, got %v", err)
+	default:
+	}
+	clock.Advance(100 * time.Millisecond)
+	if err := <-result; err != nil {
+		t.Errorf("second request: expected no error, got %v", err)
+	}
+
+	throttle.Close()
+	if err := <-throttle.WaitChan("alice"); !errors.Is(err, ErrClosed) {
+		t.Errorf("closed throttle: expected %v, got %v", ErrClosed, err)
+	}
+}
+
+func TestWaitContextDeadline(t *testing.T) {
+	throttle := New(1*time.Second, WithMaxWait(1*time.Minute))
+	defer throttle.Close()
+	throttle.Allow("alice")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := throttle.WaitContext(ctx, "alice")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("token after the deadline: expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	var deadlineErr *ThrottledError
+	if !errors.As(err, &deadlineErr) || deadlineErr.RetryAfter <= 0 {
+		t.Errorf("token after the deadline: expected a *ThrottledError with RetryAfter, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 100*time.Millisecond {
+		t.Errorf("token after the deadline: expected to fail fast, took %v", elapsed)
+	}
+	if n := throttle.Stats("alice").Waiters; n != 0 {
+		t.Errorf("token after the deadline: expected not to wait, got %d waiters", n)
+	}
+
+	// a token that would not be served anyway is throttled as usual
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel2()
+	throttle.SetClientRate("alice", 1*time.Hour)
+	var throttledErr *ThrottledError
+	if err := throttle.WaitContext(ctx2, "alice"); !errors.As(err, &throttledErr) {
+		t.Errorf("token beyond the maximum wait: expected a *ThrottledError, got %v", err)
+	}
+
+	cancel()
+	if err := throttle.WaitContext(ctx, "bob"); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled context: expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestRefund(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Second, WithClock(clock), WithTier("minute", 5, 1*time.Minute))
+	for i := 0; i < 3; i++ {
+		if !throttle.Allow("alice") {
+			t.Fatalf("request %d: expected refunded token to be available", i)
+		}
+		throttle.Refund("alice")
+	}
+	if !throttle.AllowN("alice", 1) || throttle.Allow("alice") {
+		t.Errorf("refunds: expected not to exceed the burst")
+	}
+}
+
+func TestSetRate(t *testing.T) {
+	throttle := New(1 * time.Hour)
+	if !throttle.Allow("alice") {
+		t.Fatalf("first request: expected to be allowed")
+	}
+
+	throttle.SetRate(50 * time.Millisecond)
+	if rate := throttle.Rate(); rate != 50*time.Millisecond {
+		t.Errorf("rate: expected %v, got %v", 50*time.Millisecond, rate)
+	}
+
+	// the pending token is spawned according to the new rate
+	if err := throttle.TryWaitFor("alice", 200*time.Millisecond); err != nil {
+		t.Errorf("request after loosening rate: expected no error, got %v", err)
+	}
+}
+
+func TestSetClientRate(t *testing.T) {
+	throttle := New(1 * time.Hour)
+	throttle.SetClientRate("premium", 50*time.Millisecond)
+
+	for _, client := range []string{"alice", "premium"} {
+		if !throttle.Allow(client) {
+			t.Fatalf("first request of %s: expected to be allowed", client)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if throttle.Allow("alice") {
+		t.Errorf("default client: expected to be rejected")
+	}
+	if !throttle.Allow("premium") {
+		t.Errorf("premium client: expected to be allowed")
+	}
+
+	// the client's rate applies to its waiting time, too
+	start := time.Now()
+	throttle.Wait("premium")
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("premium client: expected to wait ~50ms, waited %v", elapsed)
+	}
+	throttle.SetClientRate("premium", 0)
+	if rate := throttle.rate("premium"); rate != 1*time.Hour {
+		t.Errorf("reset client rate: expected %v, got %v", 1*time.Hour, rate)
+	}
+}
+
+func TestClose(t *testing.T) {
+	throttle := New(1*time.Second, WithMaxWait(1*time.Second), WithIdleTimeout(10*time.Millisecond))
+	if err := throttle.Wait("alice"); err != nil {
+		t.Fatalf("first request: expected no error, got %v", err)
+	}
+
+	// a request waiting for its token is released by Close
+	waiting := make(chan error)
+	go func() {
+		waiting <- throttle.Wait("alice")
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := throttle.Close(); err != nil {
+		t.Errorf("close: expected no error, got %v", err)
+	}
+	select {
+	case err := <-waiting:
+		if err != ErrClosed {
+			t.Errorf("waiting request: expected %v, got %v", ErrClosed, err)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Errorf("waiting request: expected to be released by Close")
+	}
+
+	if err := throttle.Wait("bob"); err != ErrClosed {
+		t.Errorf("request after close: expected %v, got %v", ErrClosed, err)
+	}
+	if throttle.Allow("bob") {
+		t.Errorf("request after close: expected to be rejected")
+	}
+	if err := throttle.Close(); err != nil {
+		t.Errorf("second close: expected no error, got %v", err)
+	}
+}
+
+func TestWaitWithoutGoroutines(t *testing.T) {
+	throttle := New(1*time.Second, WithMaxWait(1*time.Hour))
+	defer throttle.Close()
+	throttle.Allow("alice")
+
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	const waiters = 100
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			throttle.WaitContext(ctx, "alice")
+		}()
+	}
+	for throttle.Stats("alice").Waiters < waiters {
+		time.Sleep(1 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine() - before; n > waiters {
+		t.Errorf("expected no goroutines besides the %d waiters, got %d", waiters, n-waiters)
+	}
+	cancel()
+	wg.Wait()
+}
+
+func TestAllocations(t *testing.T) {
+	quotas := Tier{Quotas: []Quota{{Name: "daily", N: 1 << 30, Window: 24 * time.Hour}}}
+	throttles := map[string]*Throttle{
+		"global": New(1*time.Nanosecond, WithBurst(1<<30), WithGlobalLimit(1*time.Nanosecond, 1<<30)),
+		"tiers": New(1*time.Nanosecond, WithBurst(1<<30),
+			WithTier("minute", 1<<30, time.Minute), WithTier("hour", 1<<30, time.Hour)),
+		"quotas": New(1*time.Nanosecond, WithBurst(1<<30), WithGlobalLimit(1*time.Nanosecond, 1<<30),
+			WithTier("minute", 1<<30, time.Minute), WithTierFunc(func(string) Tier { return quotas })),
+	}
+	for limits, throttle := range throttles {
+		defer throttle.Close()
+		throttle.Allow("alice")
+		requests := map[string]func(){
+			"Allow": func() { throttle.Allow("alice") },
+			"Wait":  func() { throttle.Wait("alice") },
+			"WaitContext": func() {
+				throttle.WaitContext(context.Background(), "alice")
+			},
+		}
+		for name, request := range requests {
+			if allocs := testing.AllocsPerRun(100, request); allocs != 0 {
+				t.Errorf("%s with %s: expected no allocations for a known client, got %v", name, limits, allocs)
+			}
+		}
+	}
+}
+
+func BenchmarkAllow(b *testing.B) {
+	throttle := New(1*time.Nanosecond, WithBurst(1<<30))
+	defer throttle.Close()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		throttle.Allow("alice")
+	}
+}
+
+func BenchmarkAllowRejected(b *testing.B) {
+	throttle := New(1 * time.Hour)
+	defer throttle.Close()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		throttle.Allow("alice")
+	}
+}
+
+func BenchmarkAllowGlobal(b *testing.B) {
+	throttle := New(1*time.Nanosecond, WithBurst(1<<30), WithGlobalLimit(1*time.Nanosecond, 1<<30))
+	defer throttle.Close()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		throttle.Allow("alice")
+	}
+}
+
+func BenchmarkWait(b *testing.B) {
+	throttle := New(1*time.Nanosecond, WithBurst(1<<30))
+	defer throttle.Close()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		throttle.Wait("alice")
+	}
+}
+
+func BenchmarkWaitBlocking(b *testing.B) {
+	// every request waits for its token
+	throttle := New(1*time.Microsecond, WithMaxWait(1*time.Hour))
+	defer throttle.Close()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		throttle.Wait("alice")
+	}
+}
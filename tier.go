@@ -0,0 +1,85 @@
+package throttle
+
+import "time"
+
+// tier is an additional limit for the requests of every client.
+type tier struct {
+	name  string
+	limit Limit
+}
+
+// WithTier adds a limit of n requests per window for every client, which is
+// enforced in addition to the Throttle's request rate and all other tiers, so
+// that quotas like 5 requests per second, 100 per minute, and 5000 per day can
+// be combined. Every tier is a bucket of n tokens spawning one token per
+// window/n, using the same algorithm as the Throttle's own bucket. A request
+// rejected because of the tier reports its name as the ThrottledError's Tier;
+// names have to be unique. Values of n lower than 1 are treated as 1.
+func WithTier(name string, n int, window time.Duration) Option {
+	return func(t *Throttle) {
+		t.tiers = append(t.tiers, Quota{Name: name, N: n, Window: window}.tier())
+	}
+}
+
+// Tier describes the limits of a class of clients, e.g. the free, pro, or
+// enterprise plan of an API.
+type Tier struct {
+	// Rate is the request rate of the clients. If 0, the Throttle's rate
+	// applies.
+	Rate time.Duration
+
+	// Burst is the burst of the clients. If 0, the Throttle's burst applies.
+	Burst int
+
+	// Quotas are enforced in addition to the rate like the limits added by
+	// WithTier, which still apply.
+	Quotas []Quota
+}
+
+// Quota is a limit of N requests per Window, see WithTier.
+type Quota struct {
+	Name   string
+	N      int
+	Window time.Duration
+}
+
+// tier returns the quota as an additional limit.
+func (q Quota) tier() tier {
+	n := q.N
+	if n < 1 {
+		n = 1
+	}
+	return tier{name: q.Name, limit: Limit{Rate: q.Window / time.Duration(n), Burst: n}}
+}
+
+// WithTierFunc looks up the limits of every client using tierFn, e.g. from a
+// database of API keys and their plans. A rate set using SetClientRate takes
+// precedence over the rate of the client's Tier. Since tierFn is called for
+// every request, more than once, it has to be fast and safe for concurrent
+// use; expensive lookups should be cached. A client whose Tier changes keeps
+// its tokens.
+func WithTierFunc(tierFn func(client string) Tier) Option {
+	return func(t *Throttle) {
+		t.tierFn = tierFn
+	}
+}
+
+// tierKey returns the key of the bucket of the named tier or quota belonging
+// to the bucket of the given key. The keys are cached, so that requests do not
+// build them anew; the cache is cleared along with the clients' state.
+func (t *Throttle) tierKey(key, name string) string {
+	shard := t.tierKeys.shard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	keys, ok := shard.entries[key]
+	if !ok {
+		keys = make(map[string]string)
+		shard.entries[key] = keys
+	}
+	tierKey, ok := keys[name]
+	if !ok {
+		tierKey = key + "\x00" + name
+		keys[name] = tierKey
+	}
+	return tierKey
+}
@@ -0,0 +1,74 @@
+package throttle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTier(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(10*time.Millisecond, WithClock(clock),
+		WithTier("second", 5, 1*time.Second),
+		WithTier("minute", 8, 1*time.Minute))
+
+	for i := 0; i < 5; i++ {
+		if !throttle.Allow("alice") {
+			t.Errorf("request %d: expected to be allowed", i)
+		}
+		clock.Advance(10 * time.Millisecond)
+	}
+	err := throttle.TryWaitFor("alice", 0)
+	var throttledErr *ThrottledError
+	if !errors.As(err, &throttledErr) || throttledErr.Tier != "second" {
+		t.Fatalf("sixth request: expected to exceed tier %q, got %v", "second", err)
+	}
+
+	clock.Advance(1 * time.Second)
+	for i := 0; i < 3; i++ {
+		if !throttle.Allow("alice") {
+			t.Errorf("request %d of next second: expected to be allowed", i)
+		}
+		clock.Advance(10 * time.Millisecond)
+	}
+	err = throttle.TryWaitFor("alice", 0)
+	if !errors.As(err, &throttledErr) || throttledErr.Tier != "minute" {
+		t.Errorf("ninth request: expected to exceed tier %q, got %v", "minute", err)
+	}
+	if !throttle.Allow("bob") {
+		t.Errorf("other client: expected to be allowed")
+	}
+}
+
+func TestTierFunc(t *testing.T) {
+	clock := newFakeClock()
+	tiers := map[string]Tier{
+		"free": {Rate: 1 * time.Second, Quotas: []Quota{{Name: "daily", N: 2, Window: 24 * time.Hour}}},
+		"pro":  {Rate: 100 * time.Millisecond, Burst: 3},
+	}
+	throttle := New(10*time.Second, WithClock(clock), WithMaxWait(0), WithTierFunc(func(client string) Tier {
+		return tiers[client]
+	}))
+
+	if !throttle.AllowN("pro", 3) || throttle.Allow("pro") {
+		t.Errorf("pro: expected a burst of 3")
+	}
+	if rate := throttle.rate("pro"); rate != 100*time.Millisecond {
+		t.Errorf("pro: expected rate %v, got %v", 100*time.Millisecond, rate)
+	}
+	if rate := throttle.rate("unknown"); rate != 10*time.Second {
+		t.Errorf("client without tier: expected the throttle's rate %v, got %v", 10*time.Second, rate)
+	}
+
+	for i := 0; i < 2; i++ {
+		if !throttle.Allow("free") {
+			t.Errorf("free, request %d: expected to be allowed", i)
+		}
+		clock.Advance(1 * time.Second)
+	}
+	err := throttle.TryWaitFor("free", 0)
+	var throttledErr *ThrottledError
+	if !errors.As(err, &throttledErr) || throttledErr.Tier != "daily" {
+		t.Errorf("free, third request: expected to exceed quota %q, got %v", "daily", err)
+	}
+}
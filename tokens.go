@@ -0,0 +1,60 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+)
+
+// Tokens returns a channel delivering the client's tokens as they become
+// available, like a time.Ticker, for loops that range over the permitted
+// slots:
+//
+//	for range t.Tokens("crawler") {
+//		fetchNext()
+//	}
+//
+// Tokens are delivered regardless of the maximum waiting time. A token is
+// taken before it is delivered, so at most one token is held back while the
+// channel is not being received from. The channel is closed once the
+// Throttle is closed. Errors of the Store are retried after one request rate.
+func (t *Throttle) Tokens(client string) <-chan struct{} {
+	return t.TokensContext(context.Background(), client)
+}
+
+// TokensContext works like Tokens, but also closes the channel as soon as ctx
+// is done.
+func (t *Throttle) TokensContext(ctx context.Context, client string) <-chan struct{} {
+	client = t.key(client)
+	tokens := make(chan struct{})
+	t.spawn(func() {
+		defer close(tokens)
+		for {
+			_, err := t.wait(ctx, client, 1, PriorityNormal, unlimitedWait)
+			if errors.Is(err, ErrClosed) || ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				after, timer := t.after(t.rate(client))
+				select {
+				case <-after:
+				case <-ctx.Done():
+					stopTimer(timer)
+					return
+				case <-t.done:
+					stopTimer(timer)
+					return
+				}
+				continue
+			}
+			select {
+			case tokens <- struct{}{}:
+			case <-ctx.Done():
+				t.refund(client, 1)
+				return
+			case <-t.done:
+				return
+			}
+		}
+	})
+	return tokens
+}
@@ -0,0 +1,47 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokens(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Second, WithBurst(2), WithClock(clock))
+	tokens := throttle.Tokens("alice")
+
+	// the burst is delivered right away
+	for i := 0; i < 2; i++ {
+		<-tokens
+	}
+	select {
+	case <-tokens:
+		t.Fatal("token delivered beyond the burst")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(1 * time.Second)
+	<-tokens
+
+	throttle.Close()
+	for range tokens {
+	}
+}
+
+func TestTokensContext(t *testing.T) {
+	throttle := New(1 * time.Hour)
+	defer throttle.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	tokens := throttle.TokensContext(ctx, "alice")
+	<-tokens
+	cancel()
+	if _, ok := <-tokens; ok {
+		t.Error("token delivered after cancellation")
+	}
+	for throttle.Size().Goroutines > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if remaining := throttle.Remaining("alice"); remaining != 0 {
+		t.Errorf("expected no tokens remaining, got %d", remaining)
+	}
+}
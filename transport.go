@@ -0,0 +1,164 @@
+package throttle
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Transport is an http.RoundTripper that throttles outgoing requests before
+// passing them on to an underlying RoundTripper:
+//
+//	client := &http.Client{Transport: &throttle.Transport{Throttle: t}}
+//
+// Requests wait for the Throttle's maximum waiting time; if no token is
+// acquired, the request fails with the Throttle's error instead of being sent.
+// Every request takes the number of tokens set by WithCostFunc. A Transport
+// must not be copied after first use.
+type Transport struct {
+	// Throttle paces the outgoing requests.
+	Throttle *Throttle
+
+	// Base is the RoundTripper used to send requests. If nil,
+	// http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// KeyFn derives the client key from the request. If nil, requests are
+	// keyed by the host of their URL.
+	KeyFn func(*http.Request) string
+
+	// Adaptive makes the Transport slow down for a client whose responses
+	// have status 429 (Too Many Requests) or 503 (Service Unavailable): no
+	// requests are sent until the time given by the Retry-After header has
+	// passed, and the client's request rate is doubled using SetClientRate,
+	// up to MaxSlowdown times its rate from before. Every successful response
+	// moves the rate a tenth of the way back to that rate.
+	Adaptive bool
+
+	// MaxSlowdown is the factor by which Adaptive slows down the request rate
+	// of a client at most. If 0, the rate is slowed down by at most 64 times.
+	MaxSlowdown int
+
+	mutex      sync.Mutex
+	retryAfter map[string]time.Time
+	adapted    map[string]adaptedRate
+}
+
+// adaptedRate is the request rate of a client slowed down by a Transport
+// before it was adapted: the rate in effect, and the one set for the client
+// using SetClientRate, if any, to be restored once it has recovered.
+type adaptedRate struct {
+	base time.Duration
+	own  time.Duration
+}
+
+// RoundTrip waits for a token of the request's client and sends the request
+// using the underlying RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	keyFn := t.KeyFn
+	if keyFn == nil {
+		keyFn = urlHost
+	}
+	client := t.Throttle.key(keyFn(req))
+	err := t.holdOff(req, client)
+	if err == nil {
+		err = t.Throttle.waitN(req.Context(), client, t.Throttle.cost(req))
+	}
+	if err != nil {
+		if req.Body != nil {
+			// a RoundTripper must always close the body
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err == nil && t.Adaptive {
+		t.adapt(client, resp)
+	}
+	return resp, err
+}
+
+// holdOff waits until the time the client has been asked to retry after.
+func (t *Transport) holdOff(req *http.Request, client string) error {
+	t.mutex.Lock()
+	until, ok := t.retryAfter[client]
+	t.mutex.Unlock()
+	if !ok {
+		return nil
+	}
+	wait := until.Sub(t.Throttle.clock.Now())
+	if wait <= 0 {
+		return nil
+	}
+	select {
+	case <-t.Throttle.clock.After(wait):
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// adapt slows down or speeds up the client depending on the response.
+func (t *Transport) adapt(client string, resp *http.Response) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	rate := t.Throttle.rate(client)
+	adapted, ok := t.adapted[client]
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		if !ok {
+			t.Throttle.rateMutex.RLock()
+			adapted = adaptedRate{base: rate, own: t.Throttle.clientRates[client]}
+			t.Throttle.rateMutex.RUnlock()
+			if t.adapted == nil {
+				t.adapted = make(map[string]adaptedRate)
+			}
+			t.adapted[client] = adapted
+		}
+		maxSlowdown := t.MaxSlowdown
+		if maxSlowdown < 1 {
+			maxSlowdown = 64
+		}
+		t.Throttle.setClientRate(client, min(2*rate, adapted.base*time.Duration(maxSlowdown)))
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), t.Throttle.clock.Now()); ok {
+			if t.retryAfter == nil {
+				t.retryAfter = make(map[string]time.Time)
+			}
+			t.retryAfter[client] = t.Throttle.clock.Now().Add(d)
+		}
+	case resp.StatusCode < 400 && ok:
+		delete(t.retryAfter, client)
+		base := adapted.base
+		if recovered := rate - (rate-base)/10; recovered-base > base/100 {
+			t.Throttle.setClientRate(client, recovered)
+		} else {
+			t.Throttle.setClientRate(client, adapted.own)
+			delete(t.adapted, client)
+		}
+	}
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either a
+// number of seconds or an HTTP date, into the time to wait from now.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date.Sub(now), true
+	}
+	return 0, false
+}
+
+// urlHost returns the host of the request's URL.
+func urlHost(r *http.Request) string {
+	return r.URL.Host
+}
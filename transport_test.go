@@ -0,0 +1,119 @@
+package throttle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTransport(t *testing.T) {
+	var served int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&served, 1)
+	}))
+	defer server.Close()
+
+	throttle := New(1*time.Second, WithMaxWait(10*time.Millisecond))
+	client := &http.Client{Transport: &Transport{Throttle: throttle}}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("first request: expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := client.Get(server.URL); err == nil {
+		t.Errorf("second request: expected to be throttled")
+	}
+	if n := atomic.LoadInt32(&served); n != 1 {
+		t.Errorf("expected %d request to reach the server, got %d", 1, n)
+	}
+}
+
+func TestTransportAdaptive(t *testing.T) {
+	var served int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&served, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithBurst(10), WithClock(clock))
+	client := &http.Client{Transport: &Transport{Throttle: throttle, Adaptive: true}}
+	host := server.Listener.Addr().String()
+
+	resp, err := client.Get(server.URL)
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("first request: expected status %d, got %v, %v", http.StatusTooManyRequests, resp, err)
+	}
+	resp.Body.Close()
+	if rate := throttle.rate(host); rate != 200*time.Millisecond {
+		t.Errorf("after 429: expected rate %v, got %v", 200*time.Millisecond, rate)
+	}
+
+	// the next request is held off until Retry-After has passed
+	done := make(chan error)
+	go func() {
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&served); n != 1 {
+		t.Errorf("during Retry-After: expected %d request to reach the server, got %d", 1, n)
+	}
+	clock.Advance(1 * time.Second)
+	if err := <-done; err != nil {
+		t.Fatalf("second request: expected no error, got %v", err)
+	}
+	if rate := throttle.rate(host); rate != 190*time.Millisecond {
+		t.Errorf("after success: expected rate %v, got %v", 190*time.Millisecond, rate)
+	}
+}
+
+func TestTransportAdaptiveClientRate(t *testing.T) {
+	throttle := New(1 * time.Second)
+	throttle.SetClientRate("alice", 100*time.Millisecond)
+	transport := &Transport{Throttle: throttle, Adaptive: true, MaxSlowdown: 4}
+
+	for i := 0; i < 100; i++ {
+		transport.adapt("alice", &http.Response{StatusCode: http.StatusTooManyRequests})
+	}
+	if rate := throttle.rate("alice"); rate != 400*time.Millisecond {
+		t.Errorf("after 429s: expected the slowest rate %v, got %v", 400*time.Millisecond, rate)
+	}
+	for i := 0; i < 100; i++ {
+		transport.adapt("alice", &http.Response{StatusCode: http.StatusOK})
+	}
+	if rate := throttle.rate("alice"); rate != 100*time.Millisecond {
+		t.Errorf("recovered: expected the client's rate %v, got %v", 100*time.Millisecond, rate)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2021, 2, 22, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		wait  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"Mon, 22 Feb 2021 00:00:30 GMT", 30 * time.Second, true},
+		{"soon", 0, false},
+	}
+	for _, test := range tests {
+		if wait, ok := parseRetryAfter(test.value, now); wait != test.wait || ok != test.ok {
+			t.Errorf("%q: expected %v, %v, got %v, %v", test.value, test.wait, test.ok, wait, ok)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+)
+
+// WaitAll works like Wait, but takes a token for each of the clients at once,
+// e.g. for a user, the user's organization, and the whole API: either all of
+// the tokens are acquired, or none is, and the tokens ever taken are put back.
+// Limits shared by the clients, like WithGlobalLimit, are only taken once. A
+// client given twice takes two tokens. The request waits for the token coming
+// last within the shortest maximum waiting time of the clients; it bypasses
+// the queues of WithPriorities.
+func (t *Throttle) WaitAll(clients ...string) error {
+	return t.WaitAllContext(context.Background(), clients...)
+}
+
+// WaitAllContext works like WaitAll, but gives up as soon as ctx is cancelled
+// or its deadline expires, in which case the context's error is returned.
+func (t *Throttle) WaitAllContext(ctx context.Context, clients ...string) error {
+	if t.closed() {
+		return ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if t.normalize != nil {
+		keys := make([]string, len(clients))
+		for i, client := range clients {
+			keys[i] = t.key(client)
+		}
+		clients = keys
+	}
+	levels, patience, err := t.levelsAll(clients)
+	if err != nil {
+		return err
+	}
+	timeout := patience
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = min(timeout, max(deadline.Sub(t.clock.Now()), 0))
+	}
+	draining := t.draining.Load()
+	if draining {
+		timeout, patience = 0, 0
+	}
+	start := t.clock.Now()
+	wait, err := t.take(ctx, levels, 1, timeout)
+	var throttledErr *ThrottledError
+	if errors.As(err, &throttledErr) {
+		t.rejected(throttledErr.Client, start)
+		if t.shadowed(err) {
+			return nil
+		}
+		if draining {
+			return ErrDraining
+		}
+		if timeout < patience && throttledErr.RetryAfter <= patience {
+			throttledErr.Err = errDeadline
+			return throttledErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if wait > 0 && !slices.ContainsFunc(clients, t.enforced) {
+		for _, client := range clients {
+			t.allowed(client, start, wait)
+		}
+		return nil
+	}
+	if wait > 0 {
+		for i, client := range clients {
+			if !t.metrics.startWaiting(client, t.maxWaiters) {
+				for _, waiting := range clients[:i] {
+					t.metrics.waiting(waiting, -1)
+				}
+				t.refundLevels(levels, 1)
+				t.rejected(client, start)
+				return &ThrottledError{Client: client, Rate: t.rate(client), RetryAfter: wait}
+			}
+			defer t.metrics.waiting(client, -1)
+		}
+		after, timer := t.after(wait)
+		defer stopTimer(timer)
+		select {
+		case <-after:
+		case <-t.done:
+			return ErrClosed
+		case <-ctx.Done():
+			t.refundLevels(levels, 1)
+			return ctx.Err()
+		}
+	}
+	waited := t.clock.Now().Sub(start)
+	for _, client := range clients {
+		t.allowed(client, start, waited)
+	}
+	return nil
+}
+
+// levelsAll returns the levels of all clients without duplicate shared
+// levels, and the shortest maximum waiting time of the clients.
+func (t *Throttle) levelsAll(clients []string) ([]level, time.Duration, error) {
+	var levels []level
+	var timeout time.Duration
+	global := false
+	for i, client := range clients {
+		exempt, err := t.check(client)
+		if err != nil {
+			return nil, 0, err
+		}
+		if d := t.timeout(client, 1); i == 0 || d < timeout {
+			timeout = d
+		}
+		if exempt {
+			continue
+		}
+		t.track(client)
+		for _, l := range t.levels(client) {
+			if l.key == globalKey {
+				if global {
+					continue
+				}
+				global = true
+			}
+			levels = append(levels, l)
+		}
+	}
+	return levels, timeout, nil
+}
@@ -0,0 +1,59 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitAll(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Second, WithClock(clock), WithMaxWait(0), WithBurst(2),
+		WithGlobalLimit(1*time.Millisecond, 3))
+	throttle.SetClientRate("acme", 10*time.Second)
+
+	if err := throttle.WaitAll("alice", "acme"); err != nil {
+		t.Fatalf("first request: expected no error, got %v", err)
+	}
+	if err := throttle.WaitAll("bob", "acme"); err != nil {
+		t.Fatalf("second request: expected no error, got %v", err)
+	}
+
+	// the organization is exhausted, so alice's token must not be taken
+	err := throttle.WaitAll("alice", "acme")
+	var throttledErr *ThrottledError
+	if !errors.As(err, &throttledErr) || throttledErr.Client != "acme" {
+		t.Fatalf("third request: expected acme to be throttled, got %v", err)
+	}
+	result, _ := throttle.store.Peek(context.Background(), "alice", throttle.limit("alice"))
+	if result.Remaining != 1 {
+		t.Errorf("alice: expected the token of the rejected request to be refunded, %d remaining", result.Remaining)
+	}
+	result, _ = throttle.store.Peek(context.Background(), globalKey, *throttle.global)
+	if result.Remaining != 1 {
+		t.Errorf("global: expected one token per request, %d remaining", result.Remaining)
+	}
+
+	if stats := throttle.Stats("acme"); stats.Allowed != 2 || stats.Rejected != 1 {
+		t.Errorf("acme: expected 2 allowed and 1 rejected request, got %+v", stats)
+	}
+}
+
+func TestWaitAllWaits(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(1*time.Second, WithClock(clock))
+	throttle.WaitAll("alice", "acme")
+
+	done := make(chan error)
+	go func() {
+		done <- throttle.WaitAll("alice", "acme")
+	}()
+	for clock.Waiters() == 0 {
+		time.Sleep(1 * time.Millisecond)
+	}
+	clock.Advance(1 * time.Second)
+	if err := <-done; err != nil {
+		t.Errorf("second request: expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package throttle
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// warmUp tracks since when the clients have been active.
+type warmUp struct {
+	mutex     sync.Mutex
+	period    time.Duration
+	start     float64
+	clients   map[string]*activity
+	lastSweep time.Time
+}
+
+// activity is the time span a client has been active for.
+type activity struct {
+	first time.Time
+	last  time.Time
+}
+
+// WithWarmUp makes new clients, as well as clients that have been idle for at
+// least the period, start at a fraction of their throughput, which then ramps
+// up linearly to the full throughput over the period. With a fraction of 0.1,
+// a client making its first request is allowed one request per ten request
+// rates, and its burst is reduced to 10% as well, so that it cannot hit cold
+// caches at full speed. While warming up, requests for more tokens than the
+// reduced burst fail with ErrBurstExceeded. Fractions up to 0 are treated as
+// 0.01, and fractions above 1 as 1.
+func WithWarmUp(period time.Duration, fraction float64) Option {
+	return func(t *Throttle) {
+		if period <= 0 {
+			return
+		}
+		switch {
+		case fraction <= 0:
+			fraction = 0.01
+		case fraction > 1:
+			fraction = 1
+		}
+		t.warmUp = &warmUp{
+			period:  period,
+			start:   fraction,
+			clients: make(map[string]*activity),
+		}
+	}
+}
+
+// throughput returns the fraction of its throughput the client is allowed at
+// the given time, and records the client as active.
+func (w *warmUp) throughput(client string, now time.Time) float64 {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if now.Sub(w.lastSweep) >= w.period {
+		// clients idle for a period are cold again, so they can be forgotten
+		for c, a := range w.clients {
+			if now.Sub(a.last) >= w.period {
+				delete(w.clients, c)
+			}
+		}
+		w.lastSweep = now
+	}
+	a, ok := w.clients[client]
+	if !ok || now.Sub(a.last) >= w.period {
+		a = &activity{first: now}
+		w.clients[client] = a
+	}
+	a.last = now
+	progress := math.Min(float64(now.Sub(a.first))/float64(w.period), 1)
+	return w.start + (1-w.start)*progress
+}
+
+// warm returns the limit reduced to the throughput allowed for the client.
+func (t *Throttle) warm(client string, limit Limit) Limit {
+	if t.warmUp == nil {
+		return limit
+	}
+	f := t.warmUp.throughput(client, t.clock.Now())
+	if f >= 1 {
+		return limit
+	}
+	limit.Rate = time.Duration(float64(limit.Rate) / f)
+	if burst := int(float64(limit.Burst) * f); burst < limit.Burst {
+		limit.Burst = burst
+		if limit.Burst < 1 {
+			limit.Burst = 1
+		}
+	}
+	return limit
+}
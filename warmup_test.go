@@ -0,0 +1,36 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWarmUp(t *testing.T) {
+	clock := newFakeClock()
+	throttle := New(100*time.Millisecond, WithBurst(10), WithWarmUp(1*time.Minute, 0.1), WithClock(clock))
+
+	limit := throttle.limit("alice")
+	if limit.Rate != 1*time.Second || limit.Burst != 1 {
+		t.Errorf("new client: expected %v, got %+v", Limit{Rate: 1 * time.Second, Burst: 1}, limit)
+	}
+	if !throttle.Allow("alice") || throttle.Allow("alice") {
+		t.Errorf("new client: expected a single request to be allowed")
+	}
+
+	clock.Advance(30 * time.Second)
+	limit = throttle.limit("alice")
+	if limit.Burst != 5 || limit.Rate <= 100*time.Millisecond || limit.Rate >= 1*time.Second {
+		t.Errorf("half-way: expected burst 5 and a rate between, got %+v", limit)
+	}
+
+	clock.Advance(30 * time.Second)
+	if limit = throttle.limit("alice"); limit != (Limit{Rate: 100 * time.Millisecond, Burst: 10}) {
+		t.Errorf("warm client: expected full limit, got %+v", limit)
+	}
+
+	// an idle client gets cold again
+	clock.Advance(1 * time.Minute)
+	if limit = throttle.limit("alice"); limit.Burst != 1 {
+		t.Errorf("idle client: expected to warm up again, got %+v", limit)
+	}
+}
@@ -0,0 +1,363 @@
+package throttle
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// NewSlidingWindow creates a new Throttle that allows up to n requests per
+// client within any period of the given window. Other than in the token bucket
+// used by New, requests are counted continuously, so that a client can never
+// make more than n requests in a window, not even after being idle. The
+// Throttle's request rate is window/n, and its burst is n; WithBurst and
+// WithStore are ignored.
+//
+// The Throttle remembers the times of the requests within the window per
+// client, which makes it suitable for moderate values of n.
+func NewSlidingWindow(n int, window time.Duration, opts ...Option) *Throttle {
+	if n < 1 {
+		n = 1
+	}
+	opts = append(opts, WithBurst(n), withStoreFactory(func(clock Clock) Store {
+		return newSlidingWindowStore(clock)
+	}))
+	return New(window/time.Duration(n), opts...)
+}
+
+// withStoreFactory makes the Throttle use the Store returned by newStore,
+// which is called with the Throttle's Clock once all options are applied.
+func withStoreFactory(newStore func(Clock) Store) Option {
+	return func(t *Throttle) {
+		t.store = nil
+		t.newStore = newStore
+	}
+}
+
+// slidingWindowStore is a Store that keeps the times of the requests within the
+// window of every client, including the times reserved for waiting requests.
+// The window of a client is limit.Rate*limit.Burst.
+type slidingWindowStore struct {
+	clock   Clock
+	mutex   sync.Mutex
+	clients map[string][]time.Time
+}
+
+func newSlidingWindowStore(clock Clock) *slidingWindowStore {
+	return &slidingWindowStore{
+		clock:   clock,
+		clients: make(map[string][]time.Time),
+	}
+}
+
+func (s *slidingWindowStore) TryConsume(ctx context.Context, client string, limit Limit, n int, maxWait time.Duration) (Result, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.clock.Now()
+	times := s.clients[client]
+	next := nextSlot(times, limit, n, now)
+	wait := next.Sub(now)
+	if wait > maxWait {
+		return windowResult(times, false, wait, limit, now), nil
+	}
+	// requests that left the window are no longer relevant
+	w := window(limit)
+	for len(times) > 0 && now.Sub(times[0]) >= w {
+		times = times[1:]
+	}
+	for i := 0; i < n; i++ {
+		times = append(times, next)
+	}
+	s.clients[client] = times
+	return windowResult(times, true, wait, limit, now), nil
+}
+
+func (s *slidingWindowStore) Refund(ctx context.Context, client string, limit Limit, n int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	times := s.clients[client]
+	if n > len(times) {
+		n = len(times)
+	}
+	s.clients[client] = times[:len(times)-n]
+	return nil
+}
+
+func (s *slidingWindowStore) Peek(ctx context.Context, client string, limit Limit) (Result, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.clock.Now()
+	times := s.clients[client]
+	return windowResult(times, false, nextSlot(times, limit, 1, now).Sub(now), limit, now), nil
+}
+
+// Len returns the number of clients with requests.
+func (s *slidingWindowStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.clients)
+}
+
+// evictIdle removes the clients whose last request is at least idleTimeout
+// before now.
+func (s *slidingWindowStore) evictIdle(now time.Time, idleTimeout time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for client, times := range s.clients {
+		if len(times) == 0 || now.Sub(times[len(times)-1]) >= idleTimeout {
+			delete(s.clients, client)
+		}
+	}
+}
+
+func (s *slidingWindowStore) forget(client string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.clients, client)
+}
+
+// nextSlot returns the earliest time not before now at which n requests fit
+// into the window, given the times of the previous requests.
+func nextSlot(times []time.Time, limit Limit, n int, now time.Time) time.Time {
+	if len(times)+n <= limit.Burst {
+		return now
+	}
+	// the requests fit once all but the last Burst-n requests left the window
+	next := times[len(times)-limit.Burst+n-1].Add(window(limit))
+	if next.Before(now) {
+		return now
+	}
+	return next
+}
+
+// windowResult describes the window of a client with the given requests.
+func windowResult(times []time.Time, ok bool, wait time.Duration, limit Limit, now time.Time) Result {
+	w := window(limit)
+	used := 0
+	for _, t := range times {
+		if now.Sub(t) < w {
+			used++
+		}
+	}
+	result := Result{OK: ok, Wait: wait, Remaining: limit.Burst - used}
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+	if used > 0 {
+		result.Reset = times[len(times)-1].Add(w).Sub(now)
+	}
+	return result
+}
+
+// window returns the length of the window described by limit.
+func window(limit Limit) time.Duration {
+	return limit.Rate * time.Duration(limit.Burst)
+}
+
+// NewFixedWindow creates a new Throttle that allows up to n requests per
+// client within fixed windows of the given length. The windows are aligned to
+// the clock, so that e.g. windows of one minute start on the minute, and every
+// client's count is reset at the same, predictable time. A client can make up
+// to 2n requests around the start of a window, though. The Throttle's request
+// rate is window/n, and its burst is n; WithBurst and WithStore are ignored.
+func NewFixedWindow(n int, window time.Duration, opts ...Option) *Throttle {
+	if n < 1 {
+		n = 1
+	}
+	opts = append(opts, WithBurst(n), withStoreFactory(func(clock Clock) Store {
+		return newFixedWindowStore(clock)
+	}))
+	return New(window/time.Duration(n), opts...)
+}
+
+// fixedWindowStore is a Store that counts the requests of every client in the
+// current window. Requests waiting for a later window are counted as well, so
+// that a count beyond the limit spills over into the following windows.
+type fixedWindowStore struct {
+	clock   Clock
+	mutex   sync.Mutex
+	clients map[string]*fixedWindow
+}
+
+type fixedWindow struct {
+	start time.Time
+	count int
+	end   time.Time
+}
+
+func newFixedWindowStore(clock Clock) *fixedWindowStore {
+	return &fixedWindowStore{
+		clock:   clock,
+		clients: make(map[string]*fixedWindow),
+	}
+}
+
+func (s *fixedWindowStore) TryConsume(ctx context.Context, client string, limit Limit, n int, maxWait time.Duration) (Result, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.clock.Now()
+	fw := s.window(client, limit, now)
+	// all n requests have to fall into the same window as the last of them
+	last := fw.slot(fw.count+n-1, limit)
+	wait := last.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > maxWait {
+		return fw.result(false, wait, limit, now), nil
+	}
+	fw.end = last.Add(window(limit))
+	fw.count += n
+	return fw.result(true, wait, limit, now), nil
+}
+
+func (s *fixedWindowStore) Refund(ctx context.Context, client string, limit Limit, n int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	fw := s.window(client, limit, s.clock.Now())
+	fw.count -= n
+	if fw.count < 0 {
+		fw.count = 0
+	}
+	return nil
+}
+
+func (s *fixedWindowStore) Peek(ctx context.Context, client string, limit Limit) (Result, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.clock.Now()
+	fw := s.window(client, limit, now)
+	wait := fw.slot(fw.count, limit).Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	return fw.result(false, wait, limit, now), nil
+}
+
+// Len returns the number of clients with a window.
+func (s *fixedWindowStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.clients)
+}
+
+// evictIdle removes the clients whose last window with requests ended at
+// least idleTimeout before now.
+func (s *fixedWindowStore) evictIdle(now time.Time, idleTimeout time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for client, fw := range s.clients {
+		if now.Sub(fw.end) >= idleTimeout {
+			delete(s.clients, client)
+		}
+	}
+}
+
+func (s *fixedWindowStore) forget(client string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.clients, client)
+}
+
+// window returns the client's window moved forward to the one containing now.
+// It requires the caller to hold the mutex.
+func (s *fixedWindowStore) window(client string, limit Limit, now time.Time) *fixedWindow {
+	w := window(limit)
+	start := now.Truncate(w)
+	fw, ok := s.clients[client]
+	if !ok {
+		fw = &fixedWindow{start: start}
+		s.clients[client] = fw
+	}
+	if elapsed := int(start.Sub(fw.start) / w); elapsed > 0 {
+		// the requests of the elapsed windows no longer count
+		fw.count -= elapsed * limit.Burst
+		if fw.count < 0 {
+			fw.count = 0
+		}
+		fw.start = start
+	}
+	return fw
+}
+
+// slot returns the start of the window the k-th request counted falls into.
+func (fw *fixedWindow) slot(k int, limit Limit) time.Time {
+	return fw.start.Add(window(limit) * time.Duration(k/limit.Burst))
+}
+
+func (fw *fixedWindow) result(ok bool, wait time.Duration, limit Limit, now time.Time) Result {
+	remaining := limit.Burst - fw.count
+	if remaining < 0 {
+		remaining = 0
+	}
+	return Result{
+		OK:        ok,
+		Wait:      wait,
+		Remaining: remaining,
+		Reset:     fw.start.Add(window(limit)).Sub(now),
+	}
+}
+
+func (s *slidingWindowStore) kind() string {
+	return "sliding-window"
+}
+
+func (s *slidingWindowStore) snapshot() interface{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	state := make(map[string][]time.Time, len(s.clients))
+	for client, times := range s.clients {
+		state[client] = append([]time.Time(nil), times...)
+	}
+	return state
+}
+
+func (s *slidingWindowStore) restore(data json.RawMessage) error {
+	var state map[string][]time.Time
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	if state == nil {
+		state = make(map[string][]time.Time)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.clients = state
+	return nil
+}
+
+// fixedWindowState is the serialized state of a fixedWindow.
+type fixedWindowState struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+	End   time.Time `json:"end"`
+}
+
+func (s *fixedWindowStore) kind() string {
+	return "fixed-window"
+}
+
+func (s *fixedWindowStore) snapshot() interface{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	state := make(map[string]fixedWindowState, len(s.clients))
+	for client, fw := range s.clients {
+		state[client] = fixedWindowState{Start: fw.start, Count: fw.count, End: fw.end}
+	}
+	return state
+}
+
+func (s *fixedWindowStore) restore(data json.RawMessage) error {
+	var state map[string]fixedWindowState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.clients = make(map[string]*fixedWindow, len(state))
+	for client, fw := range state {
+		s.clients[client] = &fixedWindow{start: fw.Start, count: fw.Count, end: fw.End}
+	}
+	return nil
+}
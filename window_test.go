@@ -0,0 +1,126 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSlidingWindow(t *testing.T) {
+	clock := newFakeClock()
+	throttle := NewSlidingWindow(3, 1*time.Second, WithClock(clock), WithMaxWait(1*time.Millisecond))
+
+	// three requests within the window, spread out
+	for i := 0; i < 3; i++ {
+		if !throttle.Allow("alice") {
+			t.Errorf("request %d: expected to be allowed", i)
+		}
+		clock.Advance(300 * time.Millisecond)
+	}
+	if throttle.Allow("alice") {
+		t.Errorf("fourth request within window: expected to be rejected")
+	}
+
+	// the first request leaves the window after one second
+	clock.Advance(100 * time.Millisecond)
+	if !throttle.Allow("alice") {
+		t.Errorf("request after first left the window: expected to be allowed")
+	}
+	if throttle.Allow("alice") {
+		t.Errorf("another request: expected to be rejected")
+	}
+}
+
+func TestSlidingWindowIdle(t *testing.T) {
+	clock := newFakeClock()
+	throttle := NewSlidingWindow(2, 1*time.Second, WithClock(clock))
+
+	// unlike a token bucket, an idle client cannot exceed n per window
+	clock.Advance(1 * time.Hour)
+	for i := 0; i < 2; i++ {
+		throttle.Allow("alice")
+	}
+	if throttle.Allow("alice") {
+		t.Errorf("request beyond n: expected to be rejected")
+	}
+}
+
+func TestSlidingWindowReservation(t *testing.T) {
+	clock := newFakeClock()
+	store := newSlidingWindowStore(clock)
+	limit := Limit{Rate: 500 * time.Millisecond, Burst: 2}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		store.TryConsume(ctx, "alice", limit, 1, 0)
+		clock.Advance(100 * time.Millisecond)
+	}
+	result, _ := store.TryConsume(ctx, "alice", limit, 1, 1*time.Second)
+	if !result.OK || result.Wait != 800*time.Millisecond {
+		t.Errorf("reservation: expected to wait %v, got %+v", 800*time.Millisecond, result)
+	}
+	store.Refund(ctx, "alice", limit, 1)
+	result, _ = store.Peek(ctx, "alice", limit)
+	if result.Remaining != 0 || result.Wait != 800*time.Millisecond || result.Reset != 900*time.Millisecond {
+		t.Errorf("after refund: expected to wait %v, got %+v", 800*time.Millisecond, result)
+	}
+}
+
+func TestFixedWindow(t *testing.T) {
+	clock := newFakeClock()
+	throttle := NewFixedWindow(2, 1*time.Minute, WithClock(clock), WithMaxWait(1*time.Millisecond))
+
+	clock.Advance(30 * time.Second)
+	for i := 0; i < 2; i++ {
+		if !throttle.Allow("alice") {
+			t.Errorf("request %d: expected to be allowed", i)
+		}
+	}
+	if throttle.Allow("alice") {
+		t.Errorf("request beyond n: expected to be rejected")
+	}
+
+	// the count is reset on the minute
+	result, _ := throttle.store.Peek(context.Background(), "alice", throttle.limit("alice"))
+	if result.Reset != 30*time.Second {
+		t.Errorf("reset: expected %v, got %v", 30*time.Second, result.Reset)
+	}
+	clock.Advance(30 * time.Second)
+	if !throttle.Allow("alice") {
+		t.Errorf("request in next window: expected to be allowed")
+	}
+}
+
+func TestFixedWindowReservation(t *testing.T) {
+	clock := newFakeClock()
+	store := newFixedWindowStore(clock)
+	limit := Limit{Rate: 500 * time.Millisecond, Burst: 2}
+	ctx := context.Background()
+
+	clock.Advance(400 * time.Millisecond)
+	expected := []Result{
+		{OK: true, Wait: 0, Remaining: 1, Reset: 600 * time.Millisecond},
+		{OK: true, Wait: 0, Remaining: 0, Reset: 600 * time.Millisecond},
+		{OK: true, Wait: 600 * time.Millisecond, Remaining: 0, Reset: 600 * time.Millisecond},
+		{OK: false, Wait: 600 * time.Millisecond, Remaining: 0, Reset: 600 * time.Millisecond},
+	}
+	for i, exp := range expected {
+		maxWait := time.Duration(0)
+		if i == 2 {
+			maxWait = 1 * time.Second
+		}
+		if result, _ := store.TryConsume(ctx, "alice", limit, 1, maxWait); result != exp {
+			t.Errorf("request %d: expected %+v, got %+v", i, exp, result)
+		}
+	}
+
+	// the reserved request counts in the next window
+	clock.Advance(600 * time.Millisecond)
+	if result, _ := store.Peek(ctx, "alice", limit); result.Remaining != 1 {
+		t.Errorf("next window: expected %d remaining, got %+v", 1, result)
+	}
+	store.evictIdle(clock.Now().Add(2*time.Second), 1*time.Second)
+	if n := store.Len(); n != 0 {
+		t.Errorf("idle client: expected to be evicted, %d left", n)
+	}
+}